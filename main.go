@@ -11,7 +11,12 @@ import (
 	"github.com/pocketbase/pocketbase/tools/hook"
 
 	"github.com/dorianlgs/photo-cifu/pkg/container"
+	"github.com/dorianlgs/photo-cifu/pkg/galleries"
+	"github.com/dorianlgs/photo-cifu/pkg/geo"
+	"github.com/dorianlgs/photo-cifu/pkg/geocode"
 	"github.com/dorianlgs/photo-cifu/pkg/handlers"
+	"github.com/dorianlgs/photo-cifu/pkg/search"
+	"github.com/dorianlgs/photo-cifu/pkg/storage"
 	"github.com/dorianlgs/photo-cifu/tools"
 	"github.com/dorianlgs/photo-cifu/ui"
 )
@@ -101,6 +106,31 @@ func main() {
 		Dir:          migrationsDir,
 	})
 
+	// storage migrate command (local <-> S3)
+	app.RootCmd.AddCommand(storage.NewCommand(app))
+
+	// galleries assign-owner and read-metadata commands (backfills)
+	app.RootCmd.AddCommand(galleries.NewCommand(app))
+
+	// search reindex command
+	app.RootCmd.AddCommand(search.NewCommand(app))
+
+	// geo reindex command
+	app.RootCmd.AddCommand(geo.NewCommand(app))
+
+	// geocode lookup and backfill commands
+	app.RootCmd.AddCommand(geocode.NewCommand(app))
+
+	// delete the images of deleted galleries and move API deletes to the trash
+	galleries.Register(app)
+
+	// resolve gallery locations and image positions to places
+	geocode.Register(app)
+
+	// keep the full-text search and geo indexes in sync with galleries and images
+	search.Register(app)
+	geo.Register(app)
+
 	app.OnServe().Bind(&hook.Handler[*core.ServeEvent]{
 		Func: func(e *core.ServeEvent) error {
 			// Initialize dependency injection container
@@ -0,0 +1,42 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3598190544")
+
+  // update collection data
+  unmarshal({
+    "createRule": "@request.auth.id != '' && @request.body.owner = @request.auth.id",
+    "deleteRule": "@request.auth.id != '' && owner = @request.auth.id",
+    "updateRule": "@request.auth.id != '' && owner = @request.auth.id && (@request.body.owner:isset = false || @request.body.owner = @request.auth.id)"
+  }, collection)
+
+  // add field
+  collection.fields.addAt(5, new Field({
+    "cascadeDelete": false,
+    "collectionId": "_pb_users_auth_",
+    "hidden": false,
+    "id": "relation3479234172",
+    "maxSelect": 1,
+    "minSelect": 0,
+    "name": "owner",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "relation"
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3598190544")
+
+  // update collection data
+  unmarshal({
+    "createRule": "",
+    "deleteRule": "",
+    "updateRule": ""
+  }, collection)
+
+  // remove field
+  collection.fields.removeById("relation3479234172")
+
+  return app.save(collection)
+})
@@ -0,0 +1,24 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "createRule": "@request.auth.id != ''",
+    "deleteRule": "@request.auth.id != '' && galleries_via_images.owner ?= @request.auth.id",
+    "updateRule": "@request.auth.id != '' && galleries_via_images.owner ?= @request.auth.id"
+  }, collection)
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "createRule": "",
+    "deleteRule": "",
+    "updateRule": ""
+  }, collection)
+
+  return app.save(collection)
+})
@@ -0,0 +1,18 @@
+/// <reference path="../pb_data/types.d.ts" />
+// Galleries created before ownership existed have no owner. Single-user
+// installs get them assigned automatically; otherwise they stay ownerless
+// (editable only by superusers) until claimed with `galleries assign-owner`.
+migrate((app) => {
+  const users = app.findAllRecords("users")
+  if (users.length != 1) {
+    return
+  }
+
+  const galleries = app.findAllRecords("galleries", $dbx.hashExp({ "owner": "" }))
+  for (const gallery of galleries) {
+    gallery.set("owner", users[0].id)
+    app.saveNoValidate(gallery)
+  }
+}, (app) => {
+  // ownership is kept when rolling back
+})
@@ -0,0 +1,125 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = new Collection({
+    "createRule": null,
+    "deleteRule": "@request.auth.id != '' && (gallery.owner = @request.auth.id || user = @request.auth.id)",
+    "fields": [
+      {
+        "autogeneratePattern": "[a-z0-9]{15}",
+        "hidden": false,
+        "id": "text3208210256",
+        "max": 15,
+        "min": 15,
+        "name": "id",
+        "pattern": "^[a-z0-9]+$",
+        "presentable": false,
+        "primaryKey": true,
+        "required": true,
+        "system": true,
+        "type": "text"
+      },
+      {
+        "cascadeDelete": true,
+        "collectionId": "pbc_3598190544",
+        "hidden": false,
+        "id": "relation1194031162",
+        "maxSelect": 1,
+        "minSelect": 0,
+        "name": "gallery",
+        "presentable": false,
+        "required": true,
+        "system": false,
+        "type": "relation"
+      },
+      {
+        "exceptDomains": null,
+        "hidden": false,
+        "id": "email3885137012",
+        "name": "email",
+        "onlyDomains": null,
+        "presentable": false,
+        "required": true,
+        "system": false,
+        "type": "email"
+      },
+      {
+        "cascadeDelete": true,
+        "collectionId": "_pb_users_auth_",
+        "hidden": false,
+        "id": "relation2375276105",
+        "maxSelect": 1,
+        "minSelect": 0,
+        "name": "user",
+        "presentable": false,
+        "required": false,
+        "system": false,
+        "type": "relation"
+      },
+      {
+        "hidden": false,
+        "id": "select1466534506",
+        "maxSelect": 1,
+        "name": "role",
+        "presentable": false,
+        "required": true,
+        "system": false,
+        "type": "select",
+        "values": [
+          "viewer",
+          "contributor",
+          "editor"
+        ]
+      },
+      {
+        "cascadeDelete": false,
+        "collectionId": "_pb_users_auth_",
+        "hidden": false,
+        "id": "relation1109389909",
+        "maxSelect": 1,
+        "minSelect": 0,
+        "name": "invited_by",
+        "presentable": false,
+        "required": false,
+        "system": false,
+        "type": "relation"
+      },
+      {
+        "hidden": false,
+        "id": "autodate2990389176",
+        "name": "created",
+        "onCreate": true,
+        "onUpdate": false,
+        "presentable": false,
+        "system": false,
+        "type": "autodate"
+      },
+      {
+        "hidden": false,
+        "id": "autodate3332085495",
+        "name": "updated",
+        "onCreate": true,
+        "onUpdate": true,
+        "presentable": false,
+        "system": false,
+        "type": "autodate"
+      }
+    ],
+    "id": "pbc_3994760574",
+    "indexes": [
+      "CREATE UNIQUE INDEX `idx_collaborators_gallery_email` ON `collaborators` (`gallery`, `email`)",
+      "CREATE INDEX `idx_collaborators_user` ON `collaborators` (`user`)"
+    ],
+    "listRule": "@request.auth.id != '' && (gallery.owner = @request.auth.id || user = @request.auth.id || email = @request.auth.email)",
+    "name": "collaborators",
+    "system": false,
+    "type": "base",
+    "updateRule": "@request.auth.id != '' && gallery.owner = @request.auth.id && @request.body.gallery:isset = false && @request.body.email:isset = false && @request.body.user:isset = false",
+    "viewRule": "@request.auth.id != '' && (gallery.owner = @request.auth.id || user = @request.auth.id || email = @request.auth.email)"
+  });
+
+  return app.save(collection);
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3994760574");
+
+  return app.delete(collection);
+})
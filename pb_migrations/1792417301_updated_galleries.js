@@ -0,0 +1,24 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3598190544")
+
+  // update collection data
+  unmarshal({
+    "listRule": "@request.auth.id != '' && (owner = @request.auth.id || (@collection.collaborators.gallery ?= id && @collection.collaborators.user ?= @request.auth.id))",
+    "updateRule": "@request.auth.id != '' && (owner = @request.auth.id || (@collection.collaborators.gallery ?= id && @collection.collaborators.user ?= @request.auth.id && @collection.collaborators.role ?= 'editor')) && (@request.body.owner:isset = false || @request.body.owner = owner)",
+    "viewRule": "@request.auth.id != '' && (owner = @request.auth.id || (@collection.collaborators.gallery ?= id && @collection.collaborators.user ?= @request.auth.id))"
+  }, collection)
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3598190544")
+
+  // update collection data
+  unmarshal({
+    "listRule": "@request.auth.id != '' && owner = @request.auth.id",
+    "updateRule": "@request.auth.id != '' && owner = @request.auth.id && (@request.body.owner:isset = false || @request.body.owner = @request.auth.id)",
+    "viewRule": "@request.auth.id != '' && owner = @request.auth.id"
+  }, collection)
+
+  return app.save(collection)
+})
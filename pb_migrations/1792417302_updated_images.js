@@ -0,0 +1,22 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "listRule": "@request.auth.id != '' && (galleries_via_images.owner ?= @request.auth.id || (@collection.collaborators.gallery ?= galleries_via_images.id && @collection.collaborators.user ?= @request.auth.id))",
+    "viewRule": "@request.auth.id != '' && (galleries_via_images.owner ?= @request.auth.id || (@collection.collaborators.gallery ?= galleries_via_images.id && @collection.collaborators.user ?= @request.auth.id))"
+  }, collection)
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "listRule": "@request.auth.id != '' && galleries_via_images.owner ?= @request.auth.id",
+    "viewRule": "@request.auth.id != '' && galleries_via_images.owner ?= @request.auth.id"
+  }, collection)
+
+  return app.save(collection)
+})
@@ -0,0 +1,39 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // add field
+  collection.fields.addAt(3, new Field({
+    "autogeneratePattern": "",
+    "hidden": false,
+    "id": "text525602420",
+    "max": 255,
+    "min": 0,
+    "name": "original_filename",
+    "pattern": "",
+    "presentable": false,
+    "primaryKey": false,
+    "required": false,
+    "system": false,
+    "type": "text"
+  }))
+
+  app.save(collection)
+
+  // best effort backfill: stored names are the original name plus a random suffix
+  const images = app.findAllRecords("images", $dbx.hashExp({ "original_filename": "" }))
+  for (const image of images) {
+    const stored = image.getString("image")
+    if (stored) {
+      image.set("original_filename", stored.replace(/_[a-z0-9]{10}(\.[^.]*)?$/i, "$1"))
+      app.saveNoValidate(image)
+    }
+  }
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // remove field
+  collection.fields.removeById("text525602420")
+
+  return app.save(collection)
+})
@@ -0,0 +1,99 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = new Collection({
+    "createRule": null,
+    "deleteRule": null,
+    "fields": [
+      {
+        "autogeneratePattern": "[a-z0-9]{15}",
+        "hidden": false,
+        "id": "text3208210256",
+        "max": 15,
+        "min": 15,
+        "name": "id",
+        "pattern": "^[a-z0-9]+$",
+        "presentable": false,
+        "primaryKey": true,
+        "required": true,
+        "system": true,
+        "type": "text"
+      },
+      {
+        "cascadeDelete": true,
+        "collectionId": "pbc_3320040697",
+        "hidden": false,
+        "id": "relation2527399127",
+        "maxSelect": 1,
+        "minSelect": 0,
+        "name": "selection",
+        "presentable": false,
+        "required": true,
+        "system": false,
+        "type": "relation"
+      },
+      {
+        "cascadeDelete": true,
+        "collectionId": "pbc_3607937828",
+        "hidden": false,
+        "id": "relation3309110367",
+        "maxSelect": 1,
+        "minSelect": 0,
+        "name": "image",
+        "presentable": false,
+        "required": true,
+        "system": false,
+        "type": "relation"
+      },
+      {
+        "autogeneratePattern": "",
+        "hidden": false,
+        "id": "text3485334036",
+        "max": 1000,
+        "min": 0,
+        "name": "note",
+        "pattern": "",
+        "presentable": false,
+        "primaryKey": false,
+        "required": false,
+        "system": false,
+        "type": "text"
+      },
+      {
+        "hidden": false,
+        "id": "autodate2990389176",
+        "name": "created",
+        "onCreate": true,
+        "onUpdate": false,
+        "presentable": false,
+        "system": false,
+        "type": "autodate"
+      },
+      {
+        "hidden": false,
+        "id": "autodate3332085495",
+        "name": "updated",
+        "onCreate": true,
+        "onUpdate": true,
+        "presentable": false,
+        "system": false,
+        "type": "autodate"
+      }
+    ],
+    "id": "pbc_2327760471",
+    "indexes": [
+      "CREATE UNIQUE INDEX `idx_selection_items_selection_image` ON `selection_items` (`selection`, `image`)"
+    ],
+    "listRule": "@request.auth.id != '' && (selection.user = @request.auth.id || selection.gallery.owner = @request.auth.id || (@collection.collaborators.gallery ?= selection.gallery && @collection.collaborators.user ?= @request.auth.id && @collection.collaborators.role ?= 'editor'))",
+    "name": "selection_items",
+    "system": false,
+    "type": "base",
+    "updateRule": null,
+    "viewRule": "@request.auth.id != '' && (selection.user = @request.auth.id || selection.gallery.owner = @request.auth.id || (@collection.collaborators.gallery ?= selection.gallery && @collection.collaborators.user ?= @request.auth.id && @collection.collaborators.role ?= 'editor'))"
+  });
+
+  return app.save(collection);
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_2327760471");
+
+  return app.delete(collection);
+})
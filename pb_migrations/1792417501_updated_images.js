@@ -0,0 +1,20 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "updateRule": "@request.auth.id != '' && galleries_via_images.owner ?= @request.auth.id && @request.body.likes:isset = false"
+  }, collection)
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "updateRule": "@request.auth.id != '' && galleries_via_images.owner ?= @request.auth.id"
+  }, collection)
+
+  return app.save(collection)
+})
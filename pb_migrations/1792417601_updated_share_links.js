@@ -0,0 +1,45 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_1025557875")
+
+  // update field
+  collection.fields.addAt(6, new Field({
+    "hidden": false,
+    "id": "select770559087",
+    "maxSelect": 4,
+    "name": "permissions",
+    "presentable": false,
+    "required": true,
+    "system": false,
+    "type": "select",
+    "values": [
+      "view",
+      "download",
+      "like",
+      "comment"
+    ]
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_1025557875")
+
+  // update field
+  collection.fields.addAt(6, new Field({
+    "hidden": false,
+    "id": "select770559087",
+    "maxSelect": 3,
+    "name": "permissions",
+    "presentable": false,
+    "required": true,
+    "system": false,
+    "type": "select",
+    "values": [
+      "view",
+      "download",
+      "like"
+    ]
+  }))
+
+  return app.save(collection)
+})
@@ -0,0 +1,165 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = new Collection({
+    "createRule": null,
+    "deleteRule": null,
+    "fields": [
+      {
+        "autogeneratePattern": "[a-z0-9]{15}",
+        "hidden": false,
+        "id": "text3208210256",
+        "max": 15,
+        "min": 15,
+        "name": "id",
+        "pattern": "^[a-z0-9]+$",
+        "presentable": false,
+        "primaryKey": true,
+        "required": true,
+        "system": true,
+        "type": "text"
+      },
+      {
+        "cascadeDelete": true,
+        "collectionId": "pbc_3598190544",
+        "hidden": false,
+        "id": "relation1194031162",
+        "maxSelect": 1,
+        "minSelect": 0,
+        "name": "gallery",
+        "presentable": false,
+        "required": true,
+        "system": false,
+        "type": "relation"
+      },
+      {
+        "cascadeDelete": true,
+        "collectionId": "pbc_3607937828",
+        "hidden": false,
+        "id": "relation3309110367",
+        "maxSelect": 1,
+        "minSelect": 0,
+        "name": "image",
+        "presentable": false,
+        "required": true,
+        "system": false,
+        "type": "relation"
+      },
+      {
+        "cascadeDelete": true,
+        "collectionId": "_pb_users_auth_",
+        "hidden": false,
+        "id": "relation2375276105",
+        "maxSelect": 1,
+        "minSelect": 0,
+        "name": "user",
+        "presentable": false,
+        "required": false,
+        "system": false,
+        "type": "relation"
+      },
+      {
+        "cascadeDelete": true,
+        "collectionId": "pbc_1025557875",
+        "hidden": false,
+        "id": "relation4010188122",
+        "maxSelect": 1,
+        "minSelect": 0,
+        "name": "share",
+        "presentable": false,
+        "required": false,
+        "system": false,
+        "type": "relation"
+      },
+      {
+        "autogeneratePattern": "",
+        "hidden": false,
+        "id": "text3133994713",
+        "max": 100,
+        "min": 0,
+        "name": "author_name",
+        "pattern": "",
+        "presentable": false,
+        "primaryKey": false,
+        "required": false,
+        "system": false,
+        "type": "text"
+      },
+      {
+        "autogeneratePattern": "",
+        "hidden": false,
+        "id": "text999008199",
+        "max": 2000,
+        "min": 0,
+        "name": "text",
+        "pattern": "",
+        "presentable": false,
+        "primaryKey": false,
+        "required": true,
+        "system": false,
+        "type": "text"
+      },
+      {
+        "hidden": false,
+        "id": "select2063623452",
+        "maxSelect": 1,
+        "name": "status",
+        "presentable": false,
+        "required": true,
+        "system": false,
+        "type": "select",
+        "values": [
+          "visible",
+          "pending",
+          "hidden"
+        ]
+      },
+      {
+        "hidden": false,
+        "id": "date3268062305",
+        "max": "",
+        "min": "",
+        "name": "edited",
+        "presentable": false,
+        "required": false,
+        "system": false,
+        "type": "date"
+      },
+      {
+        "hidden": false,
+        "id": "autodate2990389176",
+        "name": "created",
+        "onCreate": true,
+        "onUpdate": false,
+        "presentable": false,
+        "system": false,
+        "type": "autodate"
+      },
+      {
+        "hidden": false,
+        "id": "autodate3332085495",
+        "name": "updated",
+        "onCreate": true,
+        "onUpdate": true,
+        "presentable": false,
+        "system": false,
+        "type": "autodate"
+      }
+    ],
+    "id": "pbc_1300794761",
+    "indexes": [
+      "CREATE INDEX `idx_comments_image` ON `comments` (`image`, `created`)"
+    ],
+    "listRule": "@request.auth.id != '' && (gallery.owner = @request.auth.id || user = @request.auth.id || (status = 'visible' && @collection.collaborators.gallery ?= gallery && @collection.collaborators.user ?= @request.auth.id))",
+    "name": "comments",
+    "system": false,
+    "type": "base",
+    "updateRule": null,
+    "viewRule": "@request.auth.id != '' && (gallery.owner = @request.auth.id || user = @request.auth.id || (status = 'visible' && @collection.collaborators.gallery ?= gallery && @collection.collaborators.user ?= @request.auth.id))"
+  });
+
+  return app.save(collection);
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_1300794761");
+
+  return app.delete(collection);
+})
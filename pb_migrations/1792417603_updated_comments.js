@@ -0,0 +1,28 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_1300794761")
+
+  // add field
+  collection.fields.addAt(3, new Field({
+    "cascadeDelete": true,
+    "collectionId": "pbc_1300794761",
+    "hidden": false,
+    "id": "relation1032740943",
+    "maxSelect": 1,
+    "minSelect": 0,
+    "name": "parent",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "relation"
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_1300794761")
+
+  // remove field
+  collection.fields.removeById("relation1032740943")
+
+  return app.save(collection)
+})
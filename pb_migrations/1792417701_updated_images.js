@@ -0,0 +1,117 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // add field
+  collection.fields.addAt(4, new Field({
+    "autogeneratePattern": "",
+    "hidden": false,
+    "id": "text4135340389",
+    "max": 1000,
+    "min": 0,
+    "name": "caption",
+    "pattern": "",
+    "presentable": false,
+    "primaryKey": false,
+    "required": false,
+    "system": false,
+    "type": "text"
+  }))
+
+  // add field
+  collection.fields.addAt(5, new Field({
+    "autogeneratePattern": "",
+    "hidden": false,
+    "id": "text88749771",
+    "max": 1000,
+    "min": 0,
+    "name": "alt_text",
+    "pattern": "",
+    "presentable": false,
+    "primaryKey": false,
+    "required": false,
+    "system": false,
+    "type": "text"
+  }))
+
+  // add field
+  collection.fields.addAt(6, new Field({
+    "hidden": false,
+    "id": "json1874629670",
+    "maxSize": 0,
+    "name": "tags",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "json"
+  }))
+
+  // add field
+  collection.fields.addAt(7, new Field({
+    "autogeneratePattern": "",
+    "hidden": false,
+    "id": "text991751685",
+    "max": 200,
+    "min": 0,
+    "name": "camera",
+    "pattern": "",
+    "presentable": false,
+    "primaryKey": false,
+    "required": false,
+    "system": false,
+    "type": "text"
+  }))
+
+  // add field
+  collection.fields.addAt(8, new Field({
+    "autogeneratePattern": "",
+    "hidden": false,
+    "id": "text752548035",
+    "max": 200,
+    "min": 0,
+    "name": "lens",
+    "pattern": "",
+    "presentable": false,
+    "primaryKey": false,
+    "required": false,
+    "system": false,
+    "type": "text"
+  }))
+
+  // add field
+  collection.fields.addAt(9, new Field({
+    "hidden": false,
+    "id": "date3297707656",
+    "max": "",
+    "min": "",
+    "name": "taken_at",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "date"
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // remove field
+  collection.fields.removeById("text4135340389")
+
+  // remove field
+  collection.fields.removeById("text88749771")
+
+  // remove field
+  collection.fields.removeById("json1874629670")
+
+  // remove field
+  collection.fields.removeById("text991751685")
+
+  // remove field
+  collection.fields.removeById("text752548035")
+
+  // remove field
+  collection.fields.removeById("date3297707656")
+
+  return app.save(collection)
+})
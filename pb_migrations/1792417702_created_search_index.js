@@ -0,0 +1,14 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  // full-text index of galleries and images, kept in sync by record hooks
+  // and filled by the app on start or with `search reindex`
+  app.db().newQuery(
+    "CREATE VIRTUAL TABLE IF NOT EXISTS `search_index` USING fts5(" +
+      "`kind` UNINDEXED, `record_id` UNINDEXED, `gallery` UNINDEXED, " +
+      "`name`, `location`, `description`, `caption`, `alt_text`, `tags`, " +
+      "tokenize = 'unicode61 remove_diacritics 2'" +
+    ")"
+  ).execute()
+}, (app) => {
+  app.db().newQuery("DROP TABLE IF EXISTS `search_index`").execute()
+})
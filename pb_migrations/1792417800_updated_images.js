@@ -0,0 +1,24 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // add field
+  collection.fields.addAt(10, new Field({
+    "hidden": false,
+    "id": "geoPoint1424645575",
+    "name": "gps",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "geoPoint"
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // remove field
+  collection.fields.removeById("geoPoint1424645575")
+
+  return app.save(collection)
+})
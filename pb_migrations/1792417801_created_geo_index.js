@@ -0,0 +1,13 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  // R*Tree index of geotagged images, kept in sync by record hooks
+  // and filled by the app on start or with `geo reindex`
+  app.db().newQuery(
+    "CREATE VIRTUAL TABLE IF NOT EXISTS `geo_index` USING rtree(" +
+      "`id`, `min_lat`, `max_lat`, `min_lon`, `max_lon`, " +
+      "+`image` TEXT, +`gallery` TEXT" +
+    ")"
+  ).execute()
+}, (app) => {
+  app.db().newQuery("DROP TABLE IF EXISTS `geo_index`").execute()
+})
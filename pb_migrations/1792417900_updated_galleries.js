@@ -0,0 +1,62 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3598190544")
+
+  // add field
+  collection.fields.addAt(5, new Field({
+    "autogeneratePattern": "",
+    "hidden": false,
+    "id": "text1948079053",
+    "max": 255,
+    "min": 0,
+    "name": "place",
+    "pattern": "",
+    "presentable": false,
+    "primaryKey": false,
+    "required": false,
+    "system": false,
+    "type": "text"
+  }))
+
+  // add field
+  collection.fields.addAt(6, new Field({
+    "autogeneratePattern": "",
+    "hidden": false,
+    "id": "text1400097126",
+    "max": 2,
+    "min": 0,
+    "name": "country",
+    "pattern": "",
+    "presentable": false,
+    "primaryKey": false,
+    "required": false,
+    "system": false,
+    "type": "text"
+  }))
+
+  // add field
+  collection.fields.addAt(7, new Field({
+    "hidden": false,
+    "id": "geoPoint2551633526",
+    "name": "coordinates",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "geoPoint"
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3598190544")
+
+  // remove field
+  collection.fields.removeById("text1948079053")
+
+  // remove field
+  collection.fields.removeById("text1400097126")
+
+  // remove field
+  collection.fields.removeById("geoPoint2551633526")
+
+  return app.save(collection)
+})
@@ -0,0 +1,48 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // add field
+  collection.fields.addAt(11, new Field({
+    "autogeneratePattern": "",
+    "hidden": false,
+    "id": "text1948079053",
+    "max": 255,
+    "min": 0,
+    "name": "place",
+    "pattern": "",
+    "presentable": false,
+    "primaryKey": false,
+    "required": false,
+    "system": false,
+    "type": "text"
+  }))
+
+  // add field
+  collection.fields.addAt(12, new Field({
+    "autogeneratePattern": "",
+    "hidden": false,
+    "id": "text1400097126",
+    "max": 2,
+    "min": 0,
+    "name": "country",
+    "pattern": "",
+    "presentable": false,
+    "primaryKey": false,
+    "required": false,
+    "system": false,
+    "type": "text"
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // remove field
+  collection.fields.removeById("text1948079053")
+
+  // remove field
+  collection.fields.removeById("text1400097126")
+
+  return app.save(collection)
+})
@@ -0,0 +1,40 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3598190544")
+
+  // update collection data
+  unmarshal({
+    "listRule": "@request.auth.id != '' && deleted_at = '' && (owner = @request.auth.id || (@collection.collaborators.gallery ?= id && @collection.collaborators.user ?= @request.auth.id))",
+    "updateRule": "@request.auth.id != '' && deleted_at = '' && (owner = @request.auth.id || (@collection.collaborators.gallery ?= id && @collection.collaborators.user ?= @request.auth.id && @collection.collaborators.role ?= 'editor')) && (@request.body.owner:isset = false || @request.body.owner = owner) && @request.body.deleted_at:isset = false",
+    "viewRule": "@request.auth.id != '' && deleted_at = '' && (owner = @request.auth.id || (@collection.collaborators.gallery ?= id && @collection.collaborators.user ?= @request.auth.id))"
+  }, collection)
+
+  // add field
+  collection.fields.addAt(12, new Field({
+    "hidden": false,
+    "id": "date1257476049",
+    "max": "",
+    "min": "",
+    "name": "deleted_at",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "date"
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3598190544")
+
+  // update collection data
+  unmarshal({
+    "listRule": "@request.auth.id != '' && (owner = @request.auth.id || (@collection.collaborators.gallery ?= id && @collection.collaborators.user ?= @request.auth.id))",
+    "updateRule": "@request.auth.id != '' && (owner = @request.auth.id || (@collection.collaborators.gallery ?= id && @collection.collaborators.user ?= @request.auth.id && @collection.collaborators.role ?= 'editor')) && (@request.body.owner:isset = false || @request.body.owner = owner)",
+    "viewRule": "@request.auth.id != '' && (owner = @request.auth.id || (@collection.collaborators.gallery ?= id && @collection.collaborators.user ?= @request.auth.id))"
+  }, collection)
+
+  // remove field
+  collection.fields.removeById("date1257476049")
+
+  return app.save(collection)
+})
@@ -0,0 +1,58 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "listRule": "@request.auth.id != '' && deleted_at = '' && galleries_via_images.deleted_at ?= '' && (galleries_via_images.owner ?= @request.auth.id || (@collection.collaborators.gallery ?= galleries_via_images.id && @collection.collaborators.user ?= @request.auth.id))",
+    "updateRule": "@request.auth.id != '' && deleted_at = '' && galleries_via_images.owner ?= @request.auth.id && @request.body.likes:isset = false && @request.body.deleted_at:isset = false && @request.body.deleted_from:isset = false",
+    "viewRule": "@request.auth.id != '' && deleted_at = '' && galleries_via_images.deleted_at ?= '' && (galleries_via_images.owner ?= @request.auth.id || (@collection.collaborators.gallery ?= galleries_via_images.id && @collection.collaborators.user ?= @request.auth.id))"
+  }, collection)
+
+  // add field
+  collection.fields.addAt(13, new Field({
+    "hidden": false,
+    "id": "date1257476049",
+    "max": "",
+    "min": "",
+    "name": "deleted_at",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "date"
+  }))
+
+  // add field
+  collection.fields.addAt(14, new Field({
+    "cascadeDelete": true,
+    "collectionId": "pbc_3598190544",
+    "hidden": false,
+    "id": "relation3972078693",
+    "maxSelect": 1,
+    "minSelect": 0,
+    "name": "deleted_from",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "relation"
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "listRule": "@request.auth.id != '' && (galleries_via_images.owner ?= @request.auth.id || (@collection.collaborators.gallery ?= galleries_via_images.id && @collection.collaborators.user ?= @request.auth.id))",
+    "updateRule": "@request.auth.id != '' && galleries_via_images.owner ?= @request.auth.id && @request.body.likes:isset = false",
+    "viewRule": "@request.auth.id != '' && (galleries_via_images.owner ?= @request.auth.id || (@collection.collaborators.gallery ?= galleries_via_images.id && @collection.collaborators.user ?= @request.auth.id))"
+  }, collection)
+
+  // remove field
+  collection.fields.removeById("date1257476049")
+
+  // remove field
+  collection.fields.removeById("relation3972078693")
+
+  return app.save(collection)
+})
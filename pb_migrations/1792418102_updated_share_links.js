@@ -0,0 +1,70 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_1025557875")
+
+  // update collection data
+  unmarshal({
+    "listRule": "@request.auth.id != '' && (gallery.owner = @request.auth.id || album.owner = @request.auth.id)",
+    "viewRule": "@request.auth.id != '' && (gallery.owner = @request.auth.id || album.owner = @request.auth.id)"
+  }, collection)
+
+  // update field
+  collection.fields.addAt(1, new Field({
+    "cascadeDelete": true,
+    "collectionId": "pbc_3598190544",
+    "hidden": false,
+    "id": "relation1194031162",
+    "maxSelect": 1,
+    "minSelect": 0,
+    "name": "gallery",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "relation"
+  }))
+
+  // add field
+  collection.fields.addAt(2, new Field({
+    "cascadeDelete": true,
+    "collectionId": "pbc_1165578901",
+    "hidden": false,
+    "id": "relation966291011",
+    "maxSelect": 1,
+    "minSelect": 0,
+    "name": "album",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "relation"
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_1025557875")
+
+  // update collection data
+  unmarshal({
+    "listRule": "@request.auth.id != '' && gallery.owner = @request.auth.id",
+    "viewRule": "@request.auth.id != '' && gallery.owner = @request.auth.id"
+  }, collection)
+
+  // update field
+  collection.fields.addAt(1, new Field({
+    "cascadeDelete": true,
+    "collectionId": "pbc_3598190544",
+    "hidden": false,
+    "id": "relation1194031162",
+    "maxSelect": 1,
+    "minSelect": 0,
+    "name": "gallery",
+    "presentable": false,
+    "required": true,
+    "system": false,
+    "type": "relation"
+  }))
+
+  // remove field
+  collection.fields.removeById("relation966291011")
+
+  return app.save(collection)
+})
@@ -0,0 +1,36 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3598190544")
+
+  // update collection data
+  unmarshal({
+    "updateRule": "@request.auth.id != '' && deleted_at = '' && (owner = @request.auth.id || (@collection.collaborators.gallery ?= id && @collection.collaborators.user ?= @request.auth.id && @collection.collaborators.role ?= 'editor')) && (@request.body.owner:isset = false || @request.body.owner = owner) && @request.body.deleted_at:isset = false && (@request.body.public:isset = false || owner = @request.auth.id)",
+    "viewRule": "deleted_at = '' && (public = true || (@request.auth.id != '' && (owner = @request.auth.id || (@collection.collaborators.gallery ?= id && @collection.collaborators.user ?= @request.auth.id))))"
+  }, collection)
+
+  // add field
+  collection.fields.addAt(13, new Field({
+    "hidden": false,
+    "id": "bool1001664029",
+    "name": "public",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "bool"
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3598190544")
+
+  // update collection data
+  unmarshal({
+    "updateRule": "@request.auth.id != '' && deleted_at = '' && (owner = @request.auth.id || (@collection.collaborators.gallery ?= id && @collection.collaborators.user ?= @request.auth.id && @collection.collaborators.role ?= 'editor')) && (@request.body.owner:isset = false || @request.body.owner = owner) && @request.body.deleted_at:isset = false",
+    "viewRule": "@request.auth.id != '' && deleted_at = '' && (owner = @request.auth.id || (@collection.collaborators.gallery ?= id && @collection.collaborators.user ?= @request.auth.id))"
+  }, collection)
+
+  // remove field
+  collection.fields.removeById("bool1001664029")
+
+  return app.save(collection)
+})
@@ -0,0 +1,20 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "viewRule": "deleted_at = '' && galleries_via_images.deleted_at ?= '' && (galleries_via_images.public ?= true || (@request.auth.id != '' && (galleries_via_images.owner ?= @request.auth.id || (@collection.collaborators.gallery ?= galleries_via_images.id && @collection.collaborators.user ?= @request.auth.id))))"
+  }, collection)
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "viewRule": "@request.auth.id != '' && deleted_at = '' && galleries_via_images.deleted_at ?= '' && (galleries_via_images.owner ?= @request.auth.id || (@collection.collaborators.gallery ?= galleries_via_images.id && @collection.collaborators.user ?= @request.auth.id))"
+  }, collection)
+
+  return app.save(collection)
+})
@@ -0,0 +1,42 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("_pb_users_auth_")
+
+  // update collection data
+  unmarshal({
+    "createRule": "@request.body.plan:isset = false",
+    "updateRule": "id = @request.auth.id && @request.body.plan:isset = false"
+  }, collection)
+
+  // add field
+  collection.fields.addAt(9, new Field({
+    "hidden": false,
+    "id": "select3713686397",
+    "maxSelect": 1,
+    "name": "plan",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "select",
+    "values": [
+      "free",
+      "pro",
+      "enterprise"
+    ]
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("_pb_users_auth_")
+
+  // update collection data
+  unmarshal({
+    "createRule": "",
+    "updateRule": "id = @request.auth.id"
+  }, collection)
+
+  // remove field
+  collection.fields.removeById("select3713686397")
+
+  return app.save(collection)
+})
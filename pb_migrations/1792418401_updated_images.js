@@ -0,0 +1,37 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "updateRule": "@request.auth.id != '' && deleted_at = '' && galleries_via_images.owner ?= @request.auth.id && @request.body.likes:isset = false && @request.body.deleted_at:isset = false && @request.body.deleted_from:isset = false && @request.body.size:isset = false"
+  }, collection)
+
+  // add field
+  collection.fields.addAt(2, new Field({
+    "hidden": false,
+    "id": "number4156564586",
+    "max": null,
+    "min": 0,
+    "name": "size",
+    "onlyInt": true,
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "number"
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "updateRule": "@request.auth.id != '' && deleted_at = '' && galleries_via_images.owner ?= @request.auth.id && @request.body.likes:isset = false && @request.body.deleted_at:isset = false && @request.body.deleted_from:isset = false"
+  }, collection)
+
+  // remove field
+  collection.fields.removeById("number4156564586")
+
+  return app.save(collection)
+})
@@ -0,0 +1,39 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "updateRule": "@request.auth.id != '' && deleted_at = '' && galleries_via_images.owner ?= @request.auth.id && @request.body.likes:isset = false && @request.body.deleted_at:isset = false && @request.body.deleted_from:isset = false && @request.body.size:isset = false && @request.body.sha256:isset = false"
+  }, collection)
+
+  // add field
+  collection.fields.addAt(3, new Field({
+    "autogeneratePattern": "",
+    "hidden": false,
+    "id": "text1556616439",
+    "max": 64,
+    "min": 0,
+    "name": "sha256",
+    "pattern": "^[a-f0-9]*$",
+    "presentable": false,
+    "primaryKey": false,
+    "required": false,
+    "system": false,
+    "type": "text"
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "updateRule": "@request.auth.id != '' && deleted_at = '' && galleries_via_images.owner ?= @request.auth.id && @request.body.likes:isset = false && @request.body.deleted_at:isset = false && @request.body.deleted_from:isset = false && @request.body.size:isset = false"
+  }, collection)
+
+  // remove field
+  collection.fields.removeById("text1556616439")
+
+  return app.save(collection)
+})
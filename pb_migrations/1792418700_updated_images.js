@@ -0,0 +1,90 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "updateRule": "@request.auth.id != '' && deleted_at = '' && galleries_via_images.owner ?= @request.auth.id && @request.body.likes:isset = false && @request.body.deleted_at:isset = false && @request.body.deleted_from:isset = false && @request.body.size:isset = false && @request.body.sha256:isset = false && @request.body.enhanced:isset = false && @request.body.original:isset = false && @request.body.enhancement:isset = false"
+  }, collection)
+
+  // add field
+  collection.fields.addAt(2, new Field({
+    "hidden": false,
+    "id": "file2775079470",
+    "maxSelect": 1,
+    "maxSize": 0,
+    "mimeTypes": [
+      "image/jpeg",
+      "image/png",
+      "image/svg+xml",
+      "image/gif",
+      "image/webp"
+    ],
+    "name": "enhanced",
+    "presentable": false,
+    "protected": false,
+    "required": false,
+    "system": false,
+    "thumbs": [],
+    "type": "file"
+  }))
+
+  // add field
+  collection.fields.addAt(3, new Field({
+    "hidden": false,
+    "id": "file796029061",
+    "maxSelect": 1,
+    "maxSize": 0,
+    "mimeTypes": [
+      "image/jpeg",
+      "image/png",
+      "image/svg+xml",
+      "image/gif",
+      "image/webp"
+    ],
+    "name": "original",
+    "presentable": false,
+    "protected": false,
+    "required": false,
+    "system": false,
+    "thumbs": [],
+    "type": "file"
+  }))
+
+  // add field
+  collection.fields.addAt(6, new Field({
+    "hidden": false,
+    "id": "select1370315684",
+    "maxSelect": 1,
+    "name": "enhancement",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "select",
+    "values": [
+      "pending",
+      "approved",
+      "rejected"
+    ]
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "updateRule": "@request.auth.id != '' && deleted_at = '' && galleries_via_images.owner ?= @request.auth.id && @request.body.likes:isset = false && @request.body.deleted_at:isset = false && @request.body.deleted_from:isset = false && @request.body.size:isset = false && @request.body.sha256:isset = false"
+  }, collection)
+
+  // remove field
+  collection.fields.removeById("file2775079470")
+
+  // remove field
+  collection.fields.removeById("file796029061")
+
+  // remove field
+  collection.fields.removeById("select1370315684")
+
+  return app.save(collection)
+})
@@ -0,0 +1,133 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_1757048924")
+
+  // update collection data
+  unmarshal({
+    "indexes": [
+      "CREATE UNIQUE INDEX `idx_workflow_runs_instance` ON `workflow_runs` (`instance_id`)",
+      "CREATE INDEX `idx_workflow_runs_user` ON `workflow_runs` (`user`, `created`)",
+      "CREATE INDEX `idx_workflow_runs_gallery` ON `workflow_runs` (`gallery`, `created`)",
+      "CREATE INDEX `idx_workflow_runs_status` ON `workflow_runs` (`status`)"
+    ]
+  }, collection)
+
+  // update field
+  collection.fields.addAt(1, new Field({
+    "cascadeDelete": true,
+    "collectionId": "_pb_users_auth_",
+    "hidden": false,
+    "id": "relation2375276105",
+    "maxSelect": 1,
+    "minSelect": 0,
+    "name": "user",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "relation"
+  }))
+
+  // add field
+  collection.fields.addAt(4, new Field({
+    "autogeneratePattern": "",
+    "hidden": false,
+    "id": "text1460819268",
+    "max": 0,
+    "min": 0,
+    "name": "execution_id",
+    "pattern": "",
+    "presentable": false,
+    "primaryKey": false,
+    "required": false,
+    "system": false,
+    "type": "text"
+  }))
+
+  // add field
+  collection.fields.addAt(5, new Field({
+    "cascadeDelete": false,
+    "collectionId": "pbc_3598190544",
+    "hidden": false,
+    "id": "relation1194031162",
+    "maxSelect": 1,
+    "minSelect": 0,
+    "name": "gallery",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "relation"
+  }))
+
+  // add field
+  collection.fields.addAt(6, new Field({
+    "hidden": false,
+    "id": "select2063623452",
+    "maxSelect": 1,
+    "name": "status",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "select",
+    "values": [
+      "running",
+      "waiting",
+      "completed",
+      "failed",
+      "canceled"
+    ]
+  }))
+
+  // add field
+  collection.fields.addAt(7, new Field({
+    "hidden": false,
+    "id": "date2790239036",
+    "max": "",
+    "min": "",
+    "name": "finished",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "date"
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_1757048924")
+
+  // update collection data
+  unmarshal({
+    "indexes": [
+      "CREATE UNIQUE INDEX `idx_workflow_runs_instance` ON `workflow_runs` (`instance_id`)",
+      "CREATE INDEX `idx_workflow_runs_user` ON `workflow_runs` (`user`, `created`)"
+    ]
+  }, collection)
+
+  // update field
+  collection.fields.addAt(1, new Field({
+    "cascadeDelete": true,
+    "collectionId": "_pb_users_auth_",
+    "hidden": false,
+    "id": "relation2375276105",
+    "maxSelect": 1,
+    "minSelect": 0,
+    "name": "user",
+    "presentable": false,
+    "required": true,
+    "system": false,
+    "type": "relation"
+  }))
+
+  // remove field
+  collection.fields.removeById("text1460819268")
+
+  // remove field
+  collection.fields.removeById("relation1194031162")
+
+  // remove field
+  collection.fields.removeById("select2063623452")
+
+  // remove field
+  collection.fields.removeById("date2790239036")
+
+  return app.save(collection)
+})
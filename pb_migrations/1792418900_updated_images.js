@@ -0,0 +1,54 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "updateRule": "@request.auth.id != '' && deleted_at = '' && galleries_via_images.owner ?= @request.auth.id && @request.body.likes:isset = false && @request.body.deleted_at:isset = false && @request.body.deleted_from:isset = false && @request.body.size:isset = false && @request.body.sha256:isset = false && @request.body.enhanced:isset = false && @request.body.original:isset = false && @request.body.enhancement:isset = false && @request.body.enhanced_size:isset = false && @request.body.original_size:isset = false"
+  }, collection)
+
+  // add field
+  collection.fields.addAt(5, new Field({
+    "hidden": false,
+    "id": "number3843094758",
+    "max": null,
+    "min": 0,
+    "name": "enhanced_size",
+    "onlyInt": true,
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "number"
+  }))
+
+  // add field
+  collection.fields.addAt(6, new Field({
+    "hidden": false,
+    "id": "number4256517980",
+    "max": null,
+    "min": 0,
+    "name": "original_size",
+    "onlyInt": true,
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "number"
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "updateRule": "@request.auth.id != '' && deleted_at = '' && galleries_via_images.owner ?= @request.auth.id && @request.body.likes:isset = false && @request.body.deleted_at:isset = false && @request.body.deleted_from:isset = false && @request.body.size:isset = false && @request.body.sha256:isset = false && @request.body.enhanced:isset = false && @request.body.original:isset = false && @request.body.enhancement:isset = false"
+  }, collection)
+
+  // remove field
+  collection.fields.removeById("number3843094758")
+
+  // remove field
+  collection.fields.removeById("number4256517980")
+
+  return app.save(collection)
+})
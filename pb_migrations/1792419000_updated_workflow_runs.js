@@ -0,0 +1,60 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_1757048924")
+
+  // update field
+  collection.fields.addAt(6, new Field({
+    "hidden": false,
+    "id": "select2063623452",
+    "maxSelect": 1,
+    "name": "status",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "select",
+    "values": [
+      "running",
+      "waiting",
+      "completed",
+      "failed",
+      "canceled",
+      "unknown"
+    ]
+  }))
+
+  app.save(collection)
+
+  // runs recorded before their execution id was stored can't be looked up
+  // in the workflow backend
+  app.db().newQuery(
+    "UPDATE `workflow_runs` SET `status` = 'unknown' " +
+    "WHERE `execution_id` = '' AND `status` IN ('', 'running', 'waiting')"
+  ).execute()
+}, (app) => {
+  app.db().newQuery(
+    "UPDATE `workflow_runs` SET `status` = '' WHERE `status` = 'unknown'"
+  ).execute()
+
+  const collection = app.findCollectionByNameOrId("pbc_1757048924")
+
+  // update field
+  collection.fields.addAt(6, new Field({
+    "hidden": false,
+    "id": "select2063623452",
+    "maxSelect": 1,
+    "name": "status",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "select",
+    "values": [
+      "running",
+      "waiting",
+      "completed",
+      "failed",
+      "canceled"
+    ]
+  }))
+
+  return app.save(collection)
+})
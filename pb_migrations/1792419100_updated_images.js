@@ -0,0 +1,38 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "updateRule": "@request.auth.id != '' && deleted_at = '' && galleries_via_images.owner ?= @request.auth.id && @request.body.likes:isset = false && @request.body.deleted_at:isset = false && @request.body.deleted_from:isset = false && @request.body.size:isset = false && @request.body.sha256:isset = false && @request.body.enhanced:isset = false && @request.body.original:isset = false && @request.body.enhancement:isset = false && @request.body.enhanced_size:isset = false && @request.body.original_size:isset = false && @request.body.uploader:isset = false"
+  }, collection)
+
+  // add field
+  collection.fields.addAt(7, new Field({
+    "cascadeDelete": false,
+    "collectionId": "_pb_users_auth_",
+    "hidden": true,
+    "id": "relation1668006755",
+    "maxSelect": 1,
+    "minSelect": 0,
+    "name": "uploader",
+    "presentable": false,
+    "required": false,
+    "system": false,
+    "type": "relation"
+  }))
+
+  return app.save(collection)
+}, (app) => {
+  const collection = app.findCollectionByNameOrId("pbc_3607937828")
+
+  // update collection data
+  unmarshal({
+    "updateRule": "@request.auth.id != '' && deleted_at = '' && galleries_via_images.owner ?= @request.auth.id && @request.body.likes:isset = false && @request.body.deleted_at:isset = false && @request.body.deleted_from:isset = false && @request.body.size:isset = false && @request.body.sha256:isset = false && @request.body.enhanced:isset = false && @request.body.original:isset = false && @request.body.enhancement:isset = false && @request.body.enhanced_size:isset = false && @request.body.original_size:isset = false"
+  }, collection)
+
+  // remove field
+  collection.fields.removeById("relation1668006755")
+
+  return app.save(collection)
+})
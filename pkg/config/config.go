@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 // Config holds all application configuration
@@ -17,17 +18,137 @@ type Config struct {
 	Workflow struct {
 		DefaultTimeout int // in seconds
 	}
+	Processing struct {
+		Concurrency int      // image activities a gallery processing workflow runs at once
+		BatchSize   int      // images per child workflow
+		Derivatives []string // IIIF sizes rendered into the tile cache, e.g. "!400,400"
+	}
+	Enhancement struct {
+		ReviewTimeout int // in seconds, unreviewed enhancements are rejected after it
+	}
+	IIIF struct {
+		CacheDir      string // defaults to pb_data/iiif_cache
+		CacheMaxBytes int64
+	}
+	Uploads struct {
+		URLExpiry int // presigned upload URL lifetime in seconds
+	}
+	Shares struct {
+		DefaultExpiry     int // share link lifetime in seconds when none is given
+		FileTokenDuration int // lifetime of file access tokens in seconds
+		MaxAttempts       int // failed password attempts before a link is locked
+		LockoutDuration   int // in seconds
+	}
+	Comments struct {
+		EditWindow   int // seconds after posting the author can edit a comment
+		DeleteWindow int // seconds after posting the author can delete a comment
+		RateLimit    int // comments a client can post per rate window
+		RateWindow   int // in seconds
+	}
+	Cleanup struct {
+		Schedule    string // cron expression of the nightly cleanup, empty to disable it
+		GracePeriod int    // seconds before unreferenced records and files are collected
+		BatchSize   int    // records or files deleted per activity
+		DryRun      bool   // scheduled runs only report what they would delete
+	}
+	Trash struct {
+		Retention int    // seconds deleted galleries and images stay in the trash
+		Schedule  string // cron expression of the trash purge, empty to disable it
+	}
+	Embed struct {
+		FrameAncestors string // CSP frame-ancestors sources allowed to embed galleries
+		Width          int    // default width of oEmbed iframes in pixels
+		Height         int    // default height of oEmbed iframes in pixels
+	}
+	Analytics struct {
+		FlushInterval int // seconds view counts are buffered before they are written
+		BufferSize    int // counters buffered before an early write
+	}
+	Geocode struct {
+		File          string // GeoNames dump used instead of the embedded places dataset
+		ReverseRadius int    // meters within which image GPS positions get a place name
+	}
+	Plans struct {
+		Default string                // plan of users without one
+		Limits  map[string]PlanLimits // limits by plan id
+	}
+	Stripe struct {
+		SecretKey          string
+		WebhookSecret      string            // signing secret of the webhook endpoint
+		APIBase            string            // e.g. http://localhost:12111 for stripe-mock
+		Prices             map[string]string // price ids by plan id
+		SuccessURL         string            // defaults to the billing page of the app
+		CancelURL          string
+		SignatureTolerance int // seconds a webhook signature stays valid
+	}
+	Storage struct {
+		// S3 is the target used by the storage migrate command
+		S3 struct {
+			Bucket         string
+			Region         string
+			Endpoint       string
+			AccessKey      string
+			Secret         string
+			ForcePathStyle bool
+		}
+	}
+}
+
+// PlanLimits holds the quotas of a pricing plan, zero means unlimited
+type PlanLimits struct {
+	StorageBytes       int64 `json:"storage_bytes"`
+	Galleries          int   `json:"galleries"`
+	ImagesPerGallery   int   `json:"images_per_gallery"` // Gallery.MaxImages still applies
+	WorkflowRunsPerDay int   `json:"workflow_runs_per_day"`
 }
 
 // New creates a new configuration with defaults and environment overrides
 func New() *Config {
 	cfg := &Config{}
-	
+
 	// Set defaults
 	cfg.WorkflowDB.Name = "workflow.db"
 	cfg.Gallery.MaxFileSize = 100 * 1024 * 1024 // 100MB
 	cfg.Gallery.MaxImages = 100
 	cfg.Workflow.DefaultTimeout = 300 // 5 minutes
+	cfg.Processing.Concurrency = 4
+	cfg.Processing.BatchSize = 100
+	// grid thumbnails and the embed page
+	cfg.Processing.Derivatives = []string{"!400,400", "!1600,1600"}
+	cfg.Enhancement.ReviewTimeout = 7 * 24 * 3600 // 7 days
+	cfg.IIIF.CacheMaxBytes = 512 * 1024 * 1024    // 512MB
+	cfg.Uploads.URLExpiry = 3600                  // 1 hour
+	cfg.Shares.DefaultExpiry = 30 * 24 * 3600     // 30 days
+	cfg.Shares.FileTokenDuration = 900            // 15 minutes
+	cfg.Shares.MaxAttempts = 5
+	cfg.Shares.LockoutDuration = 900 // 15 minutes
+	cfg.Comments.EditWindow = 900    // 15 minutes
+	cfg.Comments.DeleteWindow = 3600 // 1 hour
+	cfg.Comments.RateLimit = 10
+	cfg.Comments.RateWindow = 60        // 1 minute
+	cfg.Cleanup.Schedule = "0 3 * * *"  // 3am every night
+	cfg.Cleanup.GracePeriod = 24 * 3600 // 1 day
+	cfg.Cleanup.BatchSize = 100
+	cfg.Trash.Retention = 30 * 24 * 3600 // 30 days
+	cfg.Trash.Schedule = "30 3 * * *"    // 3:30am every night
+	cfg.Embed.FrameAncestors = "*"       // any site
+	cfg.Embed.Width = 800
+	cfg.Embed.Height = 600
+	cfg.Analytics.FlushInterval = 10
+	cfg.Analytics.BufferSize = 1000
+	cfg.Geocode.ReverseRadius = 25000 // 25 km
+	cfg.Plans.Default = "free"
+	cfg.Plans.Limits = map[string]PlanLimits{
+		"free":       {StorageBytes: 1 << 30, Galleries: 5, ImagesPerGallery: 25, WorkflowRunsPerDay: 10},      // 1GB
+		"pro":        {StorageBytes: 50 << 30, Galleries: 100, ImagesPerGallery: 100, WorkflowRunsPerDay: 100}, // 50GB
+		"enterprise": {StorageBytes: 500 << 30, Galleries: 0, ImagesPerGallery: 0, WorkflowRunsPerDay: 1000},   // 500GB
+	}
+	cfg.Stripe.APIBase = "https://api.stripe.com"
+	cfg.Stripe.Prices = map[string]string{
+		"pro":        "price_1NkdZCHMjzZ8mGZnRSjUm4yA",
+		"enterprise": "price_1Nkda2HMjzZ8mGZn4sKvbDAV",
+	}
+	cfg.Stripe.SignatureTolerance = 300 // 5 minutes
 
 	// Override with environment variables if present
 	if dbName := os.Getenv("WORKFLOW_DB_NAME"); dbName != "" {
@@ -52,5 +173,229 @@ func New() *Config {
 		}
 	}
 
+	if concurrency := os.Getenv("PROCESSING_CONCURRENCY"); concurrency != "" {
+		if count, err := strconv.Atoi(concurrency); err == nil {
+			cfg.Processing.Concurrency = count
+		}
+	}
+
+	if batch := os.Getenv("PROCESSING_BATCH_SIZE"); batch != "" {
+		if size, err := strconv.Atoi(batch); err == nil {
+			cfg.Processing.BatchSize = size
+		}
+	}
+
+	if derivatives, ok := os.LookupEnv("PROCESSING_DERIVATIVES"); ok {
+		cfg.Processing.Derivatives = strings.Fields(derivatives)
+	}
+
+	if timeout := os.Getenv("ENHANCEMENT_REVIEW_TIMEOUT"); timeout != "" {
+		if t, err := strconv.Atoi(timeout); err == nil {
+			cfg.Enhancement.ReviewTimeout = t
+		}
+	}
+
+	if cacheDir := os.Getenv("IIIF_CACHE_DIR"); cacheDir != "" {
+		cfg.IIIF.CacheDir = cacheDir
+	}
+
+	if cacheSize := os.Getenv("IIIF_CACHE_MAX_BYTES"); cacheSize != "" {
+		if size, err := strconv.ParseInt(cacheSize, 10, 64); err == nil {
+			cfg.IIIF.CacheMaxBytes = size
+		}
+	}
+
+	if expiry := os.Getenv("UPLOAD_URL_EXPIRY"); expiry != "" {
+		if t, err := strconv.Atoi(expiry); err == nil {
+			cfg.Uploads.URLExpiry = t
+		}
+	}
+
+	if expiry := os.Getenv("SHARE_DEFAULT_EXPIRY"); expiry != "" {
+		if t, err := strconv.Atoi(expiry); err == nil {
+			cfg.Shares.DefaultExpiry = t
+		}
+	}
+
+	if duration := os.Getenv("SHARE_FILE_TOKEN_DURATION"); duration != "" {
+		if t, err := strconv.Atoi(duration); err == nil {
+			cfg.Shares.FileTokenDuration = t
+		}
+	}
+
+	if attempts := os.Getenv("SHARE_MAX_ATTEMPTS"); attempts != "" {
+		if count, err := strconv.Atoi(attempts); err == nil {
+			cfg.Shares.MaxAttempts = count
+		}
+	}
+
+	if lockout := os.Getenv("SHARE_LOCKOUT_DURATION"); lockout != "" {
+		if t, err := strconv.Atoi(lockout); err == nil {
+			cfg.Shares.LockoutDuration = t
+		}
+	}
+
+	if window := os.Getenv("COMMENT_EDIT_WINDOW"); window != "" {
+		if t, err := strconv.Atoi(window); err == nil {
+			cfg.Comments.EditWindow = t
+		}
+	}
+
+	if window := os.Getenv("COMMENT_DELETE_WINDOW"); window != "" {
+		if t, err := strconv.Atoi(window); err == nil {
+			cfg.Comments.DeleteWindow = t
+		}
+	}
+
+	if limit := os.Getenv("COMMENT_RATE_LIMIT"); limit != "" {
+		if count, err := strconv.Atoi(limit); err == nil {
+			cfg.Comments.RateLimit = count
+		}
+	}
+
+	if window := os.Getenv("COMMENT_RATE_WINDOW"); window != "" {
+		if t, err := strconv.Atoi(window); err == nil {
+			cfg.Comments.RateWindow = t
+		}
+	}
+
+	if schedule, ok := os.LookupEnv("CLEANUP_SCHEDULE"); ok {
+		cfg.Cleanup.Schedule = schedule
+	}
+
+	if grace := os.Getenv("CLEANUP_GRACE_PERIOD"); grace != "" {
+		if t, err := strconv.Atoi(grace); err == nil {
+			cfg.Cleanup.GracePeriod = t
+		}
+	}
+
+	if batch := os.Getenv("CLEANUP_BATCH_SIZE"); batch != "" {
+		if size, err := strconv.Atoi(batch); err == nil {
+			cfg.Cleanup.BatchSize = size
+		}
+	}
+
+	if dryRun := os.Getenv("CLEANUP_DRY_RUN"); dryRun != "" {
+		if b, err := strconv.ParseBool(dryRun); err == nil {
+			cfg.Cleanup.DryRun = b
+		}
+	}
+
+	if retention := os.Getenv("TRASH_RETENTION"); retention != "" {
+		if t, err := strconv.Atoi(retention); err == nil {
+			cfg.Trash.Retention = t
+		}
+	}
+
+	if schedule, ok := os.LookupEnv("TRASH_PURGE_SCHEDULE"); ok {
+		cfg.Trash.Schedule = schedule
+	}
+
+	if ancestors := os.Getenv("EMBED_FRAME_ANCESTORS"); ancestors != "" {
+		cfg.Embed.FrameAncestors = ancestors
+	}
+
+	if width := os.Getenv("EMBED_WIDTH"); width != "" {
+		if w, err := strconv.Atoi(width); err == nil {
+			cfg.Embed.Width = w
+		}
+	}
+
+	if height := os.Getenv("EMBED_HEIGHT"); height != "" {
+		if h, err := strconv.Atoi(height); err == nil {
+			cfg.Embed.Height = h
+		}
+	}
+
+	if interval := os.Getenv("ANALYTICS_FLUSH_INTERVAL"); interval != "" {
+		if t, err := strconv.Atoi(interval); err == nil {
+			cfg.Analytics.FlushInterval = t
+		}
+	}
+
+	if size := os.Getenv("ANALYTICS_BUFFER_SIZE"); size != "" {
+		if count, err := strconv.Atoi(size); err == nil {
+			cfg.Analytics.BufferSize = count
+		}
+	}
+
+	cfg.Geocode.File = os.Getenv("GEONAMES_FILE")
+
+	if radius := os.Getenv("GEOCODE_REVERSE_RADIUS"); radius != "" {
+		if meters, err := strconv.Atoi(radius); err == nil {
+			cfg.Geocode.ReverseRadius = meters
+		}
+	}
+
+	if plan := os.Getenv("PLAN_DEFAULT"); plan != "" {
+		cfg.Plans.Default = plan
+	}
+
+	// PLAN_<ID>_STORAGE_BYTES, PLAN_<ID>_GALLERIES, PLAN_<ID>_IMAGES_PER_GALLERY and
+	// PLAN_<ID>_WORKFLOW_RUNS_PER_DAY override the limits of each plan
+	for id, limits := range cfg.Plans.Limits {
+		prefix := "PLAN_" + strings.ToUpper(id) + "_"
+
+		if storage := os.Getenv(prefix + "STORAGE_BYTES"); storage != "" {
+			if size, err := strconv.ParseInt(storage, 10, 64); err == nil {
+				limits.StorageBytes = size
+			}
+		}
+
+		if galleries := os.Getenv(prefix + "GALLERIES"); galleries != "" {
+			if count, err := strconv.Atoi(galleries); err == nil {
+				limits.Galleries = count
+			}
+		}
+
+		if images := os.Getenv(prefix + "IMAGES_PER_GALLERY"); images != "" {
+			if count, err := strconv.Atoi(images); err == nil {
+				limits.ImagesPerGallery = count
+			}
+		}
+
+		if runs := os.Getenv(prefix + "WORKFLOW_RUNS_PER_DAY"); runs != "" {
+			if count, err := strconv.Atoi(runs); err == nil {
+				limits.WorkflowRunsPerDay = count
+			}
+		}
+
+		cfg.Plans.Limits[id] = limits
+	}
+
+	cfg.Stripe.SecretKey = os.Getenv("STRIPE_SECRET_KEY")
+	cfg.Stripe.WebhookSecret = os.Getenv("STRIPE_WEBHOOK_SECRET")
+	cfg.Stripe.SuccessURL = os.Getenv("STRIPE_SUCCESS_URL")
+	cfg.Stripe.CancelURL = os.Getenv("STRIPE_CANCEL_URL")
+
+	if base := os.Getenv("STRIPE_API_BASE"); base != "" {
+		cfg.Stripe.APIBase = base
+	}
+
+	// STRIPE_PRICE_<ID> sets the price of a plan, plans without one can't be bought
+	for id := range cfg.Plans.Limits {
+		if price, ok := os.LookupEnv("STRIPE_PRICE_" + strings.ToUpper(id)); ok {
+			cfg.Stripe.Prices[id] = price
+		}
+	}
+
+	if tolerance := os.Getenv("STRIPE_SIGNATURE_TOLERANCE"); tolerance != "" {
+		if t, err := strconv.Atoi(tolerance); err == nil {
+			cfg.Stripe.SignatureTolerance = t
+		}
+	}
+
+	cfg.Storage.S3.Bucket = os.Getenv("S3_BUCKET")
+	cfg.Storage.S3.Region = os.Getenv("S3_REGION")
+	cfg.Storage.S3.Endpoint = os.Getenv("S3_ENDPOINT")
+	cfg.Storage.S3.AccessKey = os.Getenv("S3_ACCESS_KEY")
+	cfg.Storage.S3.Secret = os.Getenv("S3_SECRET")
+
+	if pathStyle := os.Getenv("S3_FORCE_PATH_STYLE"); pathStyle != "" {
+		if b, err := strconv.ParseBool(pathStyle); err == nil {
+			cfg.Storage.S3.ForcePathStyle = b
+		}
+	}
+
 	return cfg
-}
\ No newline at end of file
+}
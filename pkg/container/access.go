@@ -0,0 +1,203 @@
+package container
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// GalleryClient identifies a visitor of a gallery: a signed in user or the
+// holder of a share link file token
+type GalleryClient struct {
+	Info  *core.RequestInfo
+	Share *ShareGrant
+}
+
+// FindImageGallery returns the gallery referencing the image, or nil if there is none
+func FindImageGallery(app core.App, imageID string) *core.Record {
+	gallery, err := app.FindFirstRecordByFilter("galleries", "images.id ?= {:id}", dbx.Params{"id": imageID})
+	if err != nil {
+		return nil
+	}
+	return gallery
+}
+
+// CheckGalleryAccess enforces the view rule of the galleries collection
+func CheckGalleryAccess(app core.App, info *core.RequestInfo, gallery *core.Record) error {
+	// superusers pass every rule, trashed galleries are hidden from them too
+	if IsTrashed(gallery) {
+		return errors.NotFound("Gallery not found")
+	}
+
+	ok, err := app.CanAccessRecord(gallery, info, gallery.Collection().ViewRule)
+	if err != nil {
+		return errors.InternalError("Failed to check gallery access", err)
+	}
+	if !ok {
+		return errors.Forbidden("You are not allowed to access this gallery")
+	}
+	return nil
+}
+
+// Gallery roles. Owners can do everything, collaborator roles come from the collaborators collection.
+const (
+	RoleOwner       = "owner"
+	RoleEditor      = "editor"
+	RoleContributor = "contributor"
+	RoleViewer      = "viewer"
+)
+
+// GalleryRole returns the role of the user in the gallery, or an empty string if they have none
+func GalleryRole(app core.App, auth *core.Record, gallery *core.Record) string {
+	if auth == nil {
+		return ""
+	}
+
+	if gallery.GetString("owner") == auth.Id {
+		return RoleOwner
+	}
+
+	collaborator, err := app.FindFirstRecordByFilter(
+		"collaborators",
+		"gallery = {:gallery} && user = {:user}",
+		dbx.Params{"gallery": gallery.Id, "user": auth.Id},
+	)
+	if err != nil {
+		return ""
+	}
+
+	return collaborator.GetString("role")
+}
+
+// CheckGalleryRole allows superusers and users holding one of the roles in the
+// gallery. Trashed galleries can only be restored, see TrashService.
+func CheckGalleryRole(app core.App, info *core.RequestInfo, gallery *core.Record, roles ...string) error {
+	if IsTrashed(gallery) {
+		return errors.NotFound("Gallery not found")
+	}
+
+	if info.HasSuperuserAuth() {
+		return nil
+	}
+
+	if role := GalleryRole(app, info.Auth, gallery); role == "" || !slices.Contains(roles, role) {
+		return errors.Forbidden("You are not allowed to modify this gallery")
+	}
+
+	return nil
+}
+
+// CheckImageAccess enforces the access rules of the gallery containing the image,
+// falling back to the images collection view rule for images without a gallery
+func CheckImageAccess(app core.App, info *core.RequestInfo, image *core.Record) error {
+	if gallery := FindImageGallery(app, image.Id); gallery != nil {
+		return CheckGalleryAccess(app, info, gallery)
+	}
+
+	ok, err := app.CanAccessRecord(image, info, image.Collection().ViewRule)
+	if err != nil {
+		return errors.InternalError("Failed to check image access", err)
+	}
+	if !ok {
+		return errors.Forbidden("You are not allowed to access this image")
+	}
+	return nil
+}
+
+// ClientGallery loads the gallery and checks the client can access it. Share
+// link clients also need the given share permission.
+func ClientGallery(app core.App, client *GalleryClient, galleryID, permission string) (*core.Record, error) {
+	gallery, err := app.FindRecordById("galleries", galleryID)
+	if err != nil {
+		return nil, errors.NotFound("Gallery not found")
+	}
+
+	if client.Share != nil {
+		if IsTrashed(gallery) {
+			return nil, errors.NotFound("Gallery not found")
+		}
+		if client.Share.GalleryID != gallery.Id {
+			return nil, errors.Forbidden("This share link is for a different gallery")
+		}
+		if !client.Share.Can(permission) {
+			return nil, errors.Forbidden(fmt.Sprintf("This share link does not include the %s permission", permission))
+		}
+		return gallery, nil
+	}
+
+	if client.Info == nil || client.Info.Auth == nil {
+		return nil, errors.Unauthorized("Sign in or use a share link to access this gallery")
+	}
+
+	if err := CheckGalleryAccess(app, client.Info, gallery); err != nil {
+		return nil, err
+	}
+
+	return gallery, nil
+}
+
+// FindUserGalleries returns the galleries the signed in user can view, owned
+// and collaborating ones or every one for superusers, matching the conditions.
+// Trashed galleries are left out.
+func FindUserGalleries(app core.App, info *core.RequestInfo, conditions ...dbx.Expression) ([]*core.Record, error) {
+	params := dbx.Params{}
+	access, err := galleryAccessFilter(&GalleryClient{Info: info}, "galleries", params)
+	if err != nil {
+		return nil, err
+	}
+
+	query := app.RecordQuery("galleries").AndWhere(dbx.NewExp(access, params))
+	for _, condition := range conditions {
+		query.AndWhere(condition)
+	}
+
+	records := []*core.Record{}
+	if err := query.OrderBy("created", "id").All(&records); err != nil {
+		return nil, errors.InternalError("Failed to load galleries", err)
+	}
+
+	return records, nil
+}
+
+// galleryAccessFilter returns the SQL condition limiting a query joined with the
+// galleries table under the alias to the galleries the client can view, and adds
+// its parameters. Trashed galleries are left out for everyone.
+func galleryAccessFilter(client *GalleryClient, alias string, params dbx.Params) (string, error) {
+	notTrashed := alias + ".deleted_at = ''"
+
+	if client.Share != nil {
+		if !client.Share.Can(validation.SharePermissionView) {
+			return "", errors.Forbidden(fmt.Sprintf("This share link does not include the %s permission", validation.SharePermissionView))
+		}
+		params["shareGallery"] = client.Share.GalleryID
+		return notTrashed + " AND " + alias + ".id = {:shareGallery}", nil
+	}
+
+	if client.Info == nil || client.Info.Auth == nil {
+		return "", errors.Unauthorized("Sign in or use a share link to access galleries")
+	}
+
+	if client.Info.HasSuperuserAuth() {
+		return notTrashed, nil
+	}
+
+	return userGalleryFilter(client.Info.Auth.Id, alias, params), nil
+}
+
+// userGalleryFilter returns the condition of the galleries the user can view,
+// the same access as the galleries view rule: owned and collaborating galleries
+func userGalleryFilter(userID, alias string, params dbx.Params) string {
+	params["user"] = userID
+	return alias + ".deleted_at = '' AND (" + alias + ".owner = {:user} OR " + alias + ".id IN (SELECT gallery FROM collaborators WHERE user = {:user}))"
+}
+
+// ownedGalleryFilter returns the condition of the galleries the user owns
+func ownedGalleryFilter(userID, alias string, params dbx.Params) string {
+	params["user"] = userID
+	return alias + ".deleted_at = '' AND " + alias + ".owner = {:user}"
+}
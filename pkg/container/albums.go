@@ -0,0 +1,222 @@
+package container
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// likeEscaper escapes the LIKE wildcards of user input, with \ as the escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// AlbumServiceImpl implements AlbumService
+type AlbumServiceImpl struct {
+	app *pocketbase.PocketBase
+}
+
+func NewAlbumService(app *pocketbase.PocketBase) AlbumService {
+	return &AlbumServiceImpl{app: app}
+}
+
+func (s *AlbumServiceImpl) Create(info *core.RequestInfo, req *validation.SmartAlbumRequest) (*core.Record, error) {
+	if info.Auth == nil || info.Auth.Collection().Name != "users" {
+		return nil, errors.Forbidden("Only users can create smart albums")
+	}
+
+	collection, err := s.app.FindCollectionByNameOrId("smart_albums")
+	if err != nil {
+		return nil, errors.InternalError("Failed to find smart albums collection", err)
+	}
+
+	album := core.NewRecord(collection)
+	album.Set("owner", info.Auth.Id)
+	album.Set("sort", validation.ImageSortCaptured)
+	setAlbumFields(album, req)
+
+	if err := s.app.Save(album); err != nil {
+		return nil, errors.BadRequest("Failed to create smart album", err)
+	}
+
+	return album, nil
+}
+
+func (s *AlbumServiceImpl) Update(info *core.RequestInfo, albumID string, req *validation.SmartAlbumRequest) (*core.Record, error) {
+	album, err := s.app.FindRecordById("smart_albums", albumID)
+	if err != nil {
+		return nil, errors.NotFound("Smart album not found")
+	}
+
+	if err := checkAlbumOwner(info, album); err != nil {
+		return nil, err
+	}
+
+	setAlbumFields(album, req)
+
+	if err := s.app.Save(album); err != nil {
+		return nil, errors.BadRequest("Failed to update smart album", err)
+	}
+
+	return album, nil
+}
+
+// Images evaluates the album's filters against the galleries its owner can
+// view. Through a share link only the galleries of the owner are included,
+// the images of galleries they collaborate on are not theirs to share.
+func (s *AlbumServiceImpl) Images(client *GalleryClient, albumID string, req *validation.ImagePageRequest) (*ImagePage, error) {
+	album, err := s.app.FindRecordById("smart_albums", albumID)
+	if err != nil {
+		return nil, errors.NotFound("Smart album not found")
+	}
+
+	switch {
+	case client.Share != nil:
+		if client.Share.AlbumID != album.Id {
+			return nil, errors.Forbidden("This share link is for a different smart album")
+		}
+		if !client.Share.Can(validation.SharePermissionView) {
+			return nil, errors.Forbidden(fmt.Sprintf("This share link does not include the %s permission", validation.SharePermissionView))
+		}
+	case client.Info == nil || client.Info.Auth == nil:
+		return nil, errors.Unauthorized("Sign in or use a share link to access this smart album")
+	case !client.Info.HasSuperuserAuth() && album.GetString("owner") != client.Info.Auth.Id:
+		return nil, errors.Forbidden("You are not allowed to access this smart album")
+	}
+
+	if req.Sort == "" {
+		req.Sort = album.GetString("sort")
+	}
+
+	query, err := albumQuery(album, client.Share != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return query.page(s.app, req)
+}
+
+// checkAlbumOwner allows superusers and the owner of the album
+func checkAlbumOwner(info *core.RequestInfo, album *core.Record) error {
+	if info.HasSuperuserAuth() || (info.Auth != nil && album.GetString("owner") == info.Auth.Id) {
+		return nil
+	}
+	return errors.Forbidden("You are not allowed to modify this smart album")
+}
+
+// albumHasImage reports whether the image is currently in the album as seen
+// through its share links
+func albumHasImage(app core.App, album *core.Record, imageID string) (bool, error) {
+	query, err := albumQuery(album, true)
+	if err != nil {
+		return false, err
+	}
+
+	query.conditions = append(query.conditions, "i.id = {:image}")
+	query.params["image"] = imageID
+
+	var count int
+	if err := app.DB().NewQuery("SELECT count(*)" + query.from + whereClause(query.conditions)).Bind(query.params).Row(&count); err != nil {
+		return false, errors.InternalError("Failed to check smart album image", err)
+	}
+
+	return count > 0, nil
+}
+
+// setAlbumFields applies the set fields of the request to the album
+func setAlbumFields(album *core.Record, req *validation.SmartAlbumRequest) {
+	if req.Name != nil {
+		album.Set("name", strings.TrimSpace(*req.Name))
+	}
+	if req.Filters != nil {
+		album.Set("filters", req.Filters)
+	}
+	if req.Sort != nil {
+		album.Set("sort", *req.Sort)
+	}
+}
+
+// albumQuery returns the query of the images matching the album's filters in
+// the galleries its owner can view, or only owns when shared, each image once
+func albumQuery(album *core.Record, shared bool) (*imageQuery, error) {
+	var filters validation.SmartAlbumFilters
+	if err := album.UnmarshalJSONField("filters", &filters); err != nil {
+		return nil, errors.InternalError("Failed to read smart album filters", err)
+	}
+
+	params := dbx.Params{}
+	galleryConditions := []string{userGalleryFilter(album.GetString("owner"), "g", params)}
+	if shared {
+		galleryConditions[0] = ownedGalleryFilter(album.GetString("owner"), "g", params)
+	}
+	if len(filters.Galleries) > 0 {
+		placeholders := make([]string, len(filters.Galleries))
+		for n, id := range filters.Galleries {
+			placeholders[n] = fmt.Sprintf("{:gallery%d}", n)
+			params[fmt.Sprintf("gallery%d", n)] = id
+		}
+		galleryConditions = append(galleryConditions, "g.id IN ("+strings.Join(placeholders, ", ")+")")
+	}
+
+	var conditions []string
+
+	if filters.YearFrom != 0 {
+		conditions = append(conditions, "substr("+capturedExpr+", 1, 4) >= {:yearFrom}")
+		params["yearFrom"] = fmt.Sprintf("%04d", filters.YearFrom)
+	}
+	if filters.YearTo != 0 {
+		conditions = append(conditions, "substr("+capturedExpr+", 1, 4) <= {:yearTo}")
+		params["yearTo"] = fmt.Sprintf("%04d", filters.YearTo)
+	}
+
+	if filters.Camera != "" {
+		conditions = append(conditions, `i.camera LIKE {:camera} ESCAPE '\'`)
+		params["camera"] = "%" + likeEscaper.Replace(filters.Camera) + "%"
+	}
+	if filters.Lens != "" {
+		conditions = append(conditions, `i.lens LIKE {:lens} ESCAPE '\'`)
+		params["lens"] = "%" + likeEscaper.Replace(filters.Lens) + "%"
+	}
+
+	// images without a known focal length never match a focal length filter
+	if filters.FocalLengthMin != 0 || filters.FocalLengthMax != 0 {
+		conditions = append(conditions, "i.focal_length > 0")
+	}
+	if filters.FocalLengthMin != 0 {
+		conditions = append(conditions, "i.focal_length >= {:focalMin}")
+		params["focalMin"] = filters.FocalLengthMin
+	}
+	if filters.FocalLengthMax != 0 {
+		conditions = append(conditions, "i.focal_length <= {:focalMax}")
+		params["focalMax"] = filters.FocalLengthMax
+	}
+
+	if filters.MinLikes != 0 {
+		conditions = append(conditions, "i.likes >= {:minLikes}")
+		params["minLikes"] = filters.MinLikes
+	}
+
+	for n, tag := range filters.Tags {
+		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(i.tags) WHERE value = {:tag%d})", n))
+		params[fmt.Sprintf("tag%d", n)] = tag
+	}
+
+	if filters.Place != "" {
+		conditions = append(conditions, `(i.place LIKE {:place} ESCAPE '\' OR i.country = {:country})`)
+		params["place"] = "%" + likeEscaper.Replace(filters.Place) + "%"
+		params["country"] = strings.ToUpper(filters.Place)
+	}
+
+	return &imageQuery{
+		from: " FROM images i JOIN (SELECT j.value AS image, min(g.id) AS gallery" +
+			" FROM galleries g, json_each(g.images) j" + whereClause(galleryConditions) +
+			" GROUP BY j.value) a ON a.image = i.id",
+		gallery:    "a.gallery",
+		conditions: conditions,
+		params:     params,
+	}, nil
+}
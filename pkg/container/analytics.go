@@ -0,0 +1,369 @@
+package container
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/dorianlgs/photo-cifu/pkg/config"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// Analytics events
+const (
+	EventGalleryView = "gallery_view"
+	EventImageOpen   = "image_open"
+	EventDownload    = "download"
+)
+
+// analyticsTopImages is the number of images listed in gallery analytics
+const analyticsTopImages = 50
+
+// AnalyticsCounts are the view counts of a day or a range of days
+type AnalyticsCounts struct {
+	Views      int `json:"views" db:"views"`
+	ImageOpens int `json:"image_opens" db:"image_opens"`
+	Downloads  int `json:"downloads" db:"downloads"`
+}
+
+// AnalyticsDay are the counts of a gallery on a day
+type AnalyticsDay struct {
+	Day string `json:"day" db:"day"`
+	AnalyticsCounts
+}
+
+// AnalyticsImage are the counts of an image over the range
+type AnalyticsImage struct {
+	ID               string `json:"id" db:"id"`
+	OriginalFilename string `json:"original_filename" db:"original_filename"`
+	Opens            int    `json:"opens" db:"opens"`
+	Downloads        int    `json:"downloads" db:"downloads"`
+}
+
+// GalleryAnalytics is the daily time series of a gallery's views from From to
+// To, every day included, with its most opened images
+type GalleryAnalytics struct {
+	Gallery string           `json:"gallery"`
+	From    string           `json:"from"`
+	To      string           `json:"to"`
+	Totals  AnalyticsCounts  `json:"totals"`
+	Days    []AnalyticsDay   `json:"days"`
+	Images  []AnalyticsImage `json:"images"`
+}
+
+// analyticsKey identifies a buffered counter. Image events recorded without
+// their gallery get it when they are written.
+type analyticsKey struct {
+	event   string
+	day     string
+	gallery string
+	image   string
+}
+
+// AnalyticsServiceImpl implements AnalyticsService. Events are counted in
+// memory and added to the daily aggregates every flush interval, or earlier
+// once the buffer is full, so busy galleries don't write on every request.
+type AnalyticsServiceImpl struct {
+	app *pocketbase.PocketBase
+	cfg *config.Config
+
+	mu     sync.Mutex
+	buffer map[analyticsKey]int
+	full   chan struct{}
+
+	// flushMu keeps writes in order
+	flushMu sync.Mutex
+}
+
+func NewAnalyticsService(app *pocketbase.PocketBase, cfg *config.Config) AnalyticsService {
+	s := &AnalyticsServiceImpl{
+		app:    app,
+		cfg:    cfg,
+		buffer: make(map[analyticsKey]int),
+		full:   make(chan struct{}, 1),
+	}
+
+	go s.run()
+
+	return s
+}
+
+// Record counts an event of a gallery or one of its images. The gallery of
+// image events may be left empty when it is not known.
+func (s *AnalyticsServiceImpl) Record(event, galleryID, imageID string) {
+	key := analyticsKey{
+		event:   event,
+		day:     time.Now().UTC().Format(validation.AnalyticsDayFormat),
+		gallery: galleryID,
+		image:   imageID,
+	}
+
+	s.mu.Lock()
+	s.buffer[key]++
+	full := len(s.buffer) >= s.cfg.Analytics.BufferSize
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush writes the buffered counts to the daily aggregates. Counts that fail
+// to be written are kept for the next flush.
+func (s *AnalyticsServiceImpl) Flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	buffer := s.buffer
+	s.buffer = make(map[analyticsKey]int)
+	s.mu.Unlock()
+
+	if len(buffer) == 0 {
+		return nil
+	}
+
+	if err := s.write(buffer); err != nil {
+		s.mu.Lock()
+		for key, count := range buffer {
+			s.buffer[key] += count
+		}
+		s.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// Gallery returns the daily views of the gallery for its owner
+func (s *AnalyticsServiceImpl) Gallery(info *core.RequestInfo, galleryID string, req *validation.AnalyticsRequest) (*GalleryAnalytics, error) {
+	gallery, err := s.app.FindRecordById("galleries", galleryID)
+	if err != nil || IsTrashed(gallery) {
+		return nil, errors.NotFound("Gallery not found")
+	}
+
+	if !info.HasSuperuserAuth() && (info.Auth == nil || gallery.GetString("owner") != info.Auth.Id) {
+		return nil, errors.Forbidden("Only the owner can see the analytics of this gallery")
+	}
+
+	// include what is still buffered
+	if err := s.Flush(); err != nil {
+		s.app.Logger().Warn("Failed to write buffered analytics", "error", err)
+	}
+
+	analytics := &GalleryAnalytics{
+		Gallery: gallery.Id,
+		From:    req.FromDay.Format(validation.AnalyticsDayFormat),
+		To:      req.ToDay.Format(validation.AnalyticsDayFormat),
+		Days:    []AnalyticsDay{},
+		Images:  []AnalyticsImage{},
+	}
+	params := dbx.Params{"gallery": gallery.Id, "from": analytics.From, "to": analytics.To}
+
+	var rows []AnalyticsDay
+	err = s.app.DB().NewQuery(
+		"SELECT day, views, image_opens, downloads FROM gallery_views_daily" +
+			" WHERE gallery = {:gallery} AND day BETWEEN {:from} AND {:to}",
+	).Bind(params).All(&rows)
+	if err != nil {
+		return nil, errors.InternalError("Failed to load gallery analytics", err)
+	}
+
+	counts := make(map[string]AnalyticsCounts, len(rows))
+	for _, row := range rows {
+		counts[row.Day] = row.AnalyticsCounts
+	}
+
+	for day := req.FromDay; !day.After(req.ToDay); day = day.AddDate(0, 0, 1) {
+		key := day.Format(validation.AnalyticsDayFormat)
+		row := AnalyticsDay{Day: key, AnalyticsCounts: counts[key]}
+		analytics.Days = append(analytics.Days, row)
+		analytics.Totals.Views += row.Views
+		analytics.Totals.ImageOpens += row.ImageOpens
+		analytics.Totals.Downloads += row.Downloads
+	}
+
+	params["limit"] = analyticsTopImages
+	err = s.app.DB().NewQuery(
+		"SELECT d.image AS id, i.original_filename, sum(d.opens) AS opens, sum(d.downloads) AS downloads" +
+			" FROM image_views_daily d JOIN images i ON i.id = d.image" +
+			" WHERE d.gallery = {:gallery} AND d.day BETWEEN {:from} AND {:to}" +
+			" GROUP BY d.image ORDER BY opens DESC, downloads DESC, d.image LIMIT {:limit}",
+	).Bind(params).All(&analytics.Images)
+	if err != nil {
+		return nil, errors.InternalError("Failed to load image analytics", err)
+	}
+
+	return analytics, nil
+}
+
+// run flushes the buffer every flush interval and when it is full
+func (s *AnalyticsServiceImpl) run() {
+	ticker := time.NewTicker(time.Duration(max(s.cfg.Analytics.FlushInterval, 1)) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.full:
+		}
+
+		if err := s.Flush(); err != nil {
+			s.app.Logger().Error("Failed to write analytics", "error", err)
+		}
+	}
+}
+
+// write adds the counts to the daily aggregates of galleries and images,
+// skipping galleries and images deleted in the meantime
+func (s *AnalyticsServiceImpl) write(buffer map[analyticsKey]int) error {
+	galleries, err := s.imageGalleries(buffer)
+	if err != nil {
+		return err
+	}
+
+	type galleryDay struct{ gallery, day string }
+	type imageDay struct{ image, gallery, day string }
+	galleryCounts := map[galleryDay]*AnalyticsCounts{}
+	imageCounts := map[imageDay]*AnalyticsImage{}
+
+	for key, count := range buffer {
+		gallery := key.gallery
+		if gallery == "" {
+			gallery = galleries[key.image]
+		}
+		if gallery == "" {
+			continue
+		}
+
+		g := galleryCounts[galleryDay{gallery, key.day}]
+		if g == nil {
+			g = &AnalyticsCounts{}
+			galleryCounts[galleryDay{gallery, key.day}] = g
+		}
+
+		var i *AnalyticsImage
+		if key.image != "" {
+			i = imageCounts[imageDay{key.image, gallery, key.day}]
+			if i == nil {
+				i = &AnalyticsImage{ID: key.image}
+				imageCounts[imageDay{key.image, gallery, key.day}] = i
+			}
+		}
+
+		switch key.event {
+		case EventGalleryView:
+			g.Views += count
+		case EventImageOpen:
+			g.ImageOpens += count
+			if i != nil {
+				i.Opens += count
+			}
+		case EventDownload:
+			g.Downloads += count
+			if i != nil {
+				i.Downloads += count
+			}
+		}
+	}
+
+	now := types.NowDateTime().String()
+
+	return s.app.RunInTransaction(func(txApp core.App) error {
+		for key, counts := range galleryCounts {
+			_, err := txApp.DB().NewQuery(
+				"INSERT INTO gallery_views_daily (id, gallery, day, views, image_opens, downloads, created, updated)" +
+					" SELECT {:id}, {:gallery}, {:day}, {:views}, {:opens}, {:downloads}, {:now}, {:now}" +
+					" WHERE EXISTS (SELECT 1 FROM galleries WHERE id = {:gallery})" +
+					" ON CONFLICT (gallery, day) DO UPDATE SET views = views + excluded.views," +
+					" image_opens = image_opens + excluded.image_opens, downloads = downloads + excluded.downloads," +
+					" updated = excluded.updated",
+			).Bind(dbx.Params{
+				"id":        security.RandomStringWithAlphabet(core.DefaultIdLength, core.DefaultIdAlphabet),
+				"gallery":   key.gallery,
+				"day":       key.day,
+				"views":     counts.Views,
+				"opens":     counts.ImageOpens,
+				"downloads": counts.Downloads,
+				"now":       now,
+			}).Execute()
+			if err != nil {
+				return fmt.Errorf("failed to write gallery analytics: %w", err)
+			}
+		}
+
+		for key, counts := range imageCounts {
+			_, err := txApp.DB().NewQuery(
+				"INSERT INTO image_views_daily (id, image, gallery, day, opens, downloads, created, updated)" +
+					" SELECT {:id}, {:image}, {:gallery}, {:day}, {:opens}, {:downloads}, {:now}, {:now}" +
+					" WHERE EXISTS (SELECT 1 FROM images WHERE id = {:image})" +
+					" AND EXISTS (SELECT 1 FROM galleries WHERE id = {:gallery})" +
+					" ON CONFLICT (image, gallery, day) DO UPDATE SET opens = opens + excluded.opens," +
+					" downloads = downloads + excluded.downloads, updated = excluded.updated",
+			).Bind(dbx.Params{
+				"id":        security.RandomStringWithAlphabet(core.DefaultIdLength, core.DefaultIdAlphabet),
+				"image":     key.image,
+				"gallery":   key.gallery,
+				"day":       key.day,
+				"opens":     counts.Opens,
+				"downloads": counts.Downloads,
+				"now":       now,
+			}).Execute()
+			if err != nil {
+				return fmt.Errorf("failed to write image analytics: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// imageGalleries finds the galleries of the buffered image events recorded without one
+func (s *AnalyticsServiceImpl) imageGalleries(buffer map[analyticsKey]int) (map[string]string, error) {
+	params := dbx.Params{}
+	var placeholders []string
+	seen := map[string]bool{}
+	for key := range buffer {
+		if key.gallery != "" || key.image == "" || seen[key.image] {
+			continue
+		}
+		seen[key.image] = true
+		name := fmt.Sprintf("image%d", len(placeholders))
+		placeholders = append(placeholders, "{:"+name+"}")
+		params[name] = key.image
+	}
+
+	galleries := map[string]string{}
+	if len(placeholders) == 0 {
+		return galleries, nil
+	}
+
+	var rows []struct {
+		Image   string `db:"image"`
+		Gallery string `db:"gallery"`
+	}
+	err := s.app.DB().NewQuery(
+		"SELECT j.value AS image, min(g.id) AS gallery FROM galleries g, json_each(g.images) j" +
+			" WHERE j.value IN (" + strings.Join(placeholders, ", ") + ") GROUP BY j.value",
+	).Bind(params).All(&rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find image galleries: %w", err)
+	}
+
+	for _, row := range rows {
+		galleries[row.Image] = row.Gallery
+	}
+
+	return galleries, nil
+}
@@ -0,0 +1,306 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/dorianlgs/photo-cifu/pkg/config"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/stripe"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// activeStatuses are the subscription statuses that keep the plan of the
+// subscription, past due subscriptions keep it while Stripe retries the payment
+var activeStatuses = []string{"active", "trialing", "past_due"}
+
+// Billing is the subscription of a user as the billing page shows it
+type Billing struct {
+	Plan                   string         `json:"plan"`
+	Status                 string         `json:"status"`
+	ActiveCustomer         bool           `json:"active_customer"`
+	HasEverHadSubscription bool           `json:"has_ever_had_subscription"`
+	CurrentPeriodEnd       types.DateTime `json:"current_period_end"`
+}
+
+// Checkout is a Stripe checkout session the user is redirected to
+type Checkout struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// BillingServiceImpl implements BillingService
+type BillingServiceImpl struct {
+	app    *pocketbase.PocketBase
+	cfg    *config.Config
+	stripe *stripe.Client
+}
+
+func NewBillingService(app *pocketbase.PocketBase, cfg *config.Config) BillingService {
+	return &BillingServiceImpl{
+		app:    app,
+		cfg:    cfg,
+		stripe: stripe.NewClient(cfg.Stripe.SecretKey, cfg.Stripe.APIBase),
+	}
+}
+
+// Billing returns the plan and subscription of the user
+func (s *BillingServiceImpl) Billing(auth *core.Record) (*Billing, error) {
+	if auth == nil || auth.Collection().Name != "users" {
+		return nil, errors.BadRequest("Billing is only available for user accounts", nil)
+	}
+
+	plan, _ := UserPlan(s.app, s.cfg, auth.Id)
+	billing := &Billing{Plan: plan}
+
+	subscription, err := s.app.FindFirstRecordByData("subscriptions", "user", auth.Id)
+	if err == nil {
+		billing.Status = subscription.GetString("status")
+		billing.ActiveCustomer = slices.Contains(activeStatuses, billing.Status)
+		billing.HasEverHadSubscription = subscription.GetString("subscription") != ""
+		billing.CurrentPeriodEnd = subscription.GetDateTime("current_period_end")
+	}
+
+	return billing, nil
+}
+
+// Checkout creates a Stripe checkout session subscribing the user to the plan
+func (s *BillingServiceImpl) Checkout(auth *core.Record, baseURL string, req *validation.CheckoutRequest) (*Checkout, error) {
+	if s.cfg.Stripe.SecretKey == "" {
+		return nil, errors.NotImplemented("Stripe billing is not configured")
+	}
+
+	if auth == nil || auth.Collection().Name != "users" {
+		return nil, errors.BadRequest("Only user accounts can subscribe to a plan", nil)
+	}
+
+	price := s.cfg.Stripe.Prices[req.Plan]
+	if price == "" {
+		return nil, errors.ValidationError(fmt.Sprintf("The %s plan cannot be bought", req.Plan), nil)
+	}
+
+	params := &stripe.CheckoutSessionParams{
+		Price:             price,
+		SuccessURL:        s.cfg.Stripe.SuccessURL,
+		CancelURL:         s.cfg.Stripe.CancelURL,
+		ClientReferenceID: auth.Id,
+		CustomerEmail:     auth.Email(),
+		Metadata:          map[string]string{"user_id": auth.Id, "plan": req.Plan},
+	}
+	if params.SuccessURL == "" {
+		params.SuccessURL = baseURL + "/account/billing?checkout=success"
+	}
+	if params.CancelURL == "" {
+		params.CancelURL = baseURL + "/account/billing?checkout=canceled"
+	}
+
+	// returning customers keep their Stripe customer and its invoices
+	if subscription, err := s.app.FindFirstRecordByData("subscriptions", "user", auth.Id); err == nil {
+		if slices.Contains(activeStatuses, subscription.GetString("status")) {
+			return nil, errors.BadRequest(fmt.Sprintf("You already have an active %s subscription", subscription.GetString("plan")), nil)
+		}
+		params.Customer = subscription.GetString("customer")
+	}
+
+	session, err := s.stripe.CreateCheckoutSession(params)
+	if err != nil {
+		return nil, errors.InternalError("Failed to create checkout session", err)
+	}
+
+	return &Checkout{ID: session.ID, URL: session.URL}, nil
+}
+
+// HandleWebhook verifies and applies a Stripe webhook event. Each event is
+// applied once, and events older than the last one applied to a subscription
+// are ignored, so retried and reordered deliveries are harmless.
+func (s *BillingServiceImpl) HandleWebhook(payload []byte, signature string) error {
+	if s.cfg.Stripe.WebhookSecret == "" {
+		return errors.NotImplemented("Stripe billing is not configured")
+	}
+
+	tolerance := time.Duration(s.cfg.Stripe.SignatureTolerance) * time.Second
+	event, err := stripe.ConstructEvent(payload, signature, s.cfg.Stripe.WebhookSecret, tolerance)
+	if err != nil {
+		return errors.BadRequest("Invalid webhook signature", err)
+	}
+
+	switch event.Type {
+	case stripe.EventCheckoutCompleted, stripe.EventSubscriptionCreated, stripe.EventSubscriptionUpdated, stripe.EventSubscriptionDeleted:
+	default:
+		// acknowledged, so Stripe doesn't retry events the app doesn't use
+		return nil
+	}
+
+	err = s.app.RunInTransaction(func(txApp core.App) error {
+		if _, err := txApp.FindFirstRecordByData("stripe_events", "event_id", event.ID); err == nil {
+			return nil
+		}
+
+		collection, err := txApp.FindCollectionByNameOrId("stripe_events")
+		if err != nil {
+			return err
+		}
+		record := core.NewRecord(collection)
+		record.Set("event_id", event.ID)
+		record.Set("type", event.Type)
+		if err := txApp.Save(record); err != nil {
+			return fmt.Errorf("failed to save event: %w", err)
+		}
+
+		if event.Type == stripe.EventCheckoutCompleted {
+			session := &stripe.CheckoutSession{}
+			if err := json.Unmarshal(event.Data.Object, session); err != nil {
+				return err
+			}
+			return s.applyCheckout(txApp, event, session)
+		}
+
+		subscription := &stripe.Subscription{}
+		if err := json.Unmarshal(event.Data.Object, subscription); err != nil {
+			return err
+		}
+		if event.Type == stripe.EventSubscriptionDeleted {
+			subscription.Status = "canceled"
+		}
+		return s.applySubscription(txApp, event, subscription)
+	})
+	if err != nil {
+		return errors.InternalError("Failed to process webhook event", err)
+	}
+
+	return nil
+}
+
+// applyCheckout links the user of a completed checkout to their Stripe
+// customer and subscription, and gives them the plan once it is paid
+func (s *BillingServiceImpl) applyCheckout(txApp core.App, event *stripe.Event, session *stripe.CheckoutSession) error {
+	if session.Mode != "subscription" {
+		return nil
+	}
+
+	userID := session.ClientReferenceID
+	if userID == "" {
+		userID = session.Metadata["user_id"]
+	}
+	user, err := txApp.FindRecordById("users", userID)
+	if err != nil {
+		s.app.Logger().Warn("Ignoring checkout of an unknown user", "eventID", event.ID, "userID", userID)
+		return nil
+	}
+
+	record, err := s.subscriptionRecord(txApp, user.Id, session.Subscription, session.Customer)
+	if err != nil {
+		return err
+	}
+	record.Set("user", user.Id)
+	record.Set("customer", session.Customer)
+	record.Set("subscription", session.Subscription)
+
+	// the subscription events carry the status, checkout only fills it in
+	// when it arrives first
+	plan := session.Metadata["plan"]
+	paid := session.PaymentStatus == "paid" || session.PaymentStatus == "no_payment_required"
+	if paid && record.GetInt("event_created") == 0 && s.cfg.Stripe.Prices[plan] != "" {
+		record.Set("status", "active")
+		record.Set("plan", plan)
+		user.Set("plan", plan)
+		if err := txApp.Save(user); err != nil {
+			return fmt.Errorf("failed to save user plan: %w", err)
+		}
+	}
+
+	return txApp.Save(record)
+}
+
+// applySubscription stores the status of a subscription and gives its user the
+// plan of the subscribed price, or the default plan once it is no longer active
+func (s *BillingServiceImpl) applySubscription(txApp core.App, event *stripe.Event, subscription *stripe.Subscription) error {
+	record, err := s.subscriptionRecord(txApp, subscription.Metadata["user_id"], subscription.ID, subscription.Customer)
+	if err != nil {
+		return err
+	}
+
+	if record.GetString("user") == "" {
+		user, err := txApp.FindRecordById("users", subscription.Metadata["user_id"])
+		if err != nil {
+			s.app.Logger().Warn("Ignoring subscription of an unknown customer", "eventID", event.ID, "customer", subscription.Customer)
+			return nil
+		}
+		record.Set("user", user.Id)
+	}
+
+	if int64(record.GetInt("event_created")) > event.Created {
+		return nil
+	}
+
+	// a user who subscribed again keeps the new subscription when the old one ends
+	current := record.GetString("subscription")
+	if current != "" && current != subscription.ID &&
+		slices.Contains(activeStatuses, record.GetString("status")) && !slices.Contains(activeStatuses, subscription.Status) {
+		return nil
+	}
+
+	plan := s.pricePlan(subscription.PriceID())
+	if plan == "" {
+		plan = subscription.Metadata["plan"]
+	}
+
+	record.Set("customer", subscription.Customer)
+	record.Set("subscription", subscription.ID)
+	record.Set("status", subscription.Status)
+	record.Set("plan", plan)
+	record.Set("current_period_end", subscription.PeriodEnd())
+	record.Set("event_created", event.Created)
+	if err := txApp.Save(record); err != nil {
+		return fmt.Errorf("failed to save subscription: %w", err)
+	}
+
+	user, err := txApp.FindRecordById("users", record.GetString("user"))
+	if err != nil {
+		return err
+	}
+	if slices.Contains(activeStatuses, subscription.Status) && plan != "" {
+		user.Set("plan", plan)
+	} else {
+		user.Set("plan", s.cfg.Plans.Default)
+	}
+
+	return txApp.Save(user)
+}
+
+// subscriptionRecord finds the subscription record by the Stripe subscription,
+// customer or user, or returns a new one
+func (s *BillingServiceImpl) subscriptionRecord(txApp core.App, userID, subscriptionID, customerID string) (*core.Record, error) {
+	lookups := [][2]string{{"subscription", subscriptionID}, {"customer", customerID}, {"user", userID}}
+	for _, lookup := range lookups {
+		if lookup[1] == "" {
+			continue
+		}
+		if record, err := txApp.FindFirstRecordByData("subscriptions", lookup[0], lookup[1]); err == nil {
+			return record, nil
+		}
+	}
+
+	collection, err := txApp.FindCollectionByNameOrId("subscriptions")
+	if err != nil {
+		return nil, err
+	}
+
+	return core.NewRecord(collection), nil
+}
+
+// pricePlan returns the plan of a Stripe price
+func (s *BillingServiceImpl) pricePlan(price string) string {
+	for plan, id := range s.cfg.Stripe.Prices {
+		if id != "" && id == price {
+			return plan
+		}
+	}
+	return ""
+}
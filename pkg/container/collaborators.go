@@ -0,0 +1,126 @@
+package container
+
+import (
+	"fmt"
+	"html"
+	"net/mail"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// CollaboratorServiceImpl implements CollaboratorService
+type CollaboratorServiceImpl struct {
+	app *pocketbase.PocketBase
+}
+
+func NewCollaboratorService(app *pocketbase.PocketBase) CollaboratorService {
+	return &CollaboratorServiceImpl{app: app}
+}
+
+func (s *CollaboratorServiceImpl) Invite(info *core.RequestInfo, galleryID string, req *validation.CollaboratorInviteRequest) (*core.Record, error) {
+	gallery, err := s.app.FindRecordById("galleries", galleryID)
+	if err != nil {
+		return nil, errors.NotFound("Gallery not found")
+	}
+
+	if err := CheckGalleryRole(s.app, info, gallery, RoleOwner); err != nil {
+		return nil, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	if owner, err := s.app.FindRecordById("users", gallery.GetString("owner")); err == nil && strings.EqualFold(owner.Email(), email) {
+		return nil, errors.ValidationError("The owner can't be invited to their own gallery", nil)
+	}
+
+	existing, _ := s.app.FindFirstRecordByFilter(
+		"collaborators",
+		"gallery = {:gallery} && email = {:email}",
+		dbx.Params{"gallery": gallery.Id, "email": email},
+	)
+	if existing != nil {
+		return nil, errors.ValidationError("This email has already been invited", nil)
+	}
+
+	collection, err := s.app.FindCollectionByNameOrId("collaborators")
+	if err != nil {
+		return nil, errors.InternalError("Failed to find collaborators collection", err)
+	}
+
+	record := core.NewRecord(collection)
+	record.Set("gallery", gallery.Id)
+	record.Set("email", email)
+	record.Set("role", req.Role)
+	if info.Auth != nil && info.Auth.Collection().Name == "users" {
+		record.Set("invited_by", info.Auth.Id)
+	}
+
+	// verified accounts get access right away, everyone else accepts the
+	// invitation once they have signed up and verified the invited email
+	if user, err := s.app.FindAuthRecordByEmail("users", email); err == nil && user.Verified() {
+		record.Set("user", user.Id)
+	}
+
+	if err := s.app.Save(record); err != nil {
+		return nil, errors.InternalError("Failed to save collaborator", err)
+	}
+
+	if err := s.sendInvitation(gallery, record); err != nil {
+		s.app.Logger().Warn("Failed to send collaborator invitation", "email", email, "error", err)
+	}
+
+	return record, nil
+}
+
+func (s *CollaboratorServiceImpl) Accept(auth *core.Record, collaboratorID string) error {
+	record, err := s.app.FindRecordById("collaborators", collaboratorID)
+	if err != nil || !strings.EqualFold(record.GetString("email"), auth.Email()) {
+		return errors.NotFound("Invitation not found")
+	}
+
+	if !auth.Verified() {
+		return errors.Forbidden("Verify your email address before accepting invitations")
+	}
+
+	if user := record.GetString("user"); user != "" {
+		if user != auth.Id {
+			return errors.NotFound("Invitation not found")
+		}
+		return nil
+	}
+
+	record.Set("user", auth.Id)
+	if err := s.app.Save(record); err != nil {
+		return errors.InternalError("Failed to accept invitation", err)
+	}
+
+	return nil
+}
+
+func (s *CollaboratorServiceImpl) sendInvitation(gallery, collaborator *core.Record) error {
+	meta := s.app.Settings().Meta
+	link := strings.TrimRight(meta.AppURL, "/") + "/account"
+
+	message := &mailer.Message{
+		From:    mail.Address{Name: meta.SenderName, Address: meta.SenderAddress},
+		To:      []mail.Address{{Address: collaborator.GetString("email")}},
+		Subject: fmt.Sprintf("You have been invited to the gallery %s", gallery.GetString("name")),
+		HTML: fmt.Sprintf(
+			"<p>You have been invited as %s to the gallery <strong>%s</strong> on %s.</p><p><a href=\"%s\">Open %s</a></p>",
+			html.EscapeString(collaborator.GetString("role")),
+			html.EscapeString(gallery.GetString("name")),
+			html.EscapeString(meta.AppName),
+			html.EscapeString(link),
+			html.EscapeString(meta.AppName),
+		),
+	}
+
+	return s.app.NewMailClient().Send(message)
+}
@@ -0,0 +1,403 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/subscriptions"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/dorianlgs/photo-cifu/pkg/config"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// Comment statuses. Pending comments wait for the owner's approval when the
+// gallery moderates comments, hidden comments are only shown to the owner.
+const (
+	CommentStatusVisible = "visible"
+	CommentStatusPending = "pending"
+	CommentStatusHidden  = "hidden"
+)
+
+// Comment events published on the gallery comments topic
+const (
+	CommentEventCreate = "create"
+	CommentEventUpdate = "update"
+	CommentEventDelete = "delete"
+)
+
+// Comment is an image comment with its replies
+type Comment struct {
+	ID      string     `json:"id"`
+	Image   string     `json:"image"`
+	Parent  string     `json:"parent"`
+	User    string     `json:"user"`
+	Author  string     `json:"author"`
+	Text    string     `json:"text"`
+	Status  string     `json:"status"`
+	Edited  string     `json:"edited"`
+	Created string     `json:"created"`
+	Replies []*Comment `json:"replies"`
+}
+
+// CommentEvent is the realtime message sent to gallery comment subscribers
+type CommentEvent struct {
+	Action  string   `json:"action"`
+	Comment *Comment `json:"comment"`
+}
+
+// CommentsTopic is the realtime topic comments of a gallery are published on
+func CommentsTopic(galleryID string) string {
+	return "galleries/" + galleryID + "/comments"
+}
+
+// CommentServiceImpl implements CommentService
+type CommentServiceImpl struct {
+	app    *pocketbase.PocketBase
+	cfg    *config.Config
+	shares ShareService
+	posts  *AttemptLimiter
+}
+
+func NewCommentService(app *pocketbase.PocketBase, cfg *config.Config, shares ShareService) CommentService {
+	return &CommentServiceImpl{
+		app:    app,
+		cfg:    cfg,
+		shares: shares,
+		posts:  NewAttemptLimiter(cfg.Comments.RateLimit, time.Duration(cfg.Comments.RateWindow)*time.Second),
+	}
+}
+
+func (s *CommentServiceImpl) List(client *GalleryClient, imageID string) ([]*Comment, error) {
+	gallery, err := s.imageGallery(client, imageID, validation.SharePermissionView)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.app.FindRecordsByFilter("comments", "image = {:image}", "created", 0, 0, dbx.Params{"image": imageID})
+	if err != nil {
+		return nil, errors.InternalError("Failed to load comments", err)
+	}
+
+	moderator := s.isModerator(client, gallery)
+
+	// records are sorted by creation so parents always come before their replies,
+	// replies to comments the client can't see are left out with their parent
+	comments := make(map[string]*Comment, len(records))
+	roots := []*Comment{}
+	for _, record := range records {
+		if !s.canSee(client, record, moderator) {
+			continue
+		}
+
+		comment := toComment(record)
+		comments[comment.ID] = comment
+
+		if comment.Parent == "" {
+			roots = append(roots, comment)
+		} else if parent, ok := comments[comment.Parent]; ok {
+			parent.Replies = append(parent.Replies, comment)
+		}
+	}
+
+	return roots, nil
+}
+
+func (s *CommentServiceImpl) Post(client *GalleryClient, imageID string, req *validation.CommentRequest) (*Comment, error) {
+	gallery, err := s.imageGallery(client, imageID, validation.SharePermissionComment)
+	if err != nil {
+		return nil, err
+	}
+
+	key := clientKey(client)
+	if s.posts.Blocked(key) {
+		return nil, errors.TooManyRequests("You are posting comments too quickly, please wait a moment")
+	}
+
+	moderator := s.isModerator(client, gallery)
+
+	if req.Parent != "" {
+		parent, err := s.app.FindRecordById("comments", req.Parent)
+		if err != nil || parent.GetString("image") != imageID || !s.canSee(client, parent, moderator) {
+			return nil, errors.NotFound("Parent comment not found")
+		}
+	}
+
+	collection, err := s.app.FindCollectionByNameOrId("comments")
+	if err != nil {
+		return nil, errors.InternalError("Failed to find comments collection", err)
+	}
+
+	record := core.NewRecord(collection)
+	record.Set("gallery", gallery.Id)
+	record.Set("image", imageID)
+	record.Set("parent", req.Parent)
+	record.Set("text", strings.TrimSpace(req.Text))
+	if client.Share != nil {
+		record.Set("share", client.Share.Share.Id)
+		record.Set("author_name", client.Share.Share.GetString("label"))
+	} else {
+		record.Set("user", client.Info.Auth.Id)
+		record.Set("author_name", client.Info.Auth.GetString("name"))
+	}
+
+	if gallery.GetBool("comment_moderation") && !moderator {
+		record.Set("status", CommentStatusPending)
+	} else {
+		record.Set("status", CommentStatusVisible)
+	}
+
+	if err := s.app.Save(record); err != nil {
+		return nil, errors.InternalError("Failed to save comment", err)
+	}
+
+	s.posts.Add(key)
+	s.publish(gallery, CommentEventCreate, record)
+
+	return toComment(record), nil
+}
+
+func (s *CommentServiceImpl) Edit(client *GalleryClient, commentID string, req *validation.CommentRequest) (*Comment, error) {
+	record, gallery, err := s.clientComment(client, commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isCommentAuthor(client, record) {
+		return nil, errors.Forbidden("You can only edit your own comments")
+	}
+
+	if err := checkCommentWindow(record, s.cfg.Comments.EditWindow, "edited"); err != nil {
+		return nil, err
+	}
+
+	record.Set("text", strings.TrimSpace(req.Text))
+	record.Set("edited", types.NowDateTime())
+
+	if err := s.app.Save(record); err != nil {
+		return nil, errors.InternalError("Failed to save comment", err)
+	}
+
+	s.publish(gallery, CommentEventUpdate, record)
+
+	return toComment(record), nil
+}
+
+func (s *CommentServiceImpl) Delete(client *GalleryClient, commentID string) error {
+	record, gallery, err := s.clientComment(client, commentID)
+	if err != nil {
+		return err
+	}
+
+	// owners can remove any comment, authors only their own and only for a while
+	if !s.isModerator(client, gallery) {
+		if !isCommentAuthor(client, record) {
+			return errors.Forbidden("You can only delete your own comments")
+		}
+		if err := checkCommentWindow(record, s.cfg.Comments.DeleteWindow, "deleted"); err != nil {
+			return err
+		}
+	}
+
+	// replies are removed with the comment by the cascading parent relation
+	if err := s.app.Delete(record); err != nil {
+		return errors.InternalError("Failed to delete comment", err)
+	}
+
+	s.publish(gallery, CommentEventDelete, record)
+
+	return nil
+}
+
+func (s *CommentServiceImpl) Hide(info *core.RequestInfo, commentID string) (*Comment, error) {
+	return s.moderate(info, commentID, CommentStatusHidden)
+}
+
+func (s *CommentServiceImpl) Approve(info *core.RequestInfo, commentID string) (*Comment, error) {
+	return s.moderate(info, commentID, CommentStatusVisible)
+}
+
+// moderate changes the status of a comment on behalf of the gallery owner
+func (s *CommentServiceImpl) moderate(info *core.RequestInfo, commentID, status string) (*Comment, error) {
+	record, err := s.app.FindRecordById("comments", commentID)
+	if err != nil {
+		return nil, errors.NotFound("Comment not found")
+	}
+
+	gallery, err := s.app.FindRecordById("galleries", record.GetString("gallery"))
+	if err != nil {
+		return nil, errors.NotFound("Comment not found")
+	}
+
+	if err := CheckGalleryRole(s.app, info, gallery, RoleOwner); err != nil {
+		return nil, err
+	}
+
+	if record.GetString("status") != status {
+		record.Set("status", status)
+		if err := s.app.Save(record); err != nil {
+			return nil, errors.InternalError("Failed to update comment", err)
+		}
+
+		s.publish(gallery, CommentEventUpdate, record)
+	}
+
+	return toComment(record), nil
+}
+
+// publish sends a comment event to the subscribers of the gallery comments topic
+// that can see the comment. Clients that can no longer see an updated comment,
+// for example after it was hidden, get a delete event instead. Share link
+// clients pass their file token in the X-Share-Token header of the
+// subscription options.
+func (s *CommentServiceImpl) publish(gallery *core.Record, action string, record *core.Record) {
+	comment := toComment(record)
+
+	data, err := json.Marshal(&CommentEvent{Action: action, Comment: comment})
+	if err != nil {
+		s.app.Logger().Warn("Failed to encode comment event", "comment", record.Id, "error", err)
+		return
+	}
+
+	var removed []byte
+	if action == CommentEventUpdate {
+		removed, _ = json.Marshal(&CommentEvent{
+			Action:  CommentEventDelete,
+			Comment: &Comment{ID: comment.ID, Image: comment.Image, Parent: comment.Parent, Replies: []*Comment{}},
+		})
+	}
+
+	topic := CommentsTopic(gallery.Id)
+
+	for _, subscriber := range s.app.SubscriptionsBroker().Clients() {
+		for sub, options := range subscriber.Subscriptions(topic + "?") {
+			client := &GalleryClient{}
+			if token := options.Headers["x_share_token"]; token != "" {
+				grant, err := s.shares.VerifyFileToken(token)
+				if err != nil {
+					continue
+				}
+				client.Share = grant
+			} else {
+				auth, _ := subscriber.Get(apis.RealtimeClientAuthKey).(*core.Record)
+				if auth == nil {
+					continue
+				}
+				client.Info = &core.RequestInfo{Context: core.RequestInfoContextRealtime, Auth: auth}
+			}
+
+			if _, err := ClientGallery(s.app, client, gallery.Id, validation.SharePermissionView); err != nil {
+				continue
+			}
+
+			message := data
+			if !s.canSee(client, record, s.isModerator(client, gallery)) {
+				if removed == nil {
+					continue
+				}
+				message = removed
+			}
+
+			subscriber.Send(subscriptions.Message{Name: sub, Data: message})
+		}
+	}
+}
+
+// imageGallery returns the gallery of the image if the client can access it
+func (s *CommentServiceImpl) imageGallery(client *GalleryClient, imageID, permission string) (*core.Record, error) {
+	gallery := FindImageGallery(s.app, imageID)
+	if gallery == nil {
+		return nil, errors.NotFound("Image not found")
+	}
+
+	return ClientGallery(s.app, client, gallery.Id, permission)
+}
+
+// clientComment loads a comment the client can see together with its gallery
+func (s *CommentServiceImpl) clientComment(client *GalleryClient, commentID string) (*core.Record, *core.Record, error) {
+	record, err := s.app.FindRecordById("comments", commentID)
+	if err != nil {
+		return nil, nil, errors.NotFound("Comment not found")
+	}
+
+	gallery, err := ClientGallery(s.app, client, record.GetString("gallery"), validation.SharePermissionView)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !s.canSee(client, record, s.isModerator(client, gallery)) {
+		return nil, nil, errors.NotFound("Comment not found")
+	}
+
+	return record, gallery, nil
+}
+
+// isModerator reports whether the client moderates the gallery comments
+func (s *CommentServiceImpl) isModerator(client *GalleryClient, gallery *core.Record) bool {
+	if client.Share != nil || client.Info == nil {
+		return false
+	}
+	return CheckGalleryRole(s.app, client.Info, gallery, RoleOwner) == nil
+}
+
+// canSee reports whether the client can see the comment. Authors also see
+// their own comments while they wait for approval.
+func (s *CommentServiceImpl) canSee(client *GalleryClient, record *core.Record, moderator bool) bool {
+	switch record.GetString("status") {
+	case CommentStatusVisible:
+		return true
+	case CommentStatusPending:
+		return moderator || isCommentAuthor(client, record)
+	default:
+		return moderator
+	}
+}
+
+func isCommentAuthor(client *GalleryClient, record *core.Record) bool {
+	if client.Share != nil {
+		return record.GetString("share") == client.Share.Share.Id
+	}
+	return client.Info != nil && client.Info.Auth != nil && record.GetString("user") == client.Info.Auth.Id
+}
+
+// checkCommentWindow fails once the comment is older than the window in seconds
+func checkCommentWindow(record *core.Record, window int, action string) error {
+	created := record.GetDateTime("created").Time()
+	if time.Since(created) > time.Duration(window)*time.Second {
+		return errors.BadRequest(fmt.Sprintf("Comments can only be %s within %d minutes of posting", action, window/60), nil)
+	}
+	return nil
+}
+
+// clientKey identifies the client for rate limiting
+func clientKey(client *GalleryClient) string {
+	if client.Share != nil {
+		return "share:" + client.Share.Share.Id
+	}
+	return "user:" + client.Info.Auth.Id
+}
+
+func toComment(record *core.Record) *Comment {
+	comment := &Comment{
+		ID:      record.Id,
+		Image:   record.GetString("image"),
+		Parent:  record.GetString("parent"),
+		User:    record.GetString("user"),
+		Author:  record.GetString("author_name"),
+		Text:    record.GetString("text"),
+		Status:  record.GetString("status"),
+		Created: record.GetDateTime("created").String(),
+		Replies: []*Comment{},
+	}
+	if edited := record.GetDateTime("edited"); !edited.IsZero() {
+		comment.Edited = edited.String()
+	}
+	return comment
+}
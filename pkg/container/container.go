@@ -2,15 +2,20 @@ package container
 
 import (
 	"context"
+	"io"
 	"path/filepath"
 
 	"github.com/cschleiden/go-workflows/backend"
 	"github.com/cschleiden/go-workflows/backend/sqlite"
 	"github.com/cschleiden/go-workflows/client"
 	"github.com/dorianlgs/photo-cifu/pkg/config"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/iiif"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
 	"github.com/dorianlgs/photo-cifu/tools"
 	"github.com/dorianlgs/photo-cifu/workflow"
 	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
 )
 
 // Container holds all application dependencies
@@ -23,27 +28,187 @@ type Container struct {
 
 // ServiceContainer holds all service implementations
 type ServiceContainer struct {
-	Gallery  GalleryService
-	Workflow WorkflowService
-	Signal   SignalService
-	Settings SettingsService
+	Gallery      GalleryService
+	Workflow     WorkflowService
+	Signal       SignalService
+	Settings     SettingsService
+	IIIF         IIIFService
+	Upload       UploadService
+	Share        ShareService
+	Collaborator CollaboratorService
+	Proofing     ProofingService
+	Like         LikeService
+	Comment      CommentService
+	Search       SearchService
+	Geo          GeoService
+	Timeline     TimelineService
+	Trash        TrashService
+	Album        AlbumService
+	Embed        EmbedService
+	Analytics    AnalyticsService
+	Quota        QuotaService
+	Billing      BillingService
 }
 
 // New creates a new dependency injection container
 func New(app *pocketbase.PocketBase) *Container {
 	cfg := config.New()
 
+	// Create the IIIF tile cache; rendering still works uncached if it cannot be created
+	iiifCacheDir := cfg.IIIF.CacheDir
+	if iiifCacheDir == "" {
+		iiifCacheDir = filepath.Join(app.DataDir(), "iiif_cache")
+	}
+	iiifCache, err := iiif.NewCache(iiifCacheDir, cfg.IIIF.CacheMaxBytes)
+	if err != nil {
+		app.Logger().Warn("Failed to create IIIF cache", "dir", iiifCacheDir, "error", err)
+		iiifCache = nil
+	}
+
 	// Create workflow client
-	workflowClient := createWorkflowClient(app, cfg.WorkflowDB.Name)
+	workflowBackend, workflowClient := createWorkflowClient(app, cfg.WorkflowDB.Name, NewImageProcessor(app, cfg, iiifCache))
 
 	// Create services
+	galleryService := NewGalleryService(app, cfg)
+	shareService := NewShareService(app, cfg)
+
 	services := &ServiceContainer{
-		Gallery:  NewGalleryService(app, cfg),
-		Workflow: NewWorkflowService(workflowClient),
-		Signal:   NewSignalService(workflowClient),
-		Settings: NewSettingsService(app),
+		Gallery:      galleryService,
+		Workflow:     NewWorkflowService(app, workflowClient, workflowBackend, cfg),
+		Signal:       NewSignalService(app, workflowClient),
+		Settings:     NewSettingsService(app),
+		IIIF:         NewIIIFService(app, iiifCache),
+		Upload:       NewUploadService(app, cfg, galleryService),
+		Share:        shareService,
+		Collaborator: NewCollaboratorService(app),
+		Proofing:     NewProofingService(app),
+		Like:         NewLikeService(app),
+		Comment:      NewCommentService(app, cfg, shareService),
+		Search:       NewSearchService(app),
+		Geo:          NewGeoService(app),
+		Timeline:     NewTimelineService(app),
+		Trash:        NewTrashService(app, cfg),
+		Album:        NewAlbumService(app),
+		Embed:        NewEmbedService(app, cfg, shareService),
+		Analytics:    NewAnalyticsService(app, cfg),
+		Quota:        NewQuotaService(app, cfg),
+		Billing:      NewBillingService(app, cfg),
+	}
+
+	// Schedule the nightly cleanup of orphan images, files, uploads and share links
+	if cfg.Cleanup.Schedule != "" {
+		err := app.Cron().Add("photocifuCleanup", cfg.Cleanup.Schedule, func() {
+			if _, err := services.Workflow.CreateWorkflow(nil, "cleanup", nil); err != nil {
+				app.Logger().Error("Failed to start the cleanup workflow", "error", err)
+			}
+		})
+		if err != nil {
+			app.Logger().Warn("Invalid cleanup schedule", "schedule", cfg.Cleanup.Schedule, "error", err)
+		}
 	}
 
+	// Schedule the purge of galleries and images trashed longer than the retention
+	if cfg.Trash.Schedule != "" {
+		err := app.Cron().Add("photocifuTrashPurge", cfg.Trash.Schedule, func() {
+			if _, err := services.Workflow.CreateWorkflow(nil, "trash_purge", nil); err != nil {
+				app.Logger().Error("Failed to start the trash purge workflow", "error", err)
+			}
+		})
+		if err != nil {
+			app.Logger().Warn("Invalid trash purge schedule", "schedule", cfg.Trash.Schedule, "error", err)
+		}
+	}
+
+	// Store the status of finished workflow runs every minute, so runs can be
+	// listed and filtered without reading their history
+	app.Cron().MustAdd("photocifuWorkflowRuns", "* * * * *", func() {
+		if err := services.Workflow.RefreshWorkflows(); err != nil {
+			app.Logger().Error("Failed to refresh workflow runs", "error", err)
+		}
+	})
+
+	// Drop the rendered derivatives of deleted images, however they are deleted
+	if iiifCache != nil {
+		app.OnRecordAfterDeleteSuccess("images").BindFunc(func(e *core.RecordEvent) error {
+			iiifCache.DeleteImage(e.Record.Id)
+			return e.Next()
+		})
+	}
+
+	// Count gallery views through the records API, except by the owner
+	app.OnRecordViewRequest("galleries").BindFunc(func(e *core.RecordRequestEvent) error {
+		if e.Auth == nil || (!e.HasSuperuserAuth() && e.Auth.Id != e.Record.GetString("owner")) {
+			services.Analytics.Record(EventGalleryView, e.Record.Id, "")
+		}
+		return e.Next()
+	})
+
+	// Count image files opened in a viewer through the files API, the gallery
+	// is found when the counts are written
+	app.OnFileDownloadRequest("images").BindFunc(func(e *core.FileDownloadRequestEvent) error {
+		if e.FileField.Name == "image" && e.Request.URL.Query().Get("open") != "" {
+			services.Analytics.Record(EventImageOpen, "", e.Record.Id)
+		}
+		return e.Next()
+	})
+
+	// Galleries created through the records API count against the plan too
+	app.OnRecordCreateRequest("galleries").BindFunc(func(e *core.RecordRequestEvent) error {
+		if e.Auth != nil && !e.HasSuperuserAuth() {
+			if err := CheckGalleryQuota(app, cfg, e.Record.GetString("owner")); err != nil {
+				return errors.HandleError(e.RequestEvent, err)
+			}
+			if err := CheckGalleryImages(app, cfg, e.Auth, e.Record, nil); err != nil {
+				return errors.HandleError(e.RequestEvent, err)
+			}
+		}
+		return e.Next()
+	})
+	app.OnRecordUpdateRequest("galleries").BindFunc(func(e *core.RecordRequestEvent) error {
+		if e.Auth != nil && !e.HasSuperuserAuth() {
+			if err := CheckGalleryImages(app, cfg, e.Auth, e.Record, e.Record.Original().GetStringSlice("images")); err != nil {
+				return errors.HandleError(e.RequestEvent, err)
+			}
+		}
+		return e.Next()
+	})
+
+	// The size of images uploaded through the records API is the one of the
+	// uploaded file, checked against the storage of the uploader
+	app.OnRecordCreateRequest("images").BindFunc(func(e *core.RecordRequestEvent) error {
+		size := uploadedSize(e.Record)
+		e.Record.Set("size", size)
+		if e.Auth != nil && !e.HasSuperuserAuth() {
+			// the uploader can add the image to their galleries, see CheckGalleryImages
+			e.Record.Set("uploader", e.Auth.Id)
+			if err := CheckStorageQuota(app, cfg, e.Auth.Id, size); err != nil {
+				return errors.HandleError(e.RequestEvent, err)
+			}
+		}
+		return e.Next()
+	})
+	app.OnRecordUpdateRequest("images").BindFunc(func(e *core.RecordRequestEvent) error {
+		if len(e.Record.GetUnsavedFiles("image")) == 0 {
+			return e.Next()
+		}
+		size := uploadedSize(e.Record)
+		e.Record.Set("size", size)
+		if e.Auth != nil && !e.HasSuperuserAuth() {
+			if err := CheckStorageQuota(app, cfg, e.Auth.Id, size-int64(e.Record.Original().GetInt("size"))); err != nil {
+				return errors.HandleError(e.RequestEvent, err)
+			}
+		}
+		return e.Next()
+	})
+
+	// Write the buffered analytics before the app stops
+	app.OnTerminate().BindFunc(func(e *core.TerminateEvent) error {
+		if err := services.Analytics.Flush(); err != nil {
+			app.Logger().Error("Failed to write buffered analytics", "error", err)
+		}
+		return e.Next()
+	})
+
 	return &Container{
 		App:            app,
 		Config:         cfg,
@@ -52,7 +217,7 @@ func New(app *pocketbase.PocketBase) *Container {
 	}
 }
 
-func createWorkflowClient(app *pocketbase.PocketBase, workflowDbName string) *client.Client {
+func createWorkflowClient(app *pocketbase.PocketBase, workflowDbName string, images workflow.ImageProcessor) (backend.Backend, *client.Client) {
 	baseDir, _ := tools.InspectRuntime()
 	workflowDBPath := filepath.Join(baseDir, "pb_data", workflowDbName)
 
@@ -61,24 +226,134 @@ func createWorkflowClient(app *pocketbase.PocketBase, workflowDbName string) *cl
 
 	// Start workflow worker
 	ctx := context.Background()
-	go workflow.RunWorker(ctx, workflowBackend, app)
+	go workflow.RunWorker(ctx, workflowBackend, app, images)
 
-	return workflowClient
+	return workflowBackend, workflowClient
 }
 
 // Service interfaces for better testability
 type GalleryService interface {
-	CreateGallery(name, location string, imagesZip, thumbnail []byte, zipHeader, thumbHeader string) (string, error)
+	CreateGallery(ownerID, name, location string, imagesZip, thumbnail []byte, zipHeader, thumbHeader string) (string, error)
+	CreateGalleryFromImages(ownerID, name, location string, images []ImageFile, thumbnail []byte, thumbHeader string) (string, error)
+	AddImage(galleryID, filename string, data []byte) (string, error)
+	AddImages(info *core.RequestInfo, galleryID string, images []ImageFile) ([]string, error)
+	ReplaceImage(galleryID, imageID, filename string, data []byte) error
+	DeleteImage(galleryID, imageID string) error
+	MoveImage(srcGalleryID, imageID, dstGalleryID string) error
+	UpdateGallery(info *core.RequestInfo, galleryID string, req *validation.GalleryUpdateRequest, thumbnail []byte) (*core.Record, error)
+	DeleteGallery(info *core.RequestInfo, galleryID string) error
+	Images(client *GalleryClient, galleryID string, req *validation.ImagePageRequest) (*ImagePage, error)
 }
 
 type WorkflowService interface {
-	CreateWorkflow(workflowType string, input interface{}) (string, error)
+	CreateWorkflow(auth *core.Record, workflowType string, input interface{}) (string, error)
+	GetWorkflow(auth *core.Record, instanceID string) (*WorkflowRunDetail, error)
+	ListWorkflows(auth *core.Record, req *validation.WorkflowListRequest) (*WorkflowRunList, error)
+	RefreshWorkflows() error
 }
 
 type SignalService interface {
-	SendSignal(instanceID, signalName string, data interface{}) error
+	SendSignal(auth *core.Record, instanceID, signalName string, data interface{}) error
 }
 
 type SettingsService interface {
 	UpdateSettings(settings map[string]interface{}) error
+}
+
+type IIIFService interface {
+	ImageInfo(info *core.RequestInfo, baseURL, imageID string) (*iiif.ImageInfo, error)
+	RenderImage(info *core.RequestInfo, imageID string, req *iiif.ImageRequest) ([]byte, error)
+	GalleryManifest(info *core.RequestInfo, baseURL, galleryID string) (*iiif.Manifest, error)
+}
+
+type UploadService interface {
+	CreateSession(auth *core.Record, req *validation.UploadSessionRequest) (*UploadSession, error)
+	CompleteSession(auth *core.Record, sessionID string) (string, error)
+}
+
+type ShareService interface {
+	CreateShare(info *core.RequestInfo, galleryID string, req *validation.ShareCreateRequest) (*ShareLink, error)
+	CreateAlbumShare(info *core.RequestInfo, albumID string, req *validation.ShareCreateRequest) (*ShareLink, error)
+	RevokeShare(info *core.RequestInfo, shareID string) error
+	Access(token, password, clientIP string) (*ShareAccess, error)
+	Find(token, clientIP string) (*core.Record, error)
+	VerifyFileToken(token string) (*ShareGrant, error)
+	OpenImage(grant *ShareGrant, imageID string, download bool) (io.ReadCloser, *core.Record, error)
+}
+
+type CollaboratorService interface {
+	Invite(info *core.RequestInfo, galleryID string, req *validation.CollaboratorInviteRequest) (*core.Record, error)
+	Accept(auth *core.Record, collaboratorID string) error
+}
+
+type ProofingService interface {
+	GetSelection(client *GalleryClient, galleryID string) (*Selection, error)
+	Pick(client *GalleryClient, galleryID, imageID string, req *validation.SelectionPickRequest) (*Selection, error)
+	Unpick(client *GalleryClient, galleryID, imageID string) (*Selection, error)
+	Submit(client *GalleryClient, galleryID string, req *validation.SelectionSubmitRequest) (*Selection, error)
+	Reopen(info *core.RequestInfo, selectionID string) (*Selection, error)
+	Approve(info *core.RequestInfo, selectionID string) (*Selection, error)
+	Export(info *core.RequestInfo, selectionID, format string) (*SelectionExport, error)
+}
+
+type LikeService interface {
+	Like(client *GalleryClient, imageID string) (*LikeStatus, error)
+	Unlike(client *GalleryClient, imageID string) (*LikeStatus, error)
+}
+
+type CommentService interface {
+	List(client *GalleryClient, imageID string) ([]*Comment, error)
+	Post(client *GalleryClient, imageID string, req *validation.CommentRequest) (*Comment, error)
+	Edit(client *GalleryClient, commentID string, req *validation.CommentRequest) (*Comment, error)
+	Delete(client *GalleryClient, commentID string) error
+	Hide(info *core.RequestInfo, commentID string) (*Comment, error)
+	Approve(info *core.RequestInfo, commentID string) (*Comment, error)
+}
+
+type SearchService interface {
+	Search(client *GalleryClient, req *validation.SearchRequest) (*SearchResult, error)
+}
+
+type GeoService interface {
+	Within(client *GalleryClient, req *validation.GeoBoundsRequest) (*GeoClusters, error)
+	Near(client *GalleryClient, req *validation.GeoNearRequest) ([]GeoNearby, error)
+}
+
+type TimelineService interface {
+	Timeline(client *GalleryClient, req *validation.TimelineRequest) (*Timeline, error)
+}
+
+type TrashService interface {
+	List(info *core.RequestInfo) (*Trash, error)
+	RestoreGallery(info *core.RequestInfo, galleryID string) (*core.Record, error)
+	RestoreImage(info *core.RequestInfo, imageID string) (*core.Record, error)
+	DeleteGallery(info *core.RequestInfo, galleryID string) error
+	DeleteImage(info *core.RequestInfo, imageID string) error
+}
+
+type AlbumService interface {
+	Create(info *core.RequestInfo, req *validation.SmartAlbumRequest) (*core.Record, error)
+	Update(info *core.RequestInfo, albumID string, req *validation.SmartAlbumRequest) (*core.Record, error)
+	Images(client *GalleryClient, albumID string, req *validation.ImagePageRequest) (*ImagePage, error)
+}
+
+type EmbedService interface {
+	OEmbed(baseURL, clientIP string, req *validation.OEmbedRequest) (*OEmbed, error)
+	Gallery(galleryID, shareToken, clientIP string) (*EmbedGallery, error)
+}
+
+type AnalyticsService interface {
+	Record(event, galleryID, imageID string)
+	Flush() error
+	Gallery(info *core.RequestInfo, galleryID string, req *validation.AnalyticsRequest) (*GalleryAnalytics, error)
+}
+
+type QuotaService interface {
+	Usage(auth *core.Record) (*Usage, error)
+}
+
+type BillingService interface {
+	Billing(auth *core.Record) (*Billing, error)
+	Checkout(auth *core.Record, baseURL string, req *validation.CheckoutRequest) (*Checkout, error)
+	HandleWebhook(payload []byte, signature string) error
 }
\ No newline at end of file
@@ -0,0 +1,264 @@
+package container
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/config"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/iiif"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// embedPath matches the gallery URLs oEmbed consumers can ask for: the embed
+// page itself and the gallery page of the app
+var embedPath = regexp.MustCompile(`^/(?:embed|account)/gallery/([a-z0-9]{15})/?$`)
+
+// embedImageSize is the IIIF size of the images of public galleries in the embed page
+const embedImageSize = "!1600,1600"
+
+// OEmbed is an oEmbed rich response, see https://oembed.com
+type OEmbed struct {
+	Type            string `json:"type"`
+	Version         string `json:"version"`
+	Title           string `json:"title"`
+	AuthorName      string `json:"author_name,omitempty"`
+	ProviderName    string `json:"provider_name"`
+	ProviderURL     string `json:"provider_url"`
+	HTML            string `json:"html"`
+	Width           int    `json:"width"`
+	Height          int    `json:"height"`
+	ThumbnailURL    string `json:"thumbnail_url,omitempty"`
+	ThumbnailWidth  int    `json:"thumbnail_width,omitempty"`
+	ThumbnailHeight int    `json:"thumbnail_height,omitempty"`
+}
+
+// EmbedGallery is a gallery as shown by the embed page
+type EmbedGallery struct {
+	ID          string
+	Name        string
+	Location    string
+	Description string
+	Images      []EmbedImage
+}
+
+// EmbedImage is an image of the embed page, URL is relative to the app
+type EmbedImage struct {
+	ID  string
+	URL string
+	// OpenURL is the URL of the image in the viewer, counted as an image open
+	OpenURL string
+	Caption string
+	AltText string
+}
+
+// EmbedServiceImpl implements EmbedService
+type EmbedServiceImpl struct {
+	app    *pocketbase.PocketBase
+	cfg    *config.Config
+	shares ShareService
+}
+
+func NewEmbedService(app *pocketbase.PocketBase, cfg *config.Config, shares ShareService) EmbedService {
+	return &EmbedServiceImpl{app: app, cfg: cfg, shares: shares}
+}
+
+// OEmbed describes the gallery of an app URL for oEmbed consumers. Public
+// galleries are embedded by their URL, other galleries with a share token in
+// the share query parameter.
+func (s *EmbedServiceImpl) OEmbed(baseURL, clientIP string, req *validation.OEmbedRequest) (*OEmbed, error) {
+	galleryID, query, err := parseEmbedURL(baseURL, req.URL)
+	if err != nil {
+		return nil, err
+	}
+	shareToken := query.Get("share")
+
+	gallery, err := s.embeddableGallery(galleryID, shareToken, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := s.cfg.Embed.Width, s.cfg.Embed.Height
+	if req.MaxWidth > 0 {
+		width = min(width, req.MaxWidth)
+	}
+	if req.MaxHeight > 0 {
+		height = min(height, req.MaxHeight)
+	}
+
+	// the iframe keeps the share token and layout of the URL
+	params := url.Values{}
+	if shareToken != "" {
+		params.Set("share", shareToken)
+	}
+	if layout := query.Get("layout"); layout == validation.EmbedLayoutGrid || layout == validation.EmbedLayoutSlideshow {
+		params.Set("layout", layout)
+	}
+	src := baseURL + "/embed/gallery/" + gallery.Id
+	if len(params) > 0 {
+		src += "?" + params.Encode()
+	}
+
+	oembed := &OEmbed{
+		Type:         "rich",
+		Version:      "1.0",
+		Title:        gallery.GetString("name"),
+		ProviderName: s.app.Settings().Meta.AppName,
+		ProviderURL:  baseURL,
+		HTML: fmt.Sprintf(
+			`<iframe src="%s" width="%d" height="%d" title="%s" style="border:0" loading="lazy" allowfullscreen></iframe>`,
+			html.EscapeString(src), width, height, html.EscapeString(gallery.GetString("name")),
+		),
+		Width:  width,
+		Height: height,
+	}
+
+	if owner, err := s.app.FindRecordById("users", gallery.GetString("owner")); err == nil {
+		oembed.AuthorName = owner.GetString("name")
+	}
+
+	// consumers must not get a thumbnail larger than they asked for
+	if thumbnail := gallery.GetString("thumbnail"); thumbnail != "" {
+		w, h, err := s.thumbnailSize(gallery)
+		if err != nil {
+			s.app.Logger().Warn("Failed to read gallery thumbnail size", "galleryID", gallery.Id, "error", err)
+		} else if (req.MaxWidth == 0 || w <= req.MaxWidth) && (req.MaxHeight == 0 || h <= req.MaxHeight) {
+			oembed.ThumbnailURL = baseURL + "/api/files/" + gallery.Collection().Id + "/" + gallery.Id + "/" + url.PathEscape(thumbnail)
+			oembed.ThumbnailWidth = w
+			oembed.ThumbnailHeight = h
+		}
+	}
+
+	return oembed, nil
+}
+
+// Gallery loads a public or share linked gallery for the embed page. Opening
+// a share link here uses it like shares/access does.
+func (s *EmbedServiceImpl) Gallery(galleryID, shareToken, clientIP string) (*EmbedGallery, error) {
+	gallery, err := s.embeddableGallery(galleryID, shareToken, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	fileToken := ""
+	if shareToken != "" {
+		access, err := s.shares.Access(shareToken, "", clientIP)
+		if err != nil {
+			return nil, err
+		}
+		fileToken = access.FileToken
+	}
+
+	if errs := s.app.ExpandRecord(gallery, []string{"images"}, nil); len(errs) > 0 {
+		return nil, errors.InternalError("Failed to load gallery images", nil)
+	}
+
+	embed := &EmbedGallery{
+		ID:          gallery.Id,
+		Name:        gallery.GetString("name"),
+		Location:    gallery.GetString("location"),
+		Description: gallery.GetString("description"),
+		Images:      []EmbedImage{},
+	}
+
+	for _, image := range gallery.ExpandedAll("images") {
+		if image.GetString("image") == "" {
+			continue
+		}
+
+		// share links serve their images with the file token, public galleries
+		// through IIIF at a size fit for the page
+		src := "/iiif/" + image.Id + "/full/" + embedImageSize + "/0/default.jpg"
+		openURL := src + "?open=1"
+		if fileToken != "" {
+			src = "/api/photocifu/shares/images/" + image.Id + "?token=" + url.QueryEscape(fileToken)
+			openURL = src + "&open=1"
+		}
+
+		embed.Images = append(embed.Images, EmbedImage{
+			ID:      image.Id,
+			URL:     src,
+			OpenURL: openURL,
+			Caption: image.GetString("caption"),
+			AltText: image.GetString("alt_text"),
+		})
+	}
+
+	return embed, nil
+}
+
+// embeddableGallery loads a gallery that is public, or shared by the share
+// token with the view permission and no password
+func (s *EmbedServiceImpl) embeddableGallery(galleryID, shareToken, clientIP string) (*core.Record, error) {
+	gallery, err := s.app.FindRecordById("galleries", galleryID)
+	if err != nil || IsTrashed(gallery) {
+		return nil, errors.NotFound("Gallery not found")
+	}
+
+	if shareToken == "" {
+		if !gallery.GetBool("public") {
+			return nil, errors.Forbidden("This gallery is not public, embed it with a share link")
+		}
+		return gallery, nil
+	}
+
+	share, err := s.shares.Find(shareToken, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	grant := &ShareGrant{Share: share, GalleryID: share.GetString("gallery")}
+	if grant.GalleryID != gallery.Id {
+		return nil, errors.Forbidden("This share link is for a different gallery")
+	}
+	if share.GetString("password_hash") != "" {
+		return nil, errors.Forbidden("Password protected share links cannot be embedded")
+	}
+	if !grant.Can(validation.SharePermissionView) {
+		return nil, errors.Forbidden(fmt.Sprintf("This share link does not include the %s permission", validation.SharePermissionView))
+	}
+
+	return gallery, nil
+}
+
+// thumbnailSize reads the dimensions of the gallery's cover
+func (s *EmbedServiceImpl) thumbnailSize(gallery *core.Record) (int, int, error) {
+	fsys, err := s.app.NewFilesystem()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer fsys.Close()
+
+	reader, err := fsys.GetReader(gallery.BaseFilesPath() + "/" + gallery.GetString("thumbnail"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer reader.Close()
+
+	return iiif.DecodeConfig(reader)
+}
+
+// parseEmbedURL returns the gallery id and query parameters of a gallery URL of this app
+func parseEmbedURL(baseURL, rawURL string) (string, url.Values, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", nil, errors.NotFound("Unknown gallery URL")
+	}
+
+	base, err := url.Parse(baseURL)
+	if err != nil || u.Host != base.Host {
+		return "", nil, errors.NotFound("Unknown gallery URL")
+	}
+
+	match := embedPath.FindStringSubmatch(u.Path)
+	if match == nil {
+		return "", nil, errors.NotFound("Unknown gallery URL")
+	}
+
+	return match[1], u.Query(), nil
+}
@@ -0,0 +1,78 @@
+package container
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/exif"
+)
+
+// openImage opens the stored file of an image record
+func openImage(app core.App, image *core.Record) (io.ReadCloser, error) {
+	return openRecordFile(app, image, image.GetString("image"))
+}
+
+// openRecordFile opens one of the stored files of a record
+func openRecordFile(app core.App, record *core.Record, filename string) (io.ReadCloser, error) {
+	fsys, err := app.NewFilesystem()
+	if err != nil {
+		return nil, errors.InternalError("Failed to open storage", err)
+	}
+
+	reader, err := fsys.GetReader(record.BaseFilesPath() + "/" + filename)
+	if err != nil {
+		fsys.Close()
+		return nil, errors.NotFound("Image file not found")
+	}
+
+	return &storageReader{ReadCloser: reader, close: fsys.Close}, nil
+}
+
+// storageReader closes the underlying filesystem together with the file reader
+type storageReader struct {
+	io.ReadCloser
+	close func() error
+}
+
+func (r *storageReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.close()
+	return err
+}
+
+// SetImageMetadata sets the size and SHA-256 of an image record along with the camera, lens, focal length,
+// capture time and GPS position from the EXIF metadata of its file, clearing them for files without metadata
+func SetImageMetadata(image *core.Record, data []byte) {
+	setFileMetadata(image, data)
+
+	meta, err := exif.Decode(data)
+	if err != nil {
+		meta = &exif.Metadata{}
+	}
+
+	image.Set("camera", meta.Camera())
+	image.Set("lens", meta.Lens())
+	image.Set("focal_length", meta.FocalLength)
+	if meta.Taken.IsZero() {
+		image.Set("taken_at", "")
+	} else {
+		image.Set("taken_at", meta.Taken)
+	}
+	if meta.HasGPS {
+		image.Set("gps", types.GeoPoint{Lon: meta.Longitude, Lat: meta.Latitude})
+	} else {
+		image.Set("gps", types.GeoPoint{})
+	}
+}
+
+// setFileMetadata sets the size and SHA-256 of an image record from its file
+func setFileMetadata(image *core.Record, data []byte) {
+	sum := sha256.Sum256(data)
+	image.Set("size", len(data))
+	image.Set("sha256", hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,239 @@
+package container
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/geo"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// maxGeoClusters is the number of clusters returned for a bounding box
+const maxGeoClusters = 1000
+
+// GeoCluster is a map marker for the images or galleries in a grid cell. ID is
+// set when the cluster holds a single image or gallery.
+type GeoCluster struct {
+	Lat    float64    `json:"lat"`
+	Lon    float64    `json:"lon"`
+	Count  int        `json:"count"`
+	ID     string     `json:"id,omitempty"`
+	Bounds [4]float64 `json:"bounds"`
+}
+
+// GeoClusters are the markers of a bounding box at a zoom level
+type GeoClusters struct {
+	Zoom      int          `json:"zoom"`
+	CellSize  float64      `json:"cellSize"`
+	Clusters  []GeoCluster `json:"clusters"`
+	Truncated bool         `json:"truncated"`
+}
+
+// GeoNearby is an image, or a gallery with images, within the radius of a
+// point. Distance is in meters to the image, or the gallery's nearest image.
+type GeoNearby struct {
+	ID       string  `json:"id"`
+	Gallery  string  `json:"gallery,omitempty"`
+	Name     string  `json:"name,omitempty"`
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
+	Distance float64 `json:"distance"`
+	Count    int     `json:"count,omitempty"`
+}
+
+// GeoServiceImpl implements GeoService
+type GeoServiceImpl struct {
+	app *pocketbase.PocketBase
+}
+
+func NewGeoService(app *pocketbase.PocketBase) GeoService {
+	return &GeoServiceImpl{app: app}
+}
+
+func (s *GeoServiceImpl) Within(client *GalleryClient, req *validation.GeoBoundsRequest) (*GeoClusters, error) {
+	params := dbx.Params{
+		"minLat": req.MinLat,
+		"maxLat": req.MaxLat,
+		"minLon": req.MinLon,
+		"maxLon": req.MaxLon,
+		"cell":   geo.CellSize(req.Zoom),
+		"limit":  maxGeoClusters + 1,
+	}
+
+	where, err := geoConditions(client, params)
+	if err != nil {
+		return nil, err
+	}
+	where = append(where, "geo.min_lat <= {:maxLat} AND geo.max_lat >= {:minLat}")
+	if req.MinLon <= req.MaxLon {
+		where = append(where, "geo.min_lon <= {:maxLon} AND geo.max_lon >= {:minLon}")
+	} else {
+		where = append(where, "(geo.max_lon >= {:minLon} OR geo.min_lon <= {:maxLon})")
+	}
+
+	// the markers are the images, or the center of each gallery's images in the box
+	markers := "SELECT geo.image AS id, geo.min_lat AS lat, geo.min_lon AS lon" + geoFrom + whereClause(where)
+	if req.Kind == validation.GeoKindGalleries {
+		where = append(where, "geo.gallery != ''")
+		markers = "SELECT geo.gallery AS id, avg(geo.min_lat) AS lat, avg(geo.min_lon) AS lon" + geoFrom + whereClause(where) +
+			" GROUP BY geo.gallery"
+	}
+
+	rows := []struct {
+		Lat    float64 `db:"lat"`
+		Lon    float64 `db:"lon"`
+		Count  int     `db:"count"`
+		ID     string  `db:"id"`
+		MinLat float64 `db:"min_lat"`
+		MinLon float64 `db:"min_lon"`
+		MaxLat float64 `db:"max_lat"`
+		MaxLon float64 `db:"max_lon"`
+	}{}
+
+	// coordinates are shifted to be positive, so the cast to integer rounds down
+	err = s.app.DB().NewQuery(
+		"SELECT avg(lat) AS lat, avg(lon) AS lon, count(*) AS count, min(id) AS id," +
+			" min(lat) AS min_lat, min(lon) AS min_lon, max(lat) AS max_lat, max(lon) AS max_lon" +
+			" FROM (" + markers + ")" +
+			" GROUP BY CAST((lat + 90) / {:cell} AS INTEGER), CAST((lon + 180) / {:cell} AS INTEGER)" +
+			" ORDER BY count DESC LIMIT {:limit}",
+	).Bind(params).All(&rows)
+	if err != nil {
+		return nil, errors.InternalError("Failed to query the map", err)
+	}
+
+	result := &GeoClusters{
+		Zoom:     req.Zoom,
+		CellSize: geo.CellSize(req.Zoom),
+		Clusters: []GeoCluster{},
+	}
+	if len(rows) > maxGeoClusters {
+		rows = rows[:maxGeoClusters]
+		result.Truncated = true
+	}
+
+	for _, row := range rows {
+		cluster := GeoCluster{
+			Lat:    row.Lat,
+			Lon:    row.Lon,
+			Count:  row.Count,
+			Bounds: [4]float64{row.MinLon, row.MinLat, row.MaxLon, row.MaxLat},
+		}
+		if row.Count == 1 {
+			cluster.ID = row.ID
+		}
+		result.Clusters = append(result.Clusters, cluster)
+	}
+
+	return result, nil
+}
+
+func (s *GeoServiceImpl) Near(client *GalleryClient, req *validation.GeoNearRequest) ([]GeoNearby, error) {
+	minLat, minLon, maxLat, maxLon := geo.Around(req.Lat, req.Lon, req.Radius)
+	params := dbx.Params{
+		"minLat": minLat,
+		"maxLat": maxLat,
+		"minLon": minLon,
+		"maxLon": maxLon,
+	}
+
+	where, err := geoConditions(client, params)
+	if err != nil {
+		return nil, err
+	}
+	where = append(where, "geo.min_lat <= {:maxLat} AND geo.max_lat >= {:minLat}")
+	if minLon <= maxLon {
+		where = append(where, "geo.min_lon <= {:maxLon} AND geo.max_lon >= {:minLon}")
+	} else {
+		where = append(where, "(geo.max_lon >= {:minLon} OR geo.min_lon <= {:maxLon})")
+	}
+	if req.Kind == validation.GeoKindGalleries {
+		where = append(where, "geo.gallery != ''")
+	}
+
+	rows := []struct {
+		Image   string  `db:"image"`
+		Gallery string  `db:"gallery"`
+		Name    string  `db:"name"`
+		Lat     float64 `db:"lat"`
+		Lon     float64 `db:"lon"`
+	}{}
+
+	err = s.app.DB().NewQuery(
+		"SELECT geo.image AS image, geo.gallery AS gallery, coalesce(g.name, '') AS name," +
+			" json_extract(i.gps, '$.lat') AS lat, json_extract(i.gps, '$.lon') AS lon" +
+			geoFrom + " JOIN images i ON i.id = geo.image" + whereClause(where),
+	).Bind(params).All(&rows)
+	if err != nil {
+		return nil, errors.InternalError("Failed to query the map", err)
+	}
+
+	// the box is only a prefilter, R*Tree coordinates are rounded to 32-bit
+	// floats and the exact distance to the image position decides
+	items := []GeoNearby{}
+	galleries := map[string]int{}
+	for _, row := range rows {
+		distance := geo.Distance(req.Lat, req.Lon, row.Lat, row.Lon)
+		if distance > req.Radius {
+			continue
+		}
+
+		if req.Kind == validation.GeoKindImages {
+			items = append(items, GeoNearby{
+				ID:       row.Image,
+				Gallery:  row.Gallery,
+				Lat:      row.Lat,
+				Lon:      row.Lon,
+				Distance: distance,
+			})
+			continue
+		}
+
+		if i, ok := galleries[row.Gallery]; ok {
+			items[i].Count++
+			if distance < items[i].Distance {
+				items[i].Lat, items[i].Lon, items[i].Distance = row.Lat, row.Lon, distance
+			}
+			continue
+		}
+		galleries[row.Gallery] = len(items)
+		items = append(items, GeoNearby{
+			ID:       row.Gallery,
+			Name:     row.Name,
+			Lat:      row.Lat,
+			Lon:      row.Lon,
+			Distance: distance,
+			Count:    1,
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Distance != items[j].Distance {
+			return items[i].Distance < items[j].Distance
+		}
+		return strings.Compare(items[i].ID, items[j].ID) < 0
+	})
+	if len(items) > req.Limit {
+		items = items[:req.Limit]
+	}
+
+	return items, nil
+}
+
+const geoFrom = " FROM " + geo.Table + " geo LEFT JOIN galleries g ON g.id = geo.gallery"
+
+// geoConditions returns the access conditions of a geo index query
+func geoConditions(client *GalleryClient, params dbx.Params) ([]string, error) {
+	access, err := galleryAccessFilter(client, "g", params)
+	if err != nil {
+		return nil, err
+	}
+	if access == "" {
+		return nil, nil
+	}
+	return []string{access}, nil
+}
@@ -0,0 +1,131 @@
+package container
+
+import (
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/iiif"
+)
+
+// IIIFServiceImpl implements IIIFService
+type IIIFServiceImpl struct {
+	app   *pocketbase.PocketBase
+	cache *iiif.Cache
+}
+
+func NewIIIFService(app *pocketbase.PocketBase, cache *iiif.Cache) IIIFService {
+	return &IIIFServiceImpl{app: app, cache: cache}
+}
+
+func (s *IIIFServiceImpl) ImageInfo(info *core.RequestInfo, baseURL, imageID string) (*iiif.ImageInfo, error) {
+	image, err := s.findImage(info, imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height, err := s.dimensions(image)
+	if err != nil {
+		return nil, err
+	}
+
+	return iiif.NewImageInfo(imageServiceID(baseURL, image.Id), width, height), nil
+}
+
+func (s *IIIFServiceImpl) RenderImage(info *core.RequestInfo, imageID string, req *iiif.ImageRequest) ([]byte, error) {
+	image, err := s.findImage(info, imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the stored filename changes on every upload, so stale tiles are never served
+	key := iiif.ImageKey(image.Id, image.GetString("image"), req.String())
+	if s.cache != nil {
+		if data, ok := s.cache.Get(key); ok {
+			return data, nil
+		}
+	}
+
+	source, err := openImage(s.app, image)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	data, err := iiif.Render(source, req)
+	if err != nil {
+		return nil, errors.BadRequest("Failed to render image", err)
+	}
+
+	if s.cache != nil {
+		if err := s.cache.Put(key, data); err != nil {
+			s.app.Logger().Warn("Failed to cache IIIF tile", "imageID", image.Id, "error", err)
+		}
+	}
+
+	return data, nil
+}
+
+func (s *IIIFServiceImpl) GalleryManifest(info *core.RequestInfo, baseURL, galleryID string) (*iiif.Manifest, error) {
+	gallery, err := s.app.FindRecordById("galleries", galleryID)
+	if err != nil {
+		return nil, errors.NotFound("Gallery not found")
+	}
+
+	if err := CheckGalleryAccess(s.app, info, gallery); err != nil {
+		return nil, err
+	}
+
+	if errs := s.app.ExpandRecord(gallery, []string{"images"}, nil); len(errs) > 0 {
+		return nil, errors.InternalError("Failed to load gallery images", nil)
+	}
+
+	manifest := iiif.NewManifest(
+		baseURL+"/iiif/gallery/"+gallery.Id+"/manifest.json",
+		gallery.GetString("name"),
+		gallery.GetString("location"),
+	)
+
+	for _, image := range gallery.ExpandedAll("images") {
+		width, height, err := s.dimensions(image)
+		if err != nil {
+			s.app.Logger().Warn("Skipping unreadable image in manifest", "imageID", image.Id, "error", err)
+			continue
+		}
+		manifest.AddImage(imageServiceID(baseURL, image.Id), width, height)
+	}
+
+	return manifest, nil
+}
+
+func (s *IIIFServiceImpl) findImage(info *core.RequestInfo, imageID string) (*core.Record, error) {
+	image, err := s.app.FindRecordById("images", imageID)
+	if err != nil || image.GetString("image") == "" {
+		return nil, errors.NotFound("Image not found")
+	}
+
+	if err := CheckImageAccess(s.app, info, image); err != nil {
+		return nil, err
+	}
+
+	return image, nil
+}
+
+func (s *IIIFServiceImpl) dimensions(image *core.Record) (int, int, error) {
+	reader, err := openImage(s.app, image)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer reader.Close()
+
+	width, height, err := iiif.DecodeConfig(reader)
+	if err != nil {
+		return 0, 0, errors.InternalError("Failed to decode image", err)
+	}
+
+	return width, height, nil
+}
+
+func imageServiceID(baseURL, imageID string) string {
+	return baseURL + "/iiif/" + imageID
+}
@@ -0,0 +1,88 @@
+package container
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// PageImage is an image of a gallery or smart album page
+type PageImage struct {
+	ID               string  `db:"id" json:"id"`
+	Gallery          string  `db:"gallery" json:"gallery"`
+	File             string  `db:"image" json:"file"`
+	OriginalFilename string  `db:"original_filename" json:"original_filename"`
+	Caption          string  `db:"caption" json:"caption"`
+	Camera           string  `db:"camera" json:"camera"`
+	Lens             string  `db:"lens" json:"lens"`
+	FocalLength      float64 `db:"focal_length" json:"focal_length"`
+	TakenAt          string  `db:"taken_at" json:"taken_at"`
+	Likes            int     `db:"likes" json:"likes"`
+	Created          string  `db:"created" json:"created"`
+}
+
+// ImagePage is a page of the images of a gallery or smart album
+type ImagePage struct {
+	Page       int         `json:"page"`
+	PerPage    int         `json:"perPage"`
+	TotalItems int         `json:"totalItems"`
+	Sort       string      `json:"sort"`
+	Items      []PageImage `json:"items"`
+}
+
+// imageSorts maps the sort modes to their ORDER BY clause. The manual order
+// depends on the query, see imageQuery.
+var imageSorts = map[string]string{
+	validation.ImageSortNewest:   "i.created DESC",
+	validation.ImageSortOldest:   "i.created ASC",
+	validation.ImageSortCaptured: capturedExpr + " DESC",
+	validation.ImageSortLikes:    "i.likes DESC, i.created DESC",
+	validation.ImageSortFilename: "i.original_filename COLLATE NOCASE ASC",
+}
+
+// imageQuery selects images under the alias i, with the gallery they are listed in
+type imageQuery struct {
+	// from joins the images with their galleries
+	from    string
+	gallery string
+	// manual is the ORDER BY clause of the manual sort, empty if there is none
+	manual     string
+	conditions []string
+	params     dbx.Params
+}
+
+// page loads a page of the query's images in the requested sort mode
+func (q *imageQuery) page(app core.App, req *validation.ImagePageRequest) (*ImagePage, error) {
+	order := imageSorts[req.Sort]
+	if req.Sort == validation.ImageSortManual {
+		order = q.manual
+	}
+
+	result := &ImagePage{
+		Page:    req.Page,
+		PerPage: req.PerPage,
+		Sort:    req.Sort,
+		Items:   []PageImage{},
+	}
+
+	err := app.DB().NewQuery("SELECT count(*)" + q.from + whereClause(q.conditions)).Bind(q.params).Row(&result.TotalItems)
+	if err != nil {
+		return nil, errors.InternalError("Failed to count images", err)
+	}
+
+	err = app.DB().NewQuery(
+		"SELECT i.id, " + q.gallery + " AS gallery, i.image, i.original_filename, i.caption, i.camera, i.lens," +
+			" i.focal_length, i.taken_at, i.likes, i.created" + q.from + whereClause(q.conditions) +
+			" ORDER BY " + order + ", i.id LIMIT {:limit} OFFSET {:offset}",
+	).Bind(q.params).Bind(dbx.Params{
+		"limit":  req.PerPage,
+		"offset": (req.Page - 1) * req.PerPage,
+	}).All(&result.Items)
+	if err != nil {
+		return nil, errors.InternalError("Failed to load images", err)
+	}
+
+	return result, nil
+}
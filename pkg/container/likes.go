@@ -0,0 +1,170 @@
+package container
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// LikeStatus is the like count of an image and whether the client likes it
+type LikeStatus struct {
+	Image string `json:"image"`
+	Likes int    `json:"likes"`
+	Liked bool   `json:"liked"`
+}
+
+// LikeServiceImpl implements LikeService
+type LikeServiceImpl struct {
+	app *pocketbase.PocketBase
+}
+
+func NewLikeService(app *pocketbase.PocketBase) LikeService {
+	return &LikeServiceImpl{app: app}
+}
+
+func (s *LikeServiceImpl) Like(client *GalleryClient, imageID string) (*LikeStatus, error) {
+	if err := s.checkClient(client, imageID); err != nil {
+		return nil, err
+	}
+
+	collection, err := s.app.FindCollectionByNameOrId("likes")
+	if err != nil {
+		return nil, errors.InternalError("Failed to find likes collection", err)
+	}
+
+	var image *core.Record
+
+	transactErr := s.app.RunInTransaction(func(txApp core.App) error {
+		like, err := s.findLike(txApp, client, imageID)
+		if err != nil {
+			return err
+		}
+
+		// liking twice is a no-op
+		created := like == nil
+		if created {
+			like = core.NewRecord(collection)
+			like.Set("image", imageID)
+			if client.Share != nil {
+				like.Set("share", client.Share.Share.Id)
+			} else {
+				like.Set("user", client.Info.Auth.Id)
+			}
+
+			if err := txApp.Save(like); err != nil {
+				return errors.InternalError("Failed to save like", err)
+			}
+
+			if err := s.addLikes(txApp, imageID, 1); err != nil {
+				return err
+			}
+		}
+
+		image, err = s.refreshImage(txApp, imageID, created)
+		return err
+	})
+	if transactErr != nil {
+		return nil, transactErr
+	}
+
+	return &LikeStatus{Image: image.Id, Likes: image.GetInt("likes"), Liked: true}, nil
+}
+
+func (s *LikeServiceImpl) Unlike(client *GalleryClient, imageID string) (*LikeStatus, error) {
+	if err := s.checkClient(client, imageID); err != nil {
+		return nil, err
+	}
+
+	var image *core.Record
+
+	transactErr := s.app.RunInTransaction(func(txApp core.App) error {
+		like, err := s.findLike(txApp, client, imageID)
+		if err != nil {
+			return err
+		}
+
+		if like != nil {
+			if err := txApp.Delete(like); err != nil {
+				return errors.InternalError("Failed to remove like", err)
+			}
+
+			if err := s.addLikes(txApp, imageID, -1); err != nil {
+				return err
+			}
+		}
+
+		image, err = s.refreshImage(txApp, imageID, like != nil)
+		return err
+	})
+	if transactErr != nil {
+		return nil, transactErr
+	}
+
+	return &LikeStatus{Image: image.Id, Likes: image.GetInt("likes"), Liked: false}, nil
+}
+
+// checkClient makes sure the client can see the image's gallery and, for share links, like it
+func (s *LikeServiceImpl) checkClient(client *GalleryClient, imageID string) error {
+	gallery := FindImageGallery(s.app, imageID)
+	if gallery == nil {
+		return errors.NotFound("Image not found")
+	}
+
+	_, err := ClientGallery(s.app, client, gallery.Id, validation.SharePermissionLike)
+	return err
+}
+
+// findLike returns the client's like of the image, or nil if there is none
+func (s *LikeServiceImpl) findLike(app core.App, client *GalleryClient, imageID string) (*core.Record, error) {
+	filter := "image = {:image} && user = {:client}"
+	params := dbx.Params{"image": imageID}
+	if client.Share != nil {
+		filter = "image = {:image} && share = {:client}"
+		params["client"] = client.Share.Share.Id
+	} else {
+		params["client"] = client.Info.Auth.Id
+	}
+
+	records, err := app.FindRecordsByFilter("likes", filter, "", 1, 0, params)
+	if err != nil {
+		return nil, errors.InternalError("Failed to load like", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	return records[0], nil
+}
+
+// addLikes changes the counter in SQL so concurrent likes never overwrite each other
+func (s *LikeServiceImpl) addLikes(app core.App, imageID string, delta int) error {
+	_, err := app.DB().NewQuery("UPDATE {{images}} SET [[likes]] = MAX(COALESCE([[likes]], 0) + {:delta}, 0) WHERE [[id]] = {:id}").
+		Bind(dbx.Params{"delta": delta, "id": imageID}).
+		Execute()
+	if err != nil {
+		return errors.InternalError("Failed to update likes", err)
+	}
+	return nil
+}
+
+// refreshImage reloads the image with the current counter. Changed images are
+// saved again so realtime subscribers are notified of the new count.
+func (s *LikeServiceImpl) refreshImage(app core.App, imageID string, changed bool) (*core.Record, error) {
+	image, err := app.FindRecordById("images", imageID)
+	if err != nil {
+		return nil, errors.NotFound("Image not found")
+	}
+
+	if !changed {
+		return image, nil
+	}
+
+	if err := app.Save(image); err != nil {
+		return nil, errors.InternalError("Failed to update image", err)
+	}
+
+	return image, nil
+}
@@ -0,0 +1,62 @@
+package container
+
+import (
+	"sync"
+	"time"
+)
+
+// AttemptLimiter counts attempts per client within a fixed window
+type AttemptLimiter struct {
+	mu      sync.Mutex
+	max     int
+	window  time.Duration
+	clients map[string]*attemptWindow
+}
+
+type attemptWindow struct {
+	count int
+	reset time.Time
+}
+
+// NewAttemptLimiter allows max attempts per client within the window
+func NewAttemptLimiter(max int, window time.Duration) *AttemptLimiter {
+	return &AttemptLimiter{max: max, window: window, clients: map[string]*attemptWindow{}}
+}
+
+// Blocked reports whether the client used up its attempts in the current window
+func (l *AttemptLimiter) Blocked(client string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.clients[client]
+	if !ok {
+		return false
+	}
+	if time.Now().After(w.reset) {
+		delete(l.clients, client)
+		return false
+	}
+	return w.count >= l.max
+}
+
+// Add counts an attempt of the client
+func (l *AttemptLimiter) Add(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	// drop expired windows so the map doesn't grow unbounded
+	for key, w := range l.clients {
+		if now.After(w.reset) {
+			delete(l.clients, key)
+		}
+	}
+
+	w, ok := l.clients[client]
+	if !ok {
+		w = &attemptWindow{reset: now.Add(l.window)}
+		l.clients[client] = w
+	}
+	w.count++
+}
@@ -0,0 +1,259 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+
+	"github.com/dorianlgs/photo-cifu/pkg/config"
+	"github.com/dorianlgs/photo-cifu/pkg/enhance"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/iiif"
+	"github.com/dorianlgs/photo-cifu/workflow"
+)
+
+// ImageProcessorImpl implements workflow.ImageProcessor
+type ImageProcessorImpl struct {
+	app   *pocketbase.PocketBase
+	cfg   *config.Config
+	cache *iiif.Cache
+}
+
+func NewImageProcessor(app *pocketbase.PocketBase, cfg *config.Config, cache *iiif.Cache) workflow.ImageProcessor {
+	return &ImageProcessorImpl{app: app, cfg: cfg, cache: cache}
+}
+
+// ProcessImage reads the metadata and hash of an image and renders its
+// derivatives into the IIIF cache. Processing an image again only renders
+// the derivatives missing from the cache.
+//
+// Files that cannot be decoded are reported in the result rather than
+// returned as errors, retrying them would fail the same way.
+func (p *ImageProcessorImpl) ProcessImage(ctx context.Context, imageID string) (*workflow.ImageResult, error) {
+	image, err := p.app.FindRecordById("images", imageID)
+	if err != nil || image.GetString("image") == "" {
+		return &workflow.ImageResult{ImageID: imageID, Error: "image not found"}, nil
+	}
+
+	data, err := readRecordFile(p.app, image, image.GetString("image"))
+	if err != nil {
+		return nil, err
+	}
+
+	SetImageMetadata(image, data)
+	if err := p.app.Save(image); err != nil {
+		return nil, errors.InternalError("Failed to save image", err)
+	}
+
+	result := &workflow.ImageResult{
+		ImageID: image.Id,
+		Size:    int64(len(data)),
+		SHA256:  image.GetString("sha256"),
+	}
+
+	result.Width, result.Height, err = iiif.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		result.Error = "failed to decode image: " + err.Error()
+		return result, nil
+	}
+
+	if p.cache == nil {
+		return result, nil
+	}
+
+	for _, size := range p.cfg.Processing.Derivatives {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		req, err := iiif.ParseImageRequest("full", size, "0", "default.jpg")
+		if err != nil {
+			p.app.Logger().Warn("Skipping invalid derivative size", "size", size, "error", err)
+			continue
+		}
+
+		// the same key as IIIFService.RenderImage, so the viewer is served from the cache
+		key := iiif.ImageKey(image.Id, image.GetString("image"), req.String())
+		if _, ok := p.cache.Get(key); !ok {
+			rendered, err := iiif.Render(bytes.NewReader(data), req)
+			if err != nil {
+				result.Error = "failed to render " + size + ": " + err.Error()
+				return result, nil
+			}
+			if err := p.cache.Put(key, rendered); err != nil {
+				return nil, errors.InternalError("Failed to cache derivative", err)
+			}
+		}
+		result.Derivatives++
+	}
+
+	return result, nil
+}
+
+// EnhanceImage renders the enhanced version of an image into its enhanced
+// field, pending review. The image file itself is left untouched.
+func (p *ImageProcessorImpl) EnhanceImage(ctx context.Context, imageID string, options workflow.EnhancementOptions) (*workflow.EnhancementResult, error) {
+	image, err := p.app.FindRecordById("images", imageID)
+	if err != nil || image.GetString("image") == "" {
+		return &workflow.EnhancementResult{ImageID: imageID, Error: "image not found"}, nil
+	}
+
+	// the pending enhancement belongs to another run, which applies it on review
+	if image.GetString("enhancement") == workflow.EnhancementPending {
+		return &workflow.EnhancementResult{ImageID: imageID, Error: "an enhancement is already pending review"}, nil
+	}
+
+	opts := enhance.Options{
+		Operations: options.Operations,
+		MaxWidth:   options.MaxWidth,
+		MaxHeight:  options.MaxHeight,
+	}
+	if err := opts.Validate(); err != nil {
+		return &workflow.EnhancementResult{ImageID: imageID, Error: err.Error()}, nil
+	}
+
+	data, err := readRecordFile(p.app, image, image.GetString("image"))
+	if err != nil {
+		return nil, err
+	}
+
+	enhanced, ext, err := enhance.Enhance(bytes.NewReader(data), image.GetString("image"), opts)
+	if err != nil {
+		return &workflow.EnhancementResult{ImageID: imageID, Error: err.Error()}, nil
+	}
+
+	result := &workflow.EnhancementResult{ImageID: image.Id, Size: int64(len(enhanced))}
+	result.Width, result.Height, _ = iiif.DecodeConfig(bytes.NewReader(enhanced))
+
+	// the enhanced version counts against the storage of the gallery owner,
+	// as does the original it leaves behind once approved
+	if gallery := FindImageGallery(p.app, image.Id); gallery != nil {
+		if err := CheckStorageQuota(p.app, p.cfg, gallery.GetString("owner"), result.Size); err != nil {
+			return &workflow.EnhancementResult{ImageID: imageID, Error: err.Error()}, nil
+		}
+	}
+
+	file, err := filesystem.NewFileFromBytes(enhanced, enhancedFilename(image, ext))
+	if err != nil {
+		return nil, errors.InternalError("Failed to create file", err)
+	}
+
+	// claim the image, unless a concurrent run set its enhancement pending
+	// while this one was rendering
+	claimed := true
+	err = p.app.RunInTransaction(func(txApp core.App) error {
+		image, err := txApp.FindRecordById("images", imageID)
+		if err != nil {
+			return err
+		}
+		if image.GetString("enhancement") == workflow.EnhancementPending {
+			claimed = false
+			return nil
+		}
+
+		image.Set("enhanced", file)
+		image.Set("enhanced_size", result.Size)
+		image.Set("enhancement", workflow.EnhancementPending)
+		return txApp.Save(image)
+	})
+	if err != nil {
+		return nil, errors.InternalError("Failed to save enhanced image", err)
+	}
+	if !claimed {
+		return &workflow.EnhancementResult{ImageID: imageID, Error: "an enhancement is already pending review"}, nil
+	}
+
+	return result, nil
+}
+
+// ReviewEnhancement replaces the file of an image by its enhanced version,
+// keeping the first original in the original field, or discards the enhanced
+// version. Images without a pending enhancement are left as they are, so a
+// retried review is not applied twice.
+func (p *ImageProcessorImpl) ReviewEnhancement(ctx context.Context, imageID string, approved bool) error {
+	image, err := p.app.FindRecordById("images", imageID)
+	if err != nil || image.GetString("enhanced") == "" {
+		return nil
+	}
+
+	if !approved {
+		image.Set("enhanced", "")
+		image.Set("enhanced_size", 0)
+		image.Set("enhancement", workflow.EnhancementRejected)
+		if err := p.app.Save(image); err != nil {
+			return errors.InternalError("Failed to save image", err)
+		}
+		return nil
+	}
+
+	enhanced, err := readRecordFile(p.app, image, image.GetString("enhanced"))
+	if err != nil {
+		return err
+	}
+
+	if image.GetString("original") == "" {
+		data, err := readRecordFile(p.app, image, image.GetString("image"))
+		if err != nil {
+			return err
+		}
+		original, err := filesystem.NewFileFromBytes(data, uploadedFilename(image))
+		if err != nil {
+			return errors.InternalError("Failed to create file", err)
+		}
+		image.Set("original", original)
+		image.Set("original_size", len(data))
+	}
+
+	file, err := filesystem.NewFileFromBytes(enhanced, enhancedFilename(image, path.Ext(image.GetString("enhanced"))))
+	if err != nil {
+		return errors.InternalError("Failed to create file", err)
+	}
+	image.Set("image", file)
+	image.Set("enhanced", "")
+	image.Set("enhanced_size", 0)
+	image.Set("enhancement", workflow.EnhancementApproved)
+	// the EXIF metadata of the original still describes the photo
+	setFileMetadata(image, enhanced)
+
+	if err := p.app.Save(image); err != nil {
+		return errors.InternalError("Failed to save image", err)
+	}
+
+	return nil
+}
+
+// readRecordFile reads one of the stored files of a record
+func readRecordFile(app core.App, record *core.Record, filename string) ([]byte, error) {
+	reader, err := openRecordFile(app, record, filename)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.InternalError("Failed to read file", err)
+	}
+
+	return data, nil
+}
+
+// enhancedFilename names the enhanced version after the uploaded file
+func enhancedFilename(image *core.Record, ext string) string {
+	name := uploadedFilename(image)
+	return strings.TrimSuffix(name, path.Ext(name)) + "_enhanced" + ext
+}
+
+// uploadedFilename is the name of the file the image was uploaded as
+func uploadedFilename(image *core.Record) string {
+	if name := image.GetString("original_filename"); name != "" {
+		return path.Base(name)
+	}
+	return image.GetString("image")
+}
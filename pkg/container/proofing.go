@@ -0,0 +1,467 @@
+package container
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"net/mail"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// Selection statuses. Open selections can be changed by the client, submitting
+// locks them until the owner reopens or approves them.
+const (
+	SelectionStatusOpen      = "open"
+	SelectionStatusSubmitted = "submitted"
+	SelectionStatusApproved  = "approved"
+)
+
+// Selection export formats
+const (
+	ExportFormatCSV       = "csv"
+	ExportFormatLightroom = "lightroom"
+)
+
+// Selection is a client's pick list for a gallery
+type Selection struct {
+	ID          string          `json:"id"`
+	Gallery     string          `json:"gallery"`
+	Status      string          `json:"status"`
+	ClientName  string          `json:"client_name"`
+	MaxPicks    int             `json:"max_picks"`
+	Picks       []SelectionPick `json:"picks"`
+	SubmittedAt string          `json:"submitted_at"`
+}
+
+// SelectionPick is a picked image with the client's note
+type SelectionPick struct {
+	Image    string `json:"image"`
+	Filename string `json:"filename"`
+	Note     string `json:"note"`
+}
+
+// SelectionExport is a rendered selection export
+type SelectionExport struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// ProofingServiceImpl implements ProofingService
+type ProofingServiceImpl struct {
+	app *pocketbase.PocketBase
+}
+
+func NewProofingService(app *pocketbase.PocketBase) ProofingService {
+	return &ProofingServiceImpl{app: app}
+}
+
+func (s *ProofingServiceImpl) GetSelection(client *GalleryClient, galleryID string) (*Selection, error) {
+	gallery, err := ClientGallery(s.app, client, galleryID, validation.SharePermissionView)
+	if err != nil {
+		return nil, err
+	}
+
+	selection, err := s.findSelection(s.app, client, gallery)
+	if err != nil {
+		return nil, err
+	}
+
+	// nothing has been picked yet
+	if selection == nil {
+		return &Selection{
+			Gallery:  gallery.Id,
+			Status:   SelectionStatusOpen,
+			MaxPicks: gallery.GetInt("max_picks"),
+			Picks:    []SelectionPick{},
+		}, nil
+	}
+
+	return s.buildSelection(gallery, selection)
+}
+
+func (s *ProofingServiceImpl) Pick(client *GalleryClient, galleryID, imageID string, req *validation.SelectionPickRequest) (*Selection, error) {
+	gallery, err := ClientGallery(s.app, client, galleryID, validation.SharePermissionView)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(gallery.GetStringSlice("images"), imageID) {
+		return nil, errors.NotFound("Image not found")
+	}
+
+	itemsCollection, err := s.app.FindCollectionByNameOrId("selection_items")
+	if err != nil {
+		return nil, errors.InternalError("Failed to find selection items collection", err)
+	}
+
+	var selection *core.Record
+
+	// counting and inserting in one transaction keeps concurrent picks within the limit
+	transactErr := s.app.RunInTransaction(func(txApp core.App) error {
+		selection, err = s.openSelection(txApp, client, gallery)
+		if err != nil {
+			return err
+		}
+
+		item, _ := txApp.FindFirstRecordByFilter(
+			"selection_items",
+			"selection = {:selection} && image = {:image}",
+			dbx.Params{"selection": selection.Id, "image": imageID},
+		)
+
+		if item == nil {
+			if maxPicks := gallery.GetInt("max_picks"); maxPicks > 0 {
+				picks, err := txApp.CountRecords("selection_items", dbx.HashExp{"selection": selection.Id})
+				if err != nil {
+					return errors.InternalError("Failed to count picks", err)
+				}
+				if picks >= int64(maxPicks) {
+					return errors.ValidationError(fmt.Sprintf("Your package includes up to %d images", maxPicks), nil)
+				}
+			}
+
+			item = core.NewRecord(itemsCollection)
+			item.Set("selection", selection.Id)
+			item.Set("image", imageID)
+		}
+
+		item.Set("note", req.Note)
+
+		if err := txApp.Save(item); err != nil {
+			return errors.InternalError("Failed to save pick", err)
+		}
+
+		return nil
+	})
+	if transactErr != nil {
+		return nil, transactErr
+	}
+
+	return s.buildSelection(gallery, selection)
+}
+
+func (s *ProofingServiceImpl) Unpick(client *GalleryClient, galleryID, imageID string) (*Selection, error) {
+	gallery, err := ClientGallery(s.app, client, galleryID, validation.SharePermissionView)
+	if err != nil {
+		return nil, err
+	}
+
+	selection, err := s.findSelection(s.app, client, gallery)
+	if err != nil {
+		return nil, err
+	}
+	if selection == nil {
+		return nil, errors.NotFound("Image is not picked")
+	}
+
+	if selection.GetString("status") != SelectionStatusOpen {
+		return nil, errors.BadRequest("The selection has been submitted and can no longer be changed", nil)
+	}
+
+	item, err := s.app.FindFirstRecordByFilter(
+		"selection_items",
+		"selection = {:selection} && image = {:image}",
+		dbx.Params{"selection": selection.Id, "image": imageID},
+	)
+	if err != nil {
+		return nil, errors.NotFound("Image is not picked")
+	}
+
+	if err := s.app.Delete(item); err != nil {
+		return nil, errors.InternalError("Failed to remove pick", err)
+	}
+
+	return s.buildSelection(gallery, selection)
+}
+
+func (s *ProofingServiceImpl) Submit(client *GalleryClient, galleryID string, req *validation.SelectionSubmitRequest) (*Selection, error) {
+	gallery, err := ClientGallery(s.app, client, galleryID, validation.SharePermissionView)
+	if err != nil {
+		return nil, err
+	}
+
+	var selection *core.Record
+	var picks int64
+
+	// the status check and update happen in one transaction so no pick slips in after submitting
+	transactErr := s.app.RunInTransaction(func(txApp core.App) error {
+		selection, err = s.findSelection(txApp, client, gallery)
+		if err != nil {
+			return err
+		}
+		if selection == nil {
+			return errors.ValidationError("Pick at least one image before submitting", nil)
+		}
+
+		if selection.GetString("status") != SelectionStatusOpen {
+			return errors.BadRequest("The selection has already been submitted", nil)
+		}
+
+		picks, err = txApp.CountRecords("selection_items", dbx.HashExp{"selection": selection.Id})
+		if err != nil {
+			return errors.InternalError("Failed to count picks", err)
+		}
+		if picks == 0 {
+			return errors.ValidationError("Pick at least one image before submitting", nil)
+		}
+
+		selection.Set("status", SelectionStatusSubmitted)
+		selection.Set("submitted_at", types.NowDateTime())
+		if req.ClientName != "" {
+			selection.Set("client_name", req.ClientName)
+		}
+
+		if err := txApp.Save(selection); err != nil {
+			return errors.InternalError("Failed to submit selection", err)
+		}
+
+		return nil
+	})
+	if transactErr != nil {
+		return nil, transactErr
+	}
+
+	s.notifyOwner(gallery, selection, int(picks))
+
+	return s.buildSelection(gallery, selection)
+}
+
+func (s *ProofingServiceImpl) Reopen(info *core.RequestInfo, selectionID string) (*Selection, error) {
+	return s.transition(info, selectionID, SelectionStatusSubmitted, SelectionStatusOpen)
+}
+
+func (s *ProofingServiceImpl) Approve(info *core.RequestInfo, selectionID string) (*Selection, error) {
+	return s.transition(info, selectionID, SelectionStatusSubmitted, SelectionStatusApproved)
+}
+
+func (s *ProofingServiceImpl) Export(info *core.RequestInfo, selectionID, format string) (*SelectionExport, error) {
+	selection, gallery, err := s.managedSelection(info, selectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	view, err := s.buildSelection(gallery, selection)
+	if err != nil {
+		return nil, err
+	}
+
+	basename := fmt.Sprintf("selection-%s-%s", gallery.Id, selection.Id)
+
+	switch format {
+	case ExportFormatCSV:
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"filename", "note"})
+		for _, pick := range view.Picks {
+			w.Write([]string{pick.Filename, pick.Note})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return nil, errors.InternalError("Failed to write CSV", err)
+		}
+		return &SelectionExport{Filename: basename + ".csv", ContentType: "text/csv; charset=utf-8", Data: buf.Bytes()}, nil
+
+	case ExportFormatLightroom:
+		// Lightroom's library filter matches any of the comma separated names
+		// with "Filename contains", extensions are left out to also match raw files
+		names := make([]string, 0, len(view.Picks))
+		for _, pick := range view.Picks {
+			names = append(names, strings.TrimSuffix(pick.Filename, path.Ext(pick.Filename)))
+		}
+		return &SelectionExport{
+			Filename:    basename + ".txt",
+			ContentType: "text/plain; charset=utf-8",
+			Data:        []byte(strings.Join(names, ", ")),
+		}, nil
+	}
+
+	return nil, errors.ValidationError("Export format must be csv or lightroom", nil)
+}
+
+// transition moves a selection between states on behalf of the gallery owner or an editor
+func (s *ProofingServiceImpl) transition(info *core.RequestInfo, selectionID, from, to string) (*Selection, error) {
+	selection, gallery, err := s.managedSelection(info, selectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if status := selection.GetString("status"); status != from {
+		return nil, errors.BadRequest(fmt.Sprintf("Only %s selections can be %s", from, to), nil)
+	}
+
+	selection.Set("status", to)
+	if err := s.app.Save(selection); err != nil {
+		return nil, errors.InternalError("Failed to update selection", err)
+	}
+
+	return s.buildSelection(gallery, selection)
+}
+
+func (s *ProofingServiceImpl) managedSelection(info *core.RequestInfo, selectionID string) (*core.Record, *core.Record, error) {
+	selection, err := s.app.FindRecordById("selections", selectionID)
+	if err != nil {
+		return nil, nil, errors.NotFound("Selection not found")
+	}
+
+	gallery, err := s.app.FindRecordById("galleries", selection.GetString("gallery"))
+	if err != nil {
+		return nil, nil, errors.NotFound("Selection not found")
+	}
+
+	if err := CheckGalleryRole(s.app, info, gallery, RoleOwner, RoleEditor); err != nil {
+		return nil, nil, err
+	}
+
+	return selection, gallery, nil
+}
+
+// findSelection returns the client's selection for the gallery, or nil if there is none yet
+func (s *ProofingServiceImpl) findSelection(app core.App, client *GalleryClient, gallery *core.Record) (*core.Record, error) {
+	filter := "gallery = {:gallery} && user = {:client}"
+	params := dbx.Params{"gallery": gallery.Id}
+	if client.Share != nil {
+		filter = "gallery = {:gallery} && share = {:client}"
+		params["client"] = client.Share.Share.Id
+	} else {
+		params["client"] = client.Info.Auth.Id
+	}
+
+	records, err := app.FindRecordsByFilter("selections", filter, "", 1, 0, params)
+	if err != nil {
+		return nil, errors.InternalError("Failed to load selection", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	return records[0], nil
+}
+
+// openSelection returns the client's selection, creating it on the first pick,
+// and fails if it has been submitted
+func (s *ProofingServiceImpl) openSelection(app core.App, client *GalleryClient, gallery *core.Record) (*core.Record, error) {
+	selection, err := s.findSelection(app, client, gallery)
+	if err != nil {
+		return nil, err
+	}
+
+	if selection != nil {
+		if selection.GetString("status") != SelectionStatusOpen {
+			return nil, errors.BadRequest("The selection has been submitted and can no longer be changed", nil)
+		}
+		return selection, nil
+	}
+
+	collection, err := app.FindCollectionByNameOrId("selections")
+	if err != nil {
+		return nil, errors.InternalError("Failed to find selections collection", err)
+	}
+
+	selection = core.NewRecord(collection)
+	selection.Set("gallery", gallery.Id)
+	selection.Set("status", SelectionStatusOpen)
+	if client.Share != nil {
+		selection.Set("share", client.Share.Share.Id)
+		selection.Set("client_name", client.Share.Share.GetString("label"))
+	} else {
+		selection.Set("user", client.Info.Auth.Id)
+		selection.Set("client_name", client.Info.Auth.GetString("name"))
+	}
+
+	if err := app.Save(selection); err != nil {
+		return nil, errors.InternalError("Failed to create selection", err)
+	}
+
+	return selection, nil
+}
+
+func (s *ProofingServiceImpl) buildSelection(gallery, selection *core.Record) (*Selection, error) {
+	items, err := s.app.FindRecordsByFilter(
+		"selection_items",
+		"selection = {:selection}",
+		"created",
+		0,
+		0,
+		dbx.Params{"selection": selection.Id},
+	)
+	if err != nil {
+		return nil, errors.InternalError("Failed to load picks", err)
+	}
+
+	if errs := s.app.ExpandRecords(items, []string{"image"}, nil); len(errs) > 0 {
+		return nil, errors.InternalError("Failed to load picked images", nil)
+	}
+
+	view := &Selection{
+		ID:         selection.Id,
+		Gallery:    gallery.Id,
+		Status:     selection.GetString("status"),
+		ClientName: selection.GetString("client_name"),
+		MaxPicks:   gallery.GetInt("max_picks"),
+		Picks:      make([]SelectionPick, 0, len(items)),
+	}
+	if submitted := selection.GetDateTime("submitted_at"); !submitted.IsZero() {
+		view.SubmittedAt = submitted.String()
+	}
+
+	for _, item := range items {
+		pick := SelectionPick{Image: item.GetString("image"), Note: item.GetString("note")}
+		if image := item.ExpandedOne("image"); image != nil {
+			pick.Filename = image.GetString("original_filename")
+			if pick.Filename == "" {
+				pick.Filename = image.GetString("image")
+			}
+		}
+		view.Picks = append(view.Picks, pick)
+	}
+
+	return view, nil
+}
+
+// notifyOwner leaves a message for the gallery owner and emails them about a submitted selection
+func (s *ProofingServiceImpl) notifyOwner(gallery, selection *core.Record, picks int) {
+	owner, err := s.app.FindRecordById("users", gallery.GetString("owner"))
+	if err != nil {
+		return
+	}
+
+	client := selection.GetString("client_name")
+	if client == "" {
+		client = "A client"
+	}
+	text := fmt.Sprintf("%s submitted a selection of %d images for %s", client, picks, gallery.GetString("name"))
+
+	if collection, err := s.app.FindCollectionByNameOrId("messages"); err == nil {
+		message := core.NewRecord(collection)
+		message.Set("text", text)
+		message.Set("user", owner.Id)
+		if err := s.app.Save(message); err != nil {
+			s.app.Logger().Warn("Failed to save selection notification", "selectionID", selection.Id, "error", err)
+		}
+	}
+
+	meta := s.app.Settings().Meta
+	err = s.app.NewMailClient().Send(&mailer.Message{
+		From:    mail.Address{Name: meta.SenderName, Address: meta.SenderAddress},
+		To:      []mail.Address{{Address: owner.Email()}},
+		Subject: fmt.Sprintf("New selection for %s", gallery.GetString("name")),
+		HTML:    fmt.Sprintf("<p>%s.</p><p>Submitted on %s.</p>", html.EscapeString(text), time.Now().UTC().Format("2006-01-02 15:04 MST")),
+	})
+	if err != nil {
+		s.app.Logger().Warn("Failed to email selection notification", "selectionID", selection.Id, "error", err)
+	}
+}
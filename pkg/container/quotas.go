@@ -0,0 +1,309 @@
+package container
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/list"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/dorianlgs/photo-cifu/pkg/config"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+)
+
+// Usage is what a user consumes against the limits of their plan
+type Usage struct {
+	Plan              string            `json:"plan"`
+	Limits            config.PlanLimits `json:"limits"`
+	StorageBytes      int64             `json:"storage_bytes"`
+	Galleries         int               `json:"galleries"`
+	WorkflowRunsToday int               `json:"workflow_runs_today"`
+	GalleryImages     []GalleryUsage    `json:"gallery_images"`
+}
+
+// GalleryUsage is the number of images in one of the user's galleries
+type GalleryUsage struct {
+	ID     string `json:"id" db:"id"`
+	Name   string `json:"name" db:"name"`
+	Images int    `json:"images" db:"images"`
+}
+
+// QuotaServiceImpl implements QuotaService
+type QuotaServiceImpl struct {
+	app *pocketbase.PocketBase
+	cfg *config.Config
+}
+
+func NewQuotaService(app *pocketbase.PocketBase, cfg *config.Config) QuotaService {
+	return &QuotaServiceImpl{app: app, cfg: cfg}
+}
+
+// Usage reports the storage, galleries and workflow runs of the user against their plan
+func (s *QuotaServiceImpl) Usage(auth *core.Record) (*Usage, error) {
+	if auth == nil || auth.Collection().Name != "users" {
+		return nil, errors.BadRequest("Usage is only reported for user accounts", nil)
+	}
+
+	plan, limits := UserPlan(s.app, s.cfg, auth.Id)
+	usage := &Usage{Plan: plan, Limits: limits, GalleryImages: []GalleryUsage{}}
+
+	var err error
+	if usage.StorageBytes, err = storageUsed(s.app, auth.Id); err != nil {
+		return nil, errors.InternalError("Failed to compute storage usage", err)
+	}
+
+	if usage.WorkflowRunsToday, err = workflowRunsToday(s.app, auth.Id); err != nil {
+		return nil, errors.InternalError("Failed to count workflow runs", err)
+	}
+
+	err = s.app.DB().NewQuery(
+		"SELECT id, name, json_array_length(images) AS images FROM galleries" +
+			" WHERE owner = {:user} AND deleted_at = '' ORDER BY images DESC, name",
+	).Bind(dbx.Params{"user": auth.Id}).All(&usage.GalleryImages)
+	if err != nil {
+		return nil, errors.InternalError("Failed to count gallery images", err)
+	}
+	usage.Galleries = len(usage.GalleryImages)
+
+	return usage, nil
+}
+
+// UserPlan returns the plan of a user and its limits. Users without a plan, or
+// with one that is not configured, are on the default plan. The images per
+// gallery limit never goes past Gallery.MaxImages.
+func UserPlan(app core.App, cfg *config.Config, userID string) (string, config.PlanLimits) {
+	plan := cfg.Plans.Default
+	if user, err := app.FindRecordById("users", userID); err == nil {
+		if _, ok := cfg.Plans.Limits[user.GetString("plan")]; ok {
+			plan = user.GetString("plan")
+		}
+	}
+
+	limits := cfg.Plans.Limits[plan]
+	if limits.ImagesPerGallery == 0 || limits.ImagesPerGallery > cfg.Gallery.MaxImages {
+		limits.ImagesPerGallery = cfg.Gallery.MaxImages
+	}
+
+	return plan, limits
+}
+
+// GalleryImageLimit returns the number of images a gallery can hold under its owner's plan
+func GalleryImageLimit(app core.App, cfg *config.Config, gallery *core.Record) int {
+	return imageLimit(app, cfg, gallery.GetString("owner"))
+}
+
+func imageLimit(app core.App, cfg *config.Config, ownerID string) int {
+	if ownerID == "" {
+		return cfg.Gallery.MaxImages
+	}
+	_, limits := UserPlan(app, cfg, ownerID)
+	return limits.ImagesPerGallery
+}
+
+// CheckGalleryQuota fails when the user already has as many galleries as their plan allows
+func CheckGalleryQuota(app core.App, cfg *config.Config, userID string) error {
+	plan, limits := UserPlan(app, cfg, userID)
+	if limits.Galleries == 0 {
+		return nil
+	}
+
+	count, err := app.CountRecords("galleries", dbx.HashExp{"owner": userID, "deleted_at": ""})
+	if err != nil {
+		return errors.InternalError("Failed to count galleries", err)
+	}
+
+	if int(count) >= limits.Galleries {
+		return errors.Forbidden(fmt.Sprintf("The %s plan allows up to %d galleries", plan, limits.Galleries))
+	}
+
+	return nil
+}
+
+// CheckStorageQuota fails when storing added more bytes would take the user past
+// the storage of their plan. Trashed images count until they are purged.
+func CheckStorageQuota(app core.App, cfg *config.Config, userID string, added int64) error {
+	if userID == "" || added <= 0 {
+		return nil
+	}
+
+	plan, limits := UserPlan(app, cfg, userID)
+	if limits.StorageBytes == 0 {
+		return nil
+	}
+
+	used, err := storageUsed(app, userID)
+	if err != nil {
+		return errors.InternalError("Failed to compute storage usage", err)
+	}
+
+	if used+added > limits.StorageBytes {
+		return errors.Forbidden(fmt.Sprintf(
+			"The %s plan includes %s of storage, %s are used",
+			plan, formatBytes(limits.StorageBytes), formatBytes(used),
+		))
+	}
+
+	return nil
+}
+
+// CheckGalleryImages fails when the user saving a gallery through the records
+// API adds images they can't edit, when its images go past the images per
+// gallery of its owner's plan, or when the images added to it take the owner
+// past their storage
+func CheckGalleryImages(app core.App, cfg *config.Config, auth *core.Record, gallery *core.Record, previous []string) error {
+	images := gallery.GetStringSlice("images")
+	added := list.SubtractSlice(images, previous)
+	if len(added) == 0 {
+		return nil
+	}
+
+	foreign, err := foreignImages(app, auth.Id, added)
+	if err != nil {
+		return errors.InternalError("Failed to check gallery images", err)
+	}
+	if foreign > 0 {
+		return errors.Forbidden("You can only add images you uploaded or that are in galleries you own or edit")
+	}
+
+	if limit := GalleryImageLimit(app, cfg, gallery); len(images) > limit {
+		return errors.ValidationError(fmt.Sprintf("Gallery cannot contain more than %d images", limit), nil)
+	}
+
+	size, err := addedStorage(app, gallery.GetString("owner"), added)
+	if err != nil {
+		return errors.InternalError("Failed to compute storage usage", err)
+	}
+
+	return CheckStorageQuota(app, cfg, gallery.GetString("owner"), size)
+}
+
+// CheckWorkflowQuota fails when the user has started as many workflows today as their plan allows
+func CheckWorkflowQuota(app core.App, cfg *config.Config, userID string) error {
+	plan, limits := UserPlan(app, cfg, userID)
+	if limits.WorkflowRunsPerDay == 0 {
+		return nil
+	}
+
+	runs, err := workflowRunsToday(app, userID)
+	if err != nil {
+		return errors.InternalError("Failed to count workflow runs", err)
+	}
+
+	if runs >= limits.WorkflowRunsPerDay {
+		return errors.TooManyRequests(fmt.Sprintf("The %s plan allows %d workflow runs per day", plan, limits.WorkflowRunsPerDay))
+	}
+
+	return nil
+}
+
+// storageUsed sums the size of the images in the user's galleries, with their
+// enhanced and original files, including the trash and counting images listed
+// in several galleries once
+func storageUsed(app core.App, userID string) (int64, error) {
+	var used int64
+	err := app.DB().NewQuery(
+		"SELECT coalesce(sum(size + enhanced_size + original_size), 0) FROM images WHERE id IN (" +
+			"SELECT j.value FROM galleries g, json_each(g.images) j WHERE g.owner = {:user}" +
+			") OR deleted_from IN (SELECT id FROM galleries WHERE owner = {:user})",
+	).Bind(dbx.Params{"user": userID}).Row(&used)
+	return used, err
+}
+
+// workflowRunsToday counts the workflows the user started since midnight UTC
+func workflowRunsToday(app core.App, userID string) (int, error) {
+	midnight, err := types.ParseDateTime(time.Now().UTC().Truncate(24 * time.Hour))
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := app.CountRecords(
+		"workflow_runs",
+		dbx.HashExp{"user": userID},
+		dbx.NewExp("created >= {:midnight}", dbx.Params{"midnight": midnight.String()}),
+	)
+	return int(count), err
+}
+
+// addedStorage sums the size of the images that are not counted in the user's
+// storage yet, images already in another of their galleries are left out
+func addedStorage(app core.App, userID string, imageIDs []string) (int64, error) {
+	ids := make([]any, len(imageIDs))
+	for i, id := range imageIDs {
+		ids[i] = id
+	}
+
+	var size int64
+	err := app.DB().Select("coalesce(sum(size + enhanced_size + original_size), 0)").
+		From("images").
+		Where(dbx.In("id", ids...)).
+		AndWhere(dbx.NewExp(
+			"id NOT IN (SELECT j.value FROM galleries g, json_each(g.images) j WHERE g.owner = {:user})",
+			dbx.Params{"user": userID},
+		)).
+		Row(&size)
+	return size, err
+}
+
+// foreignImages counts the images the user can't add to a gallery: the ones
+// that are neither in a gallery they own or edit, nor uploaded by them and in
+// no gallery yet
+func foreignImages(app core.App, userID string, imageIDs []string) (int, error) {
+	ids := make([]any, len(imageIDs))
+	for i, id := range imageIDs {
+		ids[i] = id
+	}
+
+	var count int
+	err := app.DB().Select("count(*)").
+		From("images").
+		Where(dbx.In("id", ids...)).
+		AndWhere(dbx.NewExp(
+			"deleted_at != '' OR ("+
+				"id NOT IN (SELECT j.value FROM galleries g, json_each(g.images) j WHERE g.deleted_at = '' AND "+
+				"(g.owner = {:user} OR g.id IN (SELECT gallery FROM collaborators WHERE user = {:user} AND role = {:editor})))"+
+				" AND (uploader != {:user} OR id IN (SELECT j.value FROM galleries g, json_each(g.images) j)))",
+			dbx.Params{"user": userID, "editor": RoleEditor},
+		)).
+		Row(&count)
+	return count, err
+}
+
+// uploadedSize is the size of the image file uploaded with an image record
+func uploadedSize(image *core.Record) int64 {
+	var size int64
+	for _, file := range image.GetUnsavedFiles("image") {
+		size += file.Size
+	}
+	return size
+}
+
+// imageStorage is the number of bytes an image takes in storage with its
+// enhanced and original files
+func imageStorage(image *core.Record) int64 {
+	return int64(image.GetInt("size") + image.GetInt("enhanced_size") + image.GetInt("original_size"))
+}
+
+// imagesSize is the number of bytes the images take in storage
+func imagesSize(images []ImageFile) int64 {
+	var size int64
+	for _, image := range images {
+		size += int64(len(image.Data))
+	}
+	return size
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,226 @@
+package container
+
+import (
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/search"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// maxFacetValues is the number of values returned per facet
+const maxFacetValues = 20
+
+// SearchHit is a gallery or image matching a search
+type SearchHit struct {
+	Kind        string  `json:"kind"`
+	ID          string  `json:"id"`
+	Gallery     string  `json:"gallery"`
+	GalleryName string  `json:"gallery_name"`
+	Title       string  `json:"title"`
+	File        string  `json:"file"`
+	Rank        float64 `json:"rank"`
+}
+
+// FacetCount is the number of hits for a facet value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// SearchResult is a page of search hits with the facet counts of all hits
+type SearchResult struct {
+	Page       int                     `json:"page"`
+	PerPage    int                     `json:"perPage"`
+	TotalItems int                     `json:"totalItems"`
+	Items      []SearchHit             `json:"items"`
+	Facets     map[string][]FacetCount `json:"facets"`
+}
+
+// searchFacets maps the facets to the SQL expression of their value
+var searchFacets = map[string]string{
+	"camera":   "i.camera",
+	"lens":     "i.lens",
+	"year":     "substr(i.taken_at, 1, 4)",
+	"tag":      "t.value",
+	"location": "coalesce(nullif(g.place, ''), g.location)",
+}
+
+// SearchServiceImpl implements SearchService
+type SearchServiceImpl struct {
+	app *pocketbase.PocketBase
+}
+
+func NewSearchService(app *pocketbase.PocketBase) SearchService {
+	return &SearchServiceImpl{app: app}
+}
+
+func (s *SearchServiceImpl) Search(client *GalleryClient, req *validation.SearchRequest) (*SearchResult, error) {
+	params := dbx.Params{}
+
+	// conditions every hit must match
+	var where []string
+
+	if match := matchQuery(req.Query); match != "" {
+		where = append(where, search.Table+" MATCH {:match}")
+		params["match"] = match
+	}
+
+	access, err := galleryAccessFilter(client, "g", params)
+	if err != nil {
+		return nil, err
+	}
+	if access != "" {
+		where = append(where, access)
+	}
+
+	if req.Kind != "" {
+		where = append(where, "s.kind = {:kind}")
+		params["kind"] = req.Kind
+	}
+
+	// facet filters, kept apart so each facet is counted without its own filter
+	filters := map[string]string{}
+	for facet, value := range map[string]string{
+		"camera":   req.Camera,
+		"lens":     req.Lens,
+		"year":     req.Year,
+		"tag":      req.Tag,
+		"location": req.Location,
+	} {
+		if value == "" {
+			continue
+		}
+		params["facet_"+facet] = value
+		if facet == "tag" {
+			filters[facet] = "EXISTS (SELECT 1 FROM json_each(i.tags) WHERE value = {:facet_tag})"
+		} else {
+			filters[facet] = searchFacets[facet] + " = {:facet_" + facet + "}"
+		}
+	}
+
+	from := " FROM " + search.Table + " s" +
+		" LEFT JOIN images i ON s.kind = 'image' AND i.id = s.record_id" +
+		" LEFT JOIN galleries g ON g.id = s.gallery"
+
+	conditions := append(append([]string{}, where...), filterValues(filters, "")...)
+
+	result := &SearchResult{
+		Page:    req.Page,
+		PerPage: req.PerPage,
+		Items:   []SearchHit{},
+		Facets:  map[string][]FacetCount{},
+	}
+
+	err = s.app.DB().NewQuery("SELECT count(*)" + from + whereClause(conditions)).Bind(params).Row(&result.TotalItems)
+	if err != nil {
+		return nil, errors.BadRequest("Invalid search query", err)
+	}
+
+	order := " ORDER BY s.rowid DESC"
+	rank := "0"
+	if _, ok := params["match"]; ok {
+		rank = "bm25(" + search.Table + ")"
+		order = " ORDER BY rank"
+	}
+
+	rows := []struct {
+		Kind        string  `db:"kind"`
+		ID          string  `db:"record_id"`
+		Gallery     string  `db:"gallery"`
+		GalleryName string  `db:"gallery_name"`
+		Filename    string  `db:"original_filename"`
+		Caption     string  `db:"caption"`
+		File        string  `db:"file"`
+		Rank        float64 `db:"rank"`
+	}{}
+
+	params["limit"] = req.PerPage
+	params["offset"] = (req.Page - 1) * req.PerPage
+
+	err = s.app.DB().NewQuery(
+		"SELECT s.kind, s.record_id, s.gallery, coalesce(g.name, '') AS gallery_name," +
+			" coalesce(i.original_filename, '') AS original_filename, coalesce(i.caption, '') AS caption," +
+			" coalesce(i.image, '') AS file, " + rank + " AS rank" +
+			from + whereClause(conditions) + order + " LIMIT {:limit} OFFSET {:offset}",
+	).Bind(params).All(&rows)
+	if err != nil {
+		return nil, errors.InternalError("Failed to search", err)
+	}
+
+	for _, row := range rows {
+		hit := SearchHit{
+			Kind:        row.Kind,
+			ID:          row.ID,
+			Gallery:     row.Gallery,
+			GalleryName: row.GalleryName,
+			File:        row.File,
+			Rank:        row.Rank,
+		}
+		switch {
+		case row.Kind == search.KindGallery:
+			hit.Title = row.GalleryName
+		case row.Caption != "":
+			hit.Title = row.Caption
+		default:
+			hit.Title = row.Filename
+		}
+		result.Items = append(result.Items, hit)
+	}
+
+	for facet, expr := range searchFacets {
+		facetFrom := from
+		if facet == "tag" {
+			facetFrom += " JOIN json_each(i.tags) t"
+		}
+
+		conditions := append(append([]string{}, where...), filterValues(filters, facet)...)
+		conditions = append(conditions, expr+" IS NOT NULL", expr+" != ''")
+
+		counts := []FacetCount{}
+		err := s.app.DB().NewQuery(
+			"SELECT " + expr + " AS value, count(DISTINCT s.rowid) AS count" + facetFrom + whereClause(conditions) +
+				" GROUP BY value ORDER BY count DESC, value LIMIT {:facetLimit}",
+		).Bind(params).Bind(dbx.Params{"facetLimit": maxFacetValues}).All(&counts)
+		if err != nil {
+			return nil, errors.InternalError("Failed to count search facets", err)
+		}
+		result.Facets[facet] = counts
+	}
+
+	return result, nil
+}
+
+// matchQuery turns the user's words into an FTS5 query matching all of them as prefixes
+func matchQuery(query string) string {
+	var terms []string
+	for _, word := range strings.Fields(query) {
+		word = strings.ReplaceAll(word, `"`, "")
+		if word == "" {
+			continue
+		}
+		terms = append(terms, `"`+word+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// filterValues returns the facet filter conditions, leaving out the excluded facet
+func filterValues(filters map[string]string, exclude string) []string {
+	conditions := make([]string, 0, len(filters))
+	for facet, condition := range filters {
+		if facet != exclude {
+			conditions = append(conditions, condition)
+		}
+	}
+	return conditions
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
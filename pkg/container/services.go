@@ -6,12 +6,19 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"path"
+	"slices"
+	"strings"
 
+	"github.com/cschleiden/go-workflows/backend"
 	"github.com/cschleiden/go-workflows/client"
 	"github.com/dorianlgs/photo-cifu/pkg/config"
+	"github.com/dorianlgs/photo-cifu/pkg/enhance"
 	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
 	"github.com/dorianlgs/photo-cifu/workflow"
 	"github.com/google/uuid"
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/filesystem"
@@ -27,7 +34,13 @@ func NewGalleryService(app *pocketbase.PocketBase, cfg *config.Config) GallerySe
 	return &GalleryServiceImpl{app: app, cfg: cfg}
 }
 
-func (s *GalleryServiceImpl) CreateGallery(name, location string, imagesZip, thumbnail []byte, zipHeader, thumbHeader string) (string, error) {
+// ImageFile is a single image to be stored in a gallery
+type ImageFile struct {
+	Filename string
+	Data     []byte
+}
+
+func (s *GalleryServiceImpl) CreateGallery(ownerID, name, location string, imagesZip, thumbnail []byte, zipHeader, thumbHeader string) (string, error) {
 	// Validate file size
 	if int64(len(imagesZip)) > s.cfg.Gallery.MaxFileSize {
 		return "", errors.ValidationError("Images zip file exceeds maximum size limit", nil)
@@ -40,13 +53,52 @@ func (s *GalleryServiceImpl) CreateGallery(name, location string, imagesZip, thu
 	}
 
 	// Check image count
-	if len(zipReader.File) > s.cfg.Gallery.MaxImages {
+	if limit := imageLimit(s.app, s.cfg, ownerID); len(zipReader.File) > limit {
 		return "", errors.ValidationError(
-			fmt.Sprintf("Gallery cannot contain more than %d images", s.cfg.Gallery.MaxImages),
+			fmt.Sprintf("Gallery cannot contain more than %d images", limit),
 			nil,
 		)
 	}
 
+	images := make([]ImageFile, 0, len(zipReader.File))
+	for _, file := range zipReader.File {
+		data, err := readZipFile(file)
+		if err != nil {
+			return "", errors.BadRequest(fmt.Sprintf("Failed to read image %s", file.Name), err)
+		}
+		images = append(images, ImageFile{Filename: file.Name, Data: data})
+	}
+
+	return s.CreateGalleryFromImages(ownerID, name, location, images, thumbnail, thumbHeader)
+}
+
+func (s *GalleryServiceImpl) CreateGalleryFromImages(ownerID, name, location string, images []ImageFile, thumbnail []byte, thumbHeader string) (string, error) {
+	if limit := imageLimit(s.app, s.cfg, ownerID); len(images) > limit {
+		return "", errors.ValidationError(
+			fmt.Sprintf("Gallery cannot contain more than %d images", limit),
+			nil,
+		)
+	}
+
+	for _, image := range images {
+		if int64(len(image.Data)) > s.cfg.Gallery.MaxFileSize {
+			return "", errors.ValidationError(fmt.Sprintf("Image %s exceeds maximum size limit", image.Filename), nil)
+		}
+	}
+
+	// The images together are bounded like the zip file they could have been sent in
+	if imagesSize(images) > s.cfg.Gallery.MaxFileSize {
+		return "", errors.ValidationError("Images exceed maximum total size limit", nil)
+	}
+
+	// Check the plan of the owner
+	if err := CheckGalleryQuota(s.app, s.cfg, ownerID); err != nil {
+		return "", err
+	}
+	if err := CheckStorageQuota(s.app, s.cfg, ownerID, imagesSize(images)); err != nil {
+		return "", err
+	}
+
 	imagesCollection, err := s.app.FindCollectionByNameOrId("images")
 	if err != nil {
 		return "", errors.InternalError("Failed to find images collection", err)
@@ -63,16 +115,17 @@ func (s *GalleryServiceImpl) CreateGallery(name, location string, imagesZip, thu
 	transactErr := s.app.RunInTransaction(func(txApp core.App) error {
 		var imageIDs []string
 
-		// Process each image in the zip
-		for _, file := range zipReader.File {
-			imageID, err := s.processImageFile(txApp, imagesCollection, file)
+		// Store each image
+		for _, image := range images {
+			imageID, err := s.saveImage(txApp, imagesCollection, image.Filename, image.Data)
 			if err != nil {
-				return fmt.Errorf("failed to process image %s: %w", file.Name, err)
+				return fmt.Errorf("failed to process image %s: %w", image.Filename, err)
 			}
 			imageIDs = append(imageIDs, imageID)
 		}
 
 		// Create gallery record
+		galleryRecord.Set("owner", ownerID)
 		galleryRecord.Set("name", name)
 		galleryRecord.Set("location", location)
 		galleryRecord.Set("images", imageIDs)
@@ -99,24 +152,130 @@ func (s *GalleryServiceImpl) CreateGallery(name, location string, imagesZip, thu
 	return galleryID, nil
 }
 
-func (s *GalleryServiceImpl) processImageFile(txApp core.App, collection *core.Collection, file *zip.File) (string, error) {
+func (s *GalleryServiceImpl) UpdateGallery(info *core.RequestInfo, galleryID string, req *validation.GalleryUpdateRequest, thumbnail []byte) (*core.Record, error) {
+	gallery, err := s.app.FindRecordById("galleries", galleryID)
+	if err != nil {
+		return nil, errors.NotFound("Gallery not found")
+	}
+
+	if err := CheckGalleryRole(s.app, info, gallery, RoleOwner, RoleEditor); err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		gallery.Set("name", strings.TrimSpace(*req.Name))
+	}
+	if req.Location != nil {
+		gallery.Set("location", strings.TrimSpace(*req.Location))
+	}
+	if req.Description != nil {
+		gallery.Set("description", *req.Description)
+	}
+	if req.MaxPicks != nil {
+		gallery.Set("max_picks", *req.MaxPicks)
+	}
+	if req.CommentModeration != nil {
+		gallery.Set("comment_moderation", *req.CommentModeration)
+	}
+	if req.Public != nil {
+		// publishing a gallery shows it to everyone, so it is left to its owner
+		if err := CheckGalleryRole(s.app, info, gallery, RoleOwner); err != nil {
+			return nil, errors.Forbidden("Only the owner can change whether a gallery is public")
+		}
+		gallery.Set("public", *req.Public)
+	}
+
+	if req.Images != nil {
+		current := gallery.GetStringSlice("images")
+		unique := slices.Clone(req.Images)
+		slices.Sort(current)
+		slices.Sort(unique)
+		unique = slices.Compact(unique)
+		if len(unique) != len(req.Images) || !slices.Equal(current, unique) {
+			return nil, errors.ValidationError("Images must list each image of the gallery once", nil)
+		}
+		gallery.Set("images", req.Images)
+	}
+
+	if req.Thumbnail != nil {
+		thumbnailFile, err := filesystem.NewFileFromBytes(thumbnail, req.Thumbnail.Filename)
+		if err != nil {
+			return nil, errors.InternalError("Failed to create thumbnail file", err)
+		}
+		gallery.Set("thumbnail", thumbnailFile)
+	}
+
+	if err := s.app.Save(gallery); err != nil {
+		return nil, errors.BadRequest("Failed to update gallery", err)
+	}
+
+	return gallery, nil
+}
+
+func (s *GalleryServiceImpl) DeleteGallery(info *core.RequestInfo, galleryID string) error {
+	gallery, err := s.app.FindRecordById("galleries", galleryID)
+	if err != nil {
+		return errors.NotFound("Gallery not found")
+	}
+
+	if err := CheckGalleryRole(s.app, info, gallery, RoleOwner); err != nil {
+		return err
+	}
+
+	// the gallery and its images stay in the trash until they are restored or purged
+	if err := MoveGalleryToTrash(s.app, gallery); err != nil {
+		return errors.InternalError("Failed to move gallery to trash", err)
+	}
+
+	return nil
+}
+
+// Images returns a page of the gallery's images, in the gallery's order unless another sort is requested
+func (s *GalleryServiceImpl) Images(client *GalleryClient, galleryID string, req *validation.ImagePageRequest) (*ImagePage, error) {
+	gallery, err := ClientGallery(s.app, client, galleryID, validation.SharePermissionView)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Sort == "" {
+		req.Sort = validation.ImageSortManual
+	}
+
+	query := &imageQuery{
+		from:       " FROM galleries g, json_each(g.images) j JOIN images i ON i.id = j.value",
+		gallery:    "g.id",
+		manual:     "j.key",
+		conditions: []string{"g.id = {:gallery}"},
+		params:     dbx.Params{"gallery": gallery.Id},
+	}
+
+	return query.page(s.app, req)
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
 	fileReader, err := file.Open()
 	if err != nil {
-		return "", fmt.Errorf("failed to open file in archive: %w", err)
+		return nil, fmt.Errorf("failed to open file in archive: %w", err)
 	}
 	defer fileReader.Close()
 
 	// Read file data
 	dataBuffer := new(bytes.Buffer)
 	if _, err := io.Copy(dataBuffer, fileReader); err != nil {
-		return "", fmt.Errorf("failed to read file data: %w", err)
+		return nil, fmt.Errorf("failed to read file data: %w", err)
 	}
 
+	return dataBuffer.Bytes(), nil
+}
+
+func (s *GalleryServiceImpl) saveImage(txApp core.App, collection *core.Collection, filename string, data []byte) (string, error) {
 	// Create image record
 	imageRecord := core.NewRecord(collection)
 	imageRecord.Set("likes", 0)
+	imageRecord.Set("original_filename", path.Base(filename))
+	SetImageMetadata(imageRecord, data)
 
-	imageFile, err := filesystem.NewFileFromBytes(dataBuffer.Bytes(), file.Name)
+	imageFile, err := filesystem.NewFileFromBytes(data, filename)
 	if err != nil {
 		return "", fmt.Errorf("failed to create file: %w", err)
 	}
@@ -129,19 +288,255 @@ func (s *GalleryServiceImpl) processImageFile(txApp core.App, collection *core.C
 	return imageRecord.Id, nil
 }
 
+func (s *GalleryServiceImpl) AddImage(galleryID, filename string, data []byte) (string, error) {
+	if int64(len(data)) > s.cfg.Gallery.MaxFileSize {
+		return "", errors.ValidationError("Image exceeds maximum size limit", nil)
+	}
+
+	gallery, err := s.app.FindRecordById("galleries", galleryID)
+	if err != nil {
+		return "", errors.NotFound("Gallery not found")
+	}
+
+	if limit := GalleryImageLimit(s.app, s.cfg, gallery); len(gallery.GetStringSlice("images")) >= limit {
+		return "", errors.ValidationError(
+			fmt.Sprintf("Gallery cannot contain more than %d images", limit),
+			nil,
+		)
+	}
+
+	if err := CheckStorageQuota(s.app, s.cfg, gallery.GetString("owner"), int64(len(data))); err != nil {
+		return "", err
+	}
+
+	imagesCollection, err := s.app.FindCollectionByNameOrId("images")
+	if err != nil {
+		return "", errors.InternalError("Failed to find images collection", err)
+	}
+
+	var imageID string
+	transactErr := s.app.RunInTransaction(func(txApp core.App) error {
+		imageID, err = s.saveImage(txApp, imagesCollection, filename, data)
+		if err != nil {
+			return err
+		}
+
+		gallery.Set("images+", imageID)
+		if err := txApp.Save(gallery); err != nil {
+			return fmt.Errorf("failed to save gallery: %w", err)
+		}
+
+		return nil
+	})
+
+	if transactErr != nil {
+		return "", errors.InternalError("Failed to add image", transactErr)
+	}
+
+	return imageID, nil
+}
+
+func (s *GalleryServiceImpl) AddImages(info *core.RequestInfo, galleryID string, images []ImageFile) ([]string, error) {
+	gallery, err := s.app.FindRecordById("galleries", galleryID)
+	if err != nil {
+		return nil, errors.NotFound("Gallery not found")
+	}
+
+	if err := CheckGalleryRole(s.app, info, gallery, RoleOwner, RoleEditor, RoleContributor); err != nil {
+		return nil, err
+	}
+
+	if limit := GalleryImageLimit(s.app, s.cfg, gallery); len(gallery.GetStringSlice("images"))+len(images) > limit {
+		return nil, errors.ValidationError(
+			fmt.Sprintf("Gallery cannot contain more than %d images", limit),
+			nil,
+		)
+	}
+
+	for _, image := range images {
+		if int64(len(image.Data)) > s.cfg.Gallery.MaxFileSize {
+			return nil, errors.ValidationError(fmt.Sprintf("Image %s exceeds maximum size limit", image.Filename), nil)
+		}
+	}
+
+	// contributors add images to the storage of the gallery's owner
+	if err := CheckStorageQuota(s.app, s.cfg, gallery.GetString("owner"), imagesSize(images)); err != nil {
+		return nil, err
+	}
+
+	imagesCollection, err := s.app.FindCollectionByNameOrId("images")
+	if err != nil {
+		return nil, errors.InternalError("Failed to find images collection", err)
+	}
+
+	var imageIDs []string
+	transactErr := s.app.RunInTransaction(func(txApp core.App) error {
+		for _, image := range images {
+			imageID, err := s.saveImage(txApp, imagesCollection, image.Filename, image.Data)
+			if err != nil {
+				return fmt.Errorf("failed to process image %s: %w", image.Filename, err)
+			}
+			imageIDs = append(imageIDs, imageID)
+		}
+
+		gallery.Set("images+", imageIDs)
+		if err := txApp.Save(gallery); err != nil {
+			return fmt.Errorf("failed to save gallery: %w", err)
+		}
+
+		return nil
+	})
+
+	if transactErr != nil {
+		return nil, errors.InternalError("Failed to add images", transactErr)
+	}
+
+	return imageIDs, nil
+}
+
+func (s *GalleryServiceImpl) ReplaceImage(galleryID, imageID, filename string, data []byte) error {
+	if int64(len(data)) > s.cfg.Gallery.MaxFileSize {
+		return errors.ValidationError("Image exceeds maximum size limit", nil)
+	}
+
+	gallery, image, err := s.findGalleryImage(galleryID, imageID)
+	if err != nil {
+		return err
+	}
+
+	if err := CheckStorageQuota(s.app, s.cfg, gallery.GetString("owner"), int64(len(data))-imageStorage(image)); err != nil {
+		return err
+	}
+
+	imageFile, err := filesystem.NewFileFromBytes(data, filename)
+	if err != nil {
+		return errors.InternalError("Failed to create file", err)
+	}
+	image.Set("image", imageFile)
+	image.Set("original_filename", path.Base(filename))
+	SetImageMetadata(image, data)
+	// a replaced image starts over without enhancements
+	image.Set("original", "")
+	image.Set("original_size", 0)
+	image.Set("enhanced", "")
+	image.Set("enhanced_size", 0)
+	image.Set("enhancement", "")
+
+	if err := s.app.Save(image); err != nil {
+		return errors.InternalError("Failed to save image", err)
+	}
+
+	return nil
+}
+
+func (s *GalleryServiceImpl) DeleteImage(galleryID, imageID string) error {
+	gallery, image, err := s.findGalleryImage(galleryID, imageID)
+	if err != nil {
+		return err
+	}
+
+	if err := MoveImageToTrash(s.app, gallery, image); err != nil {
+		return errors.InternalError("Failed to move image to trash", err)
+	}
+
+	return nil
+}
+
+func (s *GalleryServiceImpl) MoveImage(srcGalleryID, imageID, dstGalleryID string) error {
+	src, image, err := s.findGalleryImage(srcGalleryID, imageID)
+	if err != nil {
+		return err
+	}
+
+	if srcGalleryID == dstGalleryID {
+		return nil
+	}
+
+	dst, err := s.app.FindRecordById("galleries", dstGalleryID)
+	if err != nil {
+		return errors.NotFound("Destination gallery not found")
+	}
+
+	if limit := GalleryImageLimit(s.app, s.cfg, dst); len(dst.GetStringSlice("images")) >= limit {
+		return errors.ValidationError(
+			fmt.Sprintf("Gallery cannot contain more than %d images", limit),
+			nil,
+		)
+	}
+
+	// the image moves to the storage of the destination's owner
+	if owner := dst.GetString("owner"); owner != src.GetString("owner") {
+		if err := CheckStorageQuota(s.app, s.cfg, owner, imageStorage(image)); err != nil {
+			return err
+		}
+	}
+
+	transactErr := s.app.RunInTransaction(func(txApp core.App) error {
+		src.Set("images-", image.Id)
+		if err := txApp.Save(src); err != nil {
+			return fmt.Errorf("failed to save source gallery: %w", err)
+		}
+
+		dst.Set("images+", image.Id)
+		if err := txApp.Save(dst); err != nil {
+			return fmt.Errorf("failed to save destination gallery: %w", err)
+		}
+
+		return nil
+	})
+
+	if transactErr != nil {
+		return errors.InternalError("Failed to move image", transactErr)
+	}
+
+	return nil
+}
+
+func (s *GalleryServiceImpl) findGalleryImage(galleryID, imageID string) (*core.Record, *core.Record, error) {
+	gallery, err := s.app.FindRecordById("galleries", galleryID)
+	if err != nil {
+		return nil, nil, errors.NotFound("Gallery not found")
+	}
+
+	if !slices.Contains(gallery.GetStringSlice("images"), imageID) {
+		return nil, nil, errors.NotFound("Image not found in gallery")
+	}
+
+	image, err := s.app.FindRecordById("images", imageID)
+	if err != nil {
+		return nil, nil, errors.NotFound("Image not found")
+	}
+
+	return gallery, image, nil
+}
+
 // WorkflowServiceImpl implements WorkflowService
 type WorkflowServiceImpl struct {
-	client *client.Client
+	app     *pocketbase.PocketBase
+	client  *client.Client
+	backend backend.Backend
+	cfg     *config.Config
 }
 
-func NewWorkflowService(client *client.Client) WorkflowService {
-	return &WorkflowServiceImpl{client: client}
+func NewWorkflowService(app *pocketbase.PocketBase, client *client.Client, backend backend.Backend, cfg *config.Config) WorkflowService {
+	return &WorkflowServiceImpl{app: app, client: client, backend: backend, cfg: cfg}
 }
 
-func (s *WorkflowServiceImpl) CreateWorkflow(workflowType string, input interface{}) (string, error) {
+// CreateWorkflow starts a workflow for the auth record, nil for scheduled runs, and
+// records the run. Runs started by users count against the workflow runs per day
+// of their plan.
+func (s *WorkflowServiceImpl) CreateWorkflow(auth *core.Record, workflowType string, input interface{}) (string, error) {
 	instanceID := uuid.NewString()
 	ctx := context.Background()
 
+	userID, galleryID, executionID := "", "", ""
+	if auth != nil && auth.Collection().Name == "users" {
+		userID = auth.Id
+		if err := CheckWorkflowQuota(s.app, s.cfg, userID); err != nil {
+			return "", err
+		}
+	}
+
 	switch workflowType {
 	case "gallery_process":
 		// Convert input to proper workflow input structure
@@ -150,22 +545,119 @@ func (s *WorkflowServiceImpl) CreateWorkflow(workflowType string, input interfac
 			return "", errors.ValidationError("Invalid input for gallery processing workflow", err)
 		}
 
-		_, err = s.client.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+		gallery, err := s.app.FindRecordById("galleries", galleryInput.GalleryID)
+		if err != nil {
+			return "", errors.NotFound("Gallery not found")
+		}
+		if err := s.checkGalleryEditor(auth, gallery); err != nil {
+			return "", err
+		}
+		if galleryInput.GalleryName == "" {
+			galleryInput.GalleryName = gallery.GetString("name")
+		}
+		// the notification goes to the user who started the run
+		if auth != nil {
+			galleryInput.UserEmail = auth.Email()
+		}
+
+		instance, err := s.client.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+			InstanceID: instanceID,
+		}, workflow.GalleryProcessingWorkflow, galleryInput)
+		galleryID = galleryInput.GalleryID
+
+		if err != nil {
+			return "", errors.InternalError("Failed to create workflow instance", err)
+		}
+		executionID = instance.ExecutionID
+	case "image_enhancement":
+		enhancementInput, err := s.convertToEnhancementInput(input)
+		if err != nil {
+			return "", errors.ValidationError("Invalid input for image enhancement workflow", err)
+		}
+
+		gallery, err := s.enhancementGallery(auth, enhancementInput)
+		if err != nil {
+			return "", err
+		}
+		if gallery != nil {
+			galleryID = gallery.Id
+		}
+
+		instance, err := s.client.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+			InstanceID: instanceID,
+		}, workflow.EnhancementWorkflow, enhancementInput)
+
+		if err != nil {
+			return "", errors.InternalError("Failed to create workflow instance", err)
+		}
+		executionID = instance.ExecutionID
+	case "cleanup":
+		cleanupInput, err := s.convertToCleanupInput(input)
+		if err != nil {
+			return "", errors.ValidationError("Invalid input for cleanup workflow", err)
+		}
+
+		instance, err := s.client.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
 			InstanceID: instanceID,
-		}, workflow.Workflow1, galleryInput)
+		}, workflow.CleanupWorkflow, cleanupInput)
 
 		if err != nil {
 			return "", errors.InternalError("Failed to create workflow instance", err)
 		}
+		executionID = instance.ExecutionID
+	case "trash_purge":
+		purgeInput, err := s.convertToTrashPurgeInput(input)
+		if err != nil {
+			return "", errors.ValidationError("Invalid input for trash purge workflow", err)
+		}
+
+		instance, err := s.client.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+			InstanceID: instanceID,
+		}, workflow.TrashPurgeWorkflow, purgeInput)
+
+		if err != nil {
+			return "", errors.InternalError("Failed to create workflow instance", err)
+		}
+		executionID = instance.ExecutionID
 	default:
 		return "", errors.ValidationError(fmt.Sprintf("Unknown workflow type: %s", workflowType), nil)
 	}
 
+	if err := s.recordRun(userID, galleryID, workflowType, instanceID, executionID); err != nil {
+		s.app.Logger().Error("Failed to record workflow run", "instanceID", instanceID, "error", err)
+	}
+
 	return instanceID, nil
 }
 
+// recordRun stores the run of a workflow, with the user who started it and
+// the gallery it works on when there are
+func (s *WorkflowServiceImpl) recordRun(userID, galleryID, workflowType, instanceID, executionID string) error {
+	collection, err := s.app.FindCollectionByNameOrId("workflow_runs")
+	if err != nil {
+		return err
+	}
+
+	run := core.NewRecord(collection)
+	run.Set("user", userID)
+	run.Set("workflow_type", workflowType)
+	run.Set("instance_id", instanceID)
+	run.Set("execution_id", executionID)
+	run.Set("status", WorkflowStatusRunning)
+	if galleryID != "" {
+		if _, err := s.app.FindRecordById("galleries", galleryID); err == nil {
+			run.Set("gallery", galleryID)
+		}
+	}
+
+	return s.app.Save(run)
+}
+
 func (s *WorkflowServiceImpl) convertToGalleryInput(input interface{}) (workflow.GalleryProcessingInput, error) {
-	var galleryInput workflow.GalleryProcessingInput
+	galleryInput := workflow.GalleryProcessingInput{
+		Concurrency: s.cfg.Processing.Concurrency,
+		BatchSize:   s.cfg.Processing.BatchSize,
+	}
 
 	if inputMap, ok := input.(map[string]interface{}); ok {
 		if galleryID, ok := inputMap["gallery_id"].(string); ok {
@@ -174,9 +666,6 @@ func (s *WorkflowServiceImpl) convertToGalleryInput(input interface{}) (workflow
 		if galleryName, ok := inputMap["gallery_name"].(string); ok {
 			galleryInput.GalleryName = galleryName
 		}
-		if userEmail, ok := inputMap["user_email"].(string); ok {
-			galleryInput.UserEmail = userEmail
-		}
 	}
 
 	if galleryInput.GalleryID == "" {
@@ -186,16 +675,170 @@ func (s *WorkflowServiceImpl) convertToGalleryInput(input interface{}) (workflow
 	return galleryInput, nil
 }
 
+func (s *WorkflowServiceImpl) convertToCleanupInput(input interface{}) (workflow.CleanupInput, error) {
+	cleanupInput := workflow.CleanupInput{
+		DryRun:      s.cfg.Cleanup.DryRun,
+		GracePeriod: s.cfg.Cleanup.GracePeriod,
+		BatchSize:   s.cfg.Cleanup.BatchSize,
+	}
+
+	if inputMap, ok := input.(map[string]interface{}); ok {
+		if dryRun, ok := inputMap["dry_run"].(bool); ok {
+			cleanupInput.DryRun = dryRun
+		}
+		if gracePeriod, ok := inputMap["grace_period"].(float64); ok {
+			cleanupInput.GracePeriod = int(gracePeriod)
+		}
+		if batchSize, ok := inputMap["batch_size"].(float64); ok {
+			cleanupInput.BatchSize = int(batchSize)
+		}
+	}
+
+	if cleanupInput.GracePeriod < 0 {
+		return cleanupInput, fmt.Errorf("grace_period must not be negative")
+	}
+	if cleanupInput.BatchSize < 1 || cleanupInput.BatchSize > 1000 {
+		return cleanupInput, fmt.Errorf("batch_size must be between 1 and 1000")
+	}
+
+	return cleanupInput, nil
+}
+
+func (s *WorkflowServiceImpl) convertToTrashPurgeInput(input interface{}) (workflow.TrashPurgeInput, error) {
+	purgeInput := workflow.TrashPurgeInput{
+		Retention: s.cfg.Trash.Retention,
+		BatchSize: s.cfg.Cleanup.BatchSize,
+	}
+
+	if inputMap, ok := input.(map[string]interface{}); ok {
+		if dryRun, ok := inputMap["dry_run"].(bool); ok {
+			purgeInput.DryRun = dryRun
+		}
+		if retention, ok := inputMap["retention"].(float64); ok {
+			purgeInput.Retention = int(retention)
+		}
+		if batchSize, ok := inputMap["batch_size"].(float64); ok {
+			purgeInput.BatchSize = int(batchSize)
+		}
+	}
+
+	if purgeInput.Retention < 0 {
+		return purgeInput, fmt.Errorf("retention must not be negative")
+	}
+	if purgeInput.BatchSize < 1 || purgeInput.BatchSize > 1000 {
+		return purgeInput, fmt.Errorf("batch_size must be between 1 and 1000")
+	}
+
+	return purgeInput, nil
+}
+
+func (s *WorkflowServiceImpl) convertToEnhancementInput(input interface{}) (workflow.EnhancementInput, error) {
+	enhancementInput := workflow.EnhancementInput{
+		Concurrency:   s.cfg.Processing.Concurrency,
+		ReviewTimeout: s.cfg.Enhancement.ReviewTimeout,
+	}
+
+	if inputMap, ok := input.(map[string]interface{}); ok {
+		if imageID, ok := inputMap["image_id"].(string); ok {
+			enhancementInput.ImageID = imageID
+		}
+		if galleryID, ok := inputMap["gallery_id"].(string); ok {
+			enhancementInput.GalleryID = galleryID
+		}
+		if operations, ok := inputMap["operations"].([]interface{}); ok {
+			for _, operation := range operations {
+				name, _ := operation.(string)
+				enhancementInput.Options.Operations = append(enhancementInput.Options.Operations, name)
+			}
+		}
+		if maxWidth, ok := inputMap["max_width"].(float64); ok {
+			enhancementInput.Options.MaxWidth = int(maxWidth)
+		}
+		if maxHeight, ok := inputMap["max_height"].(float64); ok {
+			enhancementInput.Options.MaxHeight = int(maxHeight)
+		}
+	}
+
+	if (enhancementInput.ImageID == "") == (enhancementInput.GalleryID == "") {
+		return enhancementInput, fmt.Errorf("either image_id or gallery_id is required")
+	}
+
+	opts := enhance.Options{
+		Operations: enhancementInput.Options.Operations,
+		MaxWidth:   enhancementInput.Options.MaxWidth,
+		MaxHeight:  enhancementInput.Options.MaxHeight,
+	}
+	if err := opts.Validate(); err != nil {
+		return enhancementInput, err
+	}
+
+	return enhancementInput, nil
+}
+
+// enhancementGallery returns the gallery of the enhanced images, nil for an image
+// in no gallery, and allows users to enhance the images of galleries they own or
+// edit. Images that are in no gallery can only be enhanced by superusers.
+func (s *WorkflowServiceImpl) enhancementGallery(auth *core.Record, input workflow.EnhancementInput) (*core.Record, error) {
+	var gallery *core.Record
+	if input.GalleryID != "" {
+		var err error
+		if gallery, err = s.app.FindRecordById("galleries", input.GalleryID); err != nil {
+			return nil, errors.NotFound("Gallery not found")
+		}
+	} else {
+		image, err := s.app.FindRecordById("images", input.ImageID)
+		if err != nil {
+			return nil, errors.NotFound("Image not found")
+		}
+		// the review of the pending enhancement would apply the new one
+		if image.GetString("enhancement") == workflow.EnhancementPending {
+			return nil, errors.ValidationError("The image already has an enhancement pending review", nil)
+		}
+		gallery = FindImageGallery(s.app, input.ImageID)
+	}
+
+	if err := s.checkGalleryEditor(auth, gallery); err != nil {
+		return nil, err
+	}
+
+	return gallery, nil
+}
+
+// checkGalleryEditor allows scheduled runs, superusers, and the owners and
+// editors of the gallery to start a workflow working on it
+func (s *WorkflowServiceImpl) checkGalleryEditor(auth *core.Record, gallery *core.Record) error {
+	if auth == nil || auth.IsSuperuser() {
+		return nil
+	}
+
+	if gallery == nil || IsTrashed(gallery) {
+		return errors.NotFound("Gallery not found")
+	}
+
+	if role := GalleryRole(s.app, auth, gallery); role != RoleOwner && role != RoleEditor {
+		return errors.Forbidden("You are not allowed to modify this gallery")
+	}
+
+	return nil
+}
+
 // SignalServiceImpl implements SignalService
 type SignalServiceImpl struct {
+	app    *pocketbase.PocketBase
 	client *client.Client
 }
 
-func NewSignalService(client *client.Client) SignalService {
-	return &SignalServiceImpl{client: client}
+func NewSignalService(app *pocketbase.PocketBase, client *client.Client) SignalService {
+	return &SignalServiceImpl{app: app, client: client}
 }
 
-func (s *SignalServiceImpl) SendSignal(instanceID, signalName string, data interface{}) error {
+// SendSignal signals a workflow run. Superusers can signal every run, users
+// the runs they started and the runs working on a gallery they own or edit.
+func (s *SignalServiceImpl) SendSignal(auth *core.Record, instanceID, signalName string, data interface{}) error {
+	if err := s.checkRun(auth, instanceID); err != nil {
+		return err
+	}
+
 	ctx := context.Background()
 
 	err := s.client.SignalWorkflow(ctx, instanceID, signalName, data)
@@ -206,6 +849,31 @@ func (s *SignalServiceImpl) SendSignal(instanceID, signalName string, data inter
 	return nil
 }
 
+func (s *SignalServiceImpl) checkRun(auth *core.Record, instanceID string) error {
+	if auth == nil {
+		return errors.Unauthorized("Authentication required")
+	}
+	if auth.IsSuperuser() {
+		return nil
+	}
+
+	run, err := s.app.FindFirstRecordByData("workflow_runs", "instance_id", instanceID)
+	if err != nil {
+		return errors.NotFound("Workflow not found")
+	}
+	if run.GetString("user") == auth.Id {
+		return nil
+	}
+
+	if gallery, err := s.app.FindRecordById("galleries", run.GetString("gallery")); err == nil && !IsTrashed(gallery) {
+		if role := GalleryRole(s.app, auth, gallery); role == RoleOwner || role == RoleEditor {
+			return nil
+		}
+	}
+
+	return errors.NotFound("Workflow not found")
+}
+
 // SettingsServiceImpl implements SettingsService
 type SettingsServiceImpl struct {
 	app *pocketbase.PocketBase
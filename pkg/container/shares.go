@@ -0,0 +1,419 @@
+package container
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/dorianlgs/photo-cifu/pkg/config"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// shareTokenType is the JWT type claim of share file tokens
+const shareTokenType = "share"
+
+// clientMaxFailures is the number of failed share accesses allowed per client
+// within the lockout duration, regardless of the share link
+const clientMaxFailures = 20
+
+// ShareLink is returned when a share link is created. The token is only
+// stored hashed and can't be retrieved later.
+type ShareLink struct {
+	ID          string    `json:"id"`
+	Token       string    `json:"token"`
+	Gallery     string    `json:"gallery,omitempty"`
+	Album       string    `json:"album,omitempty"`
+	Label       string    `json:"label"`
+	Permissions []string  `json:"permissions"`
+	Expires     time.Time `json:"expires"`
+	HasPassword bool      `json:"has_password"`
+}
+
+// ShareGallery is the public part of a shared gallery or smart album
+type ShareGallery struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Location string `json:"location"`
+}
+
+// ShareImage is an image of a shared gallery
+type ShareImage struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+}
+
+// ShareAccess is the result of exchanging a share token. Links to smart albums
+// have an album instead of a gallery, and no images since they are paged.
+type ShareAccess struct {
+	ShareID          string        `json:"share_id"`
+	Gallery          *ShareGallery `json:"gallery,omitempty"`
+	Album            *ShareGallery `json:"album,omitempty"`
+	Permissions      []string      `json:"permissions"`
+	Images           []ShareImage  `json:"images"`
+	FileToken        string        `json:"file_token"`
+	FileTokenExpires time.Time     `json:"file_token_expires"`
+}
+
+// ShareGrant is a verified share file token, for either a gallery or a smart album
+type ShareGrant struct {
+	Share     *core.Record
+	GalleryID string
+	AlbumID   string
+}
+
+// Can reports whether the share link grants the permission
+func (g *ShareGrant) Can(permission string) bool {
+	return slices.Contains(g.Share.GetStringSlice("permissions"), permission)
+}
+
+// ShareServiceImpl implements ShareService
+type ShareServiceImpl struct {
+	app      *pocketbase.PocketBase
+	cfg      *config.Config
+	failures *AttemptLimiter
+}
+
+func NewShareService(app *pocketbase.PocketBase, cfg *config.Config) ShareService {
+	return &ShareServiceImpl{
+		app:      app,
+		cfg:      cfg,
+		failures: NewAttemptLimiter(clientMaxFailures, time.Duration(cfg.Shares.LockoutDuration)*time.Second),
+	}
+}
+
+func (s *ShareServiceImpl) CreateShare(info *core.RequestInfo, galleryID string, req *validation.ShareCreateRequest) (*ShareLink, error) {
+	gallery, err := s.app.FindRecordById("galleries", galleryID)
+	if err != nil {
+		return nil, errors.NotFound("Gallery not found")
+	}
+
+	if err := CheckGalleryRole(s.app, info, gallery, RoleOwner); err != nil {
+		return nil, err
+	}
+
+	return s.createShare(info, "gallery", gallery.Id, req)
+}
+
+// CreateAlbumShare creates a share link for a smart album. Likes, comments and
+// selections belong to galleries, so album links can only view and download.
+func (s *ShareServiceImpl) CreateAlbumShare(info *core.RequestInfo, albumID string, req *validation.ShareCreateRequest) (*ShareLink, error) {
+	album, err := s.app.FindRecordById("smart_albums", albumID)
+	if err != nil {
+		return nil, errors.NotFound("Smart album not found")
+	}
+
+	if err := checkAlbumOwner(info, album); err != nil {
+		return nil, err
+	}
+
+	for _, permission := range req.Permissions {
+		if permission != validation.SharePermissionView && permission != validation.SharePermissionDownload {
+			return nil, errors.ValidationError("Smart album share links can only include the view and download permissions", nil)
+		}
+	}
+
+	return s.createShare(info, "album", album.Id, req)
+}
+
+// createShare saves a share link for the gallery or album set in the field
+func (s *ShareServiceImpl) createShare(info *core.RequestInfo, field, targetID string, req *validation.ShareCreateRequest) (*ShareLink, error) {
+	expires := time.Now().Add(time.Duration(s.cfg.Shares.DefaultExpiry) * time.Second)
+	if req.Expires != "" {
+		date, err := types.ParseDateTime(req.Expires)
+		if err != nil || date.IsZero() {
+			return nil, errors.ValidationError("Invalid expiry date", err)
+		}
+		expires = date.Time()
+	}
+	if !expires.After(time.Now()) {
+		return nil, errors.ValidationError("Expiry date must be in the future", nil)
+	}
+
+	collection, err := s.app.FindCollectionByNameOrId("share_links")
+	if err != nil {
+		return nil, errors.InternalError("Failed to find share links collection", err)
+	}
+
+	token := security.RandomString(40)
+
+	record := core.NewRecord(collection)
+	record.Set(field, targetID)
+	record.Set("label", req.Label)
+	record.Set("token_hash", hashShareToken(token))
+	record.Set("token_key", security.RandomString(50))
+	record.Set("permissions", req.Permissions)
+	record.Set("expires", expires)
+	if info.Auth != nil && info.Auth.Collection().Name == "users" {
+		record.Set("created_by", info.Auth.Id)
+	}
+
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), 12)
+		if err != nil {
+			return nil, errors.InternalError("Failed to hash password", err)
+		}
+		record.Set("password_hash", string(hash))
+	}
+
+	if err := s.app.Save(record); err != nil {
+		return nil, errors.InternalError("Failed to save share link", err)
+	}
+
+	return &ShareLink{
+		ID:          record.Id,
+		Token:       token,
+		Gallery:     record.GetString("gallery"),
+		Album:       record.GetString("album"),
+		Label:       req.Label,
+		Permissions: record.GetStringSlice("permissions"),
+		Expires:     expires.UTC(),
+		HasPassword: req.Password != "",
+	}, nil
+}
+
+func (s *ShareServiceImpl) RevokeShare(info *core.RequestInfo, shareID string) error {
+	share, err := s.app.FindRecordById("share_links", shareID)
+	if err != nil {
+		return errors.NotFound("Share link not found")
+	}
+
+	if albumID := share.GetString("album"); albumID != "" {
+		album, err := s.app.FindRecordById("smart_albums", albumID)
+		if err != nil {
+			return errors.NotFound("Share link not found")
+		}
+		if err := checkAlbumOwner(info, album); err != nil {
+			return err
+		}
+	} else {
+		gallery, err := s.app.FindRecordById("galleries", share.GetString("gallery"))
+		if err != nil {
+			return errors.NotFound("Share link not found")
+		}
+		if err := CheckGalleryRole(s.app, info, gallery, RoleOwner); err != nil {
+			return err
+		}
+	}
+
+	// a new key also invalidates the file tokens already handed out
+	share.Set("revoked", true)
+	share.Set("token_key", security.RandomString(50))
+
+	if err := s.app.Save(share); err != nil {
+		return errors.InternalError("Failed to revoke share link", err)
+	}
+
+	return nil
+}
+
+func (s *ShareServiceImpl) Access(token, password, clientIP string) (*ShareAccess, error) {
+	share, err := s.Find(token, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	if hash := share.GetString("password_hash"); hash != "" {
+		if password == "" {
+			return nil, errors.Unauthorized("This share link requires a password")
+		}
+
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+			s.failures.Add(clientIP)
+			s.recordFailedAttempt(share)
+			return nil, errors.Unauthorized("Invalid password")
+		}
+	}
+
+	share.Set("failed_attempts", 0)
+	share.Set("last_used", types.NowDateTime())
+	if err := s.app.Save(share); err != nil {
+		return nil, errors.InternalError("Failed to update share link", err)
+	}
+
+	access := &ShareAccess{
+		ShareID:     share.Id,
+		Permissions: share.GetStringSlice("permissions"),
+		Images:      []ShareImage{},
+	}
+	claims := jwt.MapClaims{
+		"id":   share.Id,
+		"type": shareTokenType,
+	}
+
+	if albumID := share.GetString("album"); albumID != "" {
+		album, err := s.app.FindRecordById("smart_albums", albumID)
+		if err != nil {
+			return nil, errors.NotFound("Smart album not found")
+		}
+
+		claims["album"] = album.Id
+		access.Album = &ShareGallery{ID: album.Id, Name: album.GetString("name")}
+	} else {
+		gallery, err := s.app.FindRecordById("galleries", share.GetString("gallery"))
+		if err != nil || IsTrashed(gallery) {
+			return nil, errors.NotFound("Gallery not found")
+		}
+
+		if errs := s.app.ExpandRecord(gallery, []string{"images"}, nil); len(errs) > 0 {
+			return nil, errors.InternalError("Failed to load gallery images", nil)
+		}
+
+		claims["gallery"] = gallery.Id
+		access.Gallery = &ShareGallery{
+			ID:       gallery.Id,
+			Name:     gallery.GetString("name"),
+			Location: gallery.GetString("location"),
+		}
+		for _, image := range gallery.ExpandedAll("images") {
+			access.Images = append(access.Images, ShareImage{ID: image.Id, Filename: image.GetString("image")})
+		}
+	}
+
+	// file tokens never outlive the share link itself
+	duration := time.Duration(s.cfg.Shares.FileTokenDuration) * time.Second
+	duration = min(duration, time.Until(share.GetDateTime("expires").Time()))
+
+	fileToken, err := security.NewJWT(claims, share.GetString("token_key"), duration)
+	if err != nil {
+		return nil, errors.InternalError("Failed to create file token", err)
+	}
+
+	access.FileToken = fileToken
+	access.FileTokenExpires = time.Now().Add(duration).UTC()
+
+	return access, nil
+}
+
+// Find returns the active share link of the token without using it, unknown
+// tokens count as failed attempts of the client
+func (s *ShareServiceImpl) Find(token, clientIP string) (*core.Record, error) {
+	if s.failures.Blocked(clientIP) {
+		return nil, errors.TooManyRequests("Too many failed attempts, try again later")
+	}
+
+	share, err := s.app.FindFirstRecordByFilter(
+		"share_links",
+		"token_hash = {:hash}",
+		dbx.Params{"hash": hashShareToken(token)},
+	)
+	if err != nil {
+		s.failures.Add(clientIP)
+		return nil, errors.NotFound("Share link not found")
+	}
+
+	if err := checkShareActive(share); err != nil {
+		return nil, err
+	}
+
+	if share.GetDateTime("locked_until").Time().After(time.Now()) {
+		return nil, errors.TooManyRequests("Too many failed attempts, try again later")
+	}
+
+	return share, nil
+}
+
+func (s *ShareServiceImpl) VerifyFileToken(token string) (*ShareGrant, error) {
+	claims, err := security.ParseUnverifiedJWT(token)
+	if err != nil || claims["type"] != shareTokenType {
+		return nil, errors.Unauthorized("Invalid or expired file token")
+	}
+
+	shareID, _ := claims["id"].(string)
+	share, err := s.app.FindRecordById("share_links", shareID)
+	if err != nil {
+		return nil, errors.Unauthorized("Invalid or expired file token")
+	}
+
+	if _, err := security.ParseJWT(token, share.GetString("token_key")); err != nil {
+		return nil, errors.Unauthorized("Invalid or expired file token")
+	}
+
+	if err := checkShareActive(share); err != nil {
+		return nil, err
+	}
+
+	return &ShareGrant{Share: share, GalleryID: share.GetString("gallery"), AlbumID: share.GetString("album")}, nil
+}
+
+func (s *ShareServiceImpl) OpenImage(grant *ShareGrant, imageID string, download bool) (io.ReadCloser, *core.Record, error) {
+	if !grant.Can(validation.SharePermissionView) && !grant.Can(validation.SharePermissionDownload) {
+		return nil, nil, errors.Forbidden("This share link does not allow viewing images")
+	}
+
+	if download && !grant.Can(validation.SharePermissionDownload) {
+		return nil, nil, errors.Forbidden("This share link does not allow downloads")
+	}
+
+	if grant.AlbumID != "" {
+		album, err := s.app.FindRecordById("smart_albums", grant.AlbumID)
+		if err != nil {
+			return nil, nil, errors.NotFound("Image not found")
+		}
+		ok, err := albumHasImage(s.app, album, imageID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			return nil, nil, errors.NotFound("Image not found")
+		}
+	} else {
+		gallery, err := s.app.FindRecordById("galleries", grant.GalleryID)
+		if err != nil || IsTrashed(gallery) || !slices.Contains(gallery.GetStringSlice("images"), imageID) {
+			return nil, nil, errors.NotFound("Image not found")
+		}
+	}
+
+	image, err := s.app.FindRecordById("images", imageID)
+	if err != nil || image.GetString("image") == "" {
+		return nil, nil, errors.NotFound("Image not found")
+	}
+
+	reader, err := openImage(s.app, image)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return reader, image, nil
+}
+
+// recordFailedAttempt counts a wrong password and locks the link once the limit is reached
+func (s *ShareServiceImpl) recordFailedAttempt(share *core.Record) {
+	attempts := share.GetInt("failed_attempts") + 1
+	if attempts >= s.cfg.Shares.MaxAttempts {
+		lockout := time.Duration(s.cfg.Shares.LockoutDuration) * time.Second
+		share.Set("locked_until", time.Now().Add(lockout))
+		attempts = 0
+	}
+	share.Set("failed_attempts", attempts)
+
+	if err := s.app.Save(share); err != nil {
+		s.app.Logger().Error("Failed to record share link attempt", "shareID", share.Id, "error", err)
+	}
+}
+
+func checkShareActive(share *core.Record) error {
+	if share.GetBool("revoked") {
+		return errors.Forbidden("This share link has been revoked")
+	}
+	if share.GetDateTime("expires").Time().Before(time.Now()) {
+		return errors.Forbidden("This share link has expired")
+	}
+	return nil
+}
+
+// hashShareToken hashes share tokens before storing or looking them up
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,203 @@
+package container
+
+import (
+	"strconv"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// TimelineImage is an image of the timeline. Captured is when the photo was
+// taken, or when it was uploaded if Taken is false.
+type TimelineImage struct {
+	ID               string `json:"id"`
+	Gallery          string `json:"gallery"`
+	File             string `json:"file"`
+	OriginalFilename string `json:"original_filename"`
+	Caption          string `json:"caption"`
+	Captured         string `json:"captured"`
+	Taken            bool   `json:"taken"`
+}
+
+// TimelineDay holds the images of the page captured on a day
+type TimelineDay struct {
+	Day   int             `json:"day"`
+	Date  string          `json:"date"`
+	Count int             `json:"count"`
+	Items []TimelineImage `json:"items"`
+}
+
+// TimelineMonth holds the days of the page in a month
+type TimelineMonth struct {
+	Month int            `json:"month"`
+	Count int            `json:"count"`
+	Days  []*TimelineDay `json:"days"`
+}
+
+// TimelineYear holds the months of the page in a year
+type TimelineYear struct {
+	Year   int              `json:"year"`
+	Count  int              `json:"count"`
+	Months []*TimelineMonth `json:"months"`
+}
+
+// Timeline is a page of images, newest first, grouped by capture date. The
+// counts are the totals of each year, month and day, so a bucket split across
+// pages shows its full size on the first of them.
+type Timeline struct {
+	Years      []*TimelineYear `json:"years"`
+	TotalItems int             `json:"totalItems"`
+	NextCursor string          `json:"nextCursor"`
+}
+
+// TimelineServiceImpl implements TimelineService
+type TimelineServiceImpl struct {
+	app *pocketbase.PocketBase
+}
+
+func NewTimelineService(app *pocketbase.PocketBase) TimelineService {
+	return &TimelineServiceImpl{app: app}
+}
+
+func (s *TimelineServiceImpl) Timeline(client *GalleryClient, req *validation.TimelineRequest) (*Timeline, error) {
+	params := dbx.Params{}
+
+	access, err := galleryAccessFilter(client, "g", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var conditions []string
+	if access != "" {
+		conditions = append(conditions, access)
+	}
+
+	// the images of the galleries the client can view, each once even when in several galleries
+	from := " FROM images i JOIN (SELECT j.value AS image, min(g.id) AS gallery" +
+		" FROM galleries g, json_each(g.images) j" + whereClause(conditions) +
+		" GROUP BY j.value) a ON a.image = i.id"
+
+	result := &Timeline{Years: []*TimelineYear{}}
+
+	err = s.app.DB().NewQuery("SELECT count(*)" + from).Bind(params).Row(&result.TotalItems)
+	if err != nil {
+		return nil, errors.InternalError("Failed to count timeline images", err)
+	}
+
+	var where []string
+	if req.CursorAt != "" {
+		where = append(where, "(captured < {:cursorAt} OR (captured = {:cursorAt} AND i.id < {:cursorId}))")
+		params["cursorAt"] = req.CursorAt
+		params["cursorId"] = req.CursorID
+	}
+	params["limit"] = req.PerPage + 1
+
+	rows := []struct {
+		ID               string `db:"id"`
+		Gallery          string `db:"gallery"`
+		File             string `db:"image"`
+		OriginalFilename string `db:"original_filename"`
+		Caption          string `db:"caption"`
+		Captured         string `db:"captured"`
+		Taken            bool   `db:"taken"`
+	}{}
+
+	err = s.app.DB().NewQuery(
+		"SELECT * FROM (SELECT i.id, a.gallery, i.image, i.original_filename, i.caption," +
+			" " + capturedExpr + " AS captured, i.taken_at != '' AS taken" + from + ") i" +
+			whereClause(where) + " ORDER BY captured DESC, i.id DESC LIMIT {:limit}",
+	).Bind(params).All(&rows)
+	if err != nil {
+		return nil, errors.InternalError("Failed to load the timeline", err)
+	}
+
+	if len(rows) > req.PerPage {
+		rows = rows[:req.PerPage]
+		last := rows[len(rows)-1]
+		result.NextCursor = validation.TimelineCursor(last.Captured, last.ID)
+	}
+	if len(rows) == 0 {
+		return result, nil
+	}
+
+	counts, err := s.dayCounts(from, params, rows[len(rows)-1].Captured, rows[0].Captured)
+	if err != nil {
+		return nil, err
+	}
+
+	var year *TimelineYear
+	var month *TimelineMonth
+	var day *TimelineDay
+	for _, row := range rows {
+		date := row.Captured[:10]
+		y, m, d := datePart(date, 0, 4), datePart(date, 5, 7), datePart(date, 8, 10)
+
+		if year == nil || year.Year != y {
+			year = &TimelineYear{Year: y, Count: counts[date[:4]], Months: []*TimelineMonth{}}
+			result.Years = append(result.Years, year)
+			month = nil
+		}
+		if month == nil || month.Month != m {
+			month = &TimelineMonth{Month: m, Count: counts[date[:7]], Days: []*TimelineDay{}}
+			year.Months = append(year.Months, month)
+			day = nil
+		}
+		if day == nil || day.Date != date {
+			day = &TimelineDay{Day: d, Date: date, Count: counts[date], Items: []TimelineImage{}}
+			month.Days = append(month.Days, day)
+		}
+
+		day.Items = append(day.Items, TimelineImage{
+			ID:               row.ID,
+			Gallery:          row.Gallery,
+			File:             row.File,
+			OriginalFilename: row.OriginalFilename,
+			Caption:          row.Caption,
+			Captured:         row.Captured,
+			Taken:            row.Taken,
+		})
+	}
+
+	return result, nil
+}
+
+// capturedExpr is the capture date of an image, falling back to its upload date
+const capturedExpr = "coalesce(nullif(i.taken_at, ''), i.created)"
+
+// dayCounts returns the number of images per year, month and day, keyed by
+// their date prefix, for the years between the oldest and newest capture dates
+func (s *TimelineServiceImpl) dayCounts(from string, params dbx.Params, oldest, newest string) (map[string]int, error) {
+	rows := []struct {
+		Date  string `db:"date"`
+		Count int    `db:"count"`
+	}{}
+
+	err := s.app.DB().NewQuery(
+		"SELECT substr(" + capturedExpr + ", 1, 10) AS date, count(*) AS count" + from +
+			" WHERE " + capturedExpr + " >= {:fromYear} AND " + capturedExpr + " < {:toYear}" +
+			" GROUP BY date",
+	).Bind(params).Bind(dbx.Params{
+		"fromYear": oldest[:4],
+		"toYear":   strconv.Itoa(datePart(newest, 0, 4) + 1),
+	}).All(&rows)
+	if err != nil {
+		return nil, errors.InternalError("Failed to count timeline images", err)
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Date[:4]] += row.Count
+		counts[row.Date[:7]] += row.Count
+		counts[row.Date] += row.Count
+	}
+	return counts, nil
+}
+
+// datePart parses the digits of a date between start and end
+func datePart(date string, start, end int) int {
+	value, _ := strconv.Atoi(date[start:end])
+	return value
+}
@@ -0,0 +1,278 @@
+package container
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/dorianlgs/photo-cifu/pkg/config"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+)
+
+// TrashedGallery is a gallery in the trash, its images are trashed with it
+type TrashedGallery struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Images    int            `json:"images"`
+	DeletedAt types.DateTime `json:"deleted_at"`
+	PurgeAt   types.DateTime `json:"purge_at"`
+}
+
+// TrashedImage is an image deleted from a gallery it is restored to
+type TrashedImage struct {
+	ID               string         `json:"id"`
+	Gallery          string         `json:"gallery"`
+	GalleryName      string         `json:"gallery_name"`
+	File             string         `json:"file"`
+	OriginalFilename string         `json:"original_filename"`
+	DeletedAt        types.DateTime `json:"deleted_at"`
+	PurgeAt          types.DateTime `json:"purge_at"`
+}
+
+// Trash lists the trashed galleries and images, most recently deleted first.
+// They are purged for good at PurgeAt.
+type Trash struct {
+	Galleries []TrashedGallery `json:"galleries"`
+	Images    []TrashedImage   `json:"images"`
+}
+
+// IsTrashed reports whether the gallery or image is in the trash
+func IsTrashed(record *core.Record) bool {
+	return !record.GetDateTime("deleted_at").IsZero()
+}
+
+// MoveGalleryToTrash hides the gallery, its images and share links until it is
+// restored or purged
+func MoveGalleryToTrash(app core.App, gallery *core.Record) error {
+	gallery.Set("deleted_at", types.NowDateTime())
+	return app.Save(gallery)
+}
+
+// MoveImageToTrash takes the image out of the gallery and remembers the gallery
+// to restore it to. Images that are also in another gallery are only taken out.
+func MoveImageToTrash(app core.App, gallery, image *core.Record) error {
+	return app.RunInTransaction(func(txApp core.App) error {
+		gallery.Set("images-", image.Id)
+		if err := txApp.Save(gallery); err != nil {
+			return fmt.Errorf("failed to save gallery: %w", err)
+		}
+
+		if FindImageGallery(txApp, image.Id) != nil {
+			return nil
+		}
+
+		image.Set("deleted_at", types.NowDateTime())
+		image.Set("deleted_from", gallery.Id)
+		if err := txApp.Save(image); err != nil {
+			return fmt.Errorf("failed to save image: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// TrashServiceImpl implements TrashService
+type TrashServiceImpl struct {
+	app *pocketbase.PocketBase
+	cfg *config.Config
+}
+
+func NewTrashService(app *pocketbase.PocketBase, cfg *config.Config) TrashService {
+	return &TrashServiceImpl{app: app, cfg: cfg}
+}
+
+// List returns the trash of the user's galleries, superusers see all of it
+func (s *TrashServiceImpl) List(info *core.RequestInfo) (*Trash, error) {
+	galleryFilter := "deleted_at != ''"
+	imageFilter := "deleted_at != ''"
+	params := dbx.Params{}
+	if !info.HasSuperuserAuth() {
+		galleryFilter += " && owner = {:user}"
+		imageFilter += " && deleted_from.owner = {:user}"
+		params["user"] = info.Auth.Id
+	}
+
+	galleries, err := s.app.FindRecordsByFilter("galleries", galleryFilter, "-deleted_at", 0, 0, params)
+	if err != nil {
+		return nil, errors.InternalError("Failed to load trashed galleries", err)
+	}
+
+	images, err := s.app.FindRecordsByFilter("images", imageFilter, "-deleted_at", 0, 0, params)
+	if err != nil {
+		return nil, errors.InternalError("Failed to load trashed images", err)
+	}
+
+	if errs := s.app.ExpandRecords(images, []string{"deleted_from"}, nil); len(errs) > 0 {
+		return nil, errors.InternalError("Failed to load the galleries of trashed images", nil)
+	}
+
+	trash := &Trash{
+		Galleries: make([]TrashedGallery, 0, len(galleries)),
+		Images:    make([]TrashedImage, 0, len(images)),
+	}
+
+	for _, gallery := range galleries {
+		trash.Galleries = append(trash.Galleries, TrashedGallery{
+			ID:        gallery.Id,
+			Name:      gallery.GetString("name"),
+			Images:    len(gallery.GetStringSlice("images")),
+			DeletedAt: gallery.GetDateTime("deleted_at"),
+			PurgeAt:   s.purgeAt(gallery),
+		})
+	}
+
+	for _, image := range images {
+		item := TrashedImage{
+			ID:               image.Id,
+			Gallery:          image.GetString("deleted_from"),
+			File:             image.GetString("image"),
+			OriginalFilename: image.GetString("original_filename"),
+			DeletedAt:        image.GetDateTime("deleted_at"),
+			PurgeAt:          s.purgeAt(image),
+		}
+		if gallery := image.ExpandedOne("deleted_from"); gallery != nil {
+			item.GalleryName = gallery.GetString("name")
+		}
+		trash.Images = append(trash.Images, item)
+	}
+
+	return trash, nil
+}
+
+// RestoreGallery takes the gallery out of the trash along with its images
+func (s *TrashServiceImpl) RestoreGallery(info *core.RequestInfo, galleryID string) (*core.Record, error) {
+	gallery, err := s.trashedGallery(info, galleryID)
+	if err != nil {
+		return nil, err
+	}
+
+	// trashed galleries don't count against the plan, restored ones do again
+	if err := CheckGalleryQuota(s.app, s.cfg, gallery.GetString("owner")); err != nil {
+		return nil, err
+	}
+
+	gallery.Set("deleted_at", "")
+	if err := s.app.Save(gallery); err != nil {
+		return nil, errors.InternalError("Failed to restore gallery", err)
+	}
+
+	return gallery, nil
+}
+
+// RestoreImage puts the image back at the end of the gallery it was deleted from
+func (s *TrashServiceImpl) RestoreImage(info *core.RequestInfo, imageID string) (*core.Record, error) {
+	image, gallery, err := s.trashedImage(info, imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if IsTrashed(gallery) {
+		return nil, errors.ValidationError("Restore the gallery of this image first", nil)
+	}
+
+	if limit := GalleryImageLimit(s.app, s.cfg, gallery); len(gallery.GetStringSlice("images")) >= limit {
+		return nil, errors.ValidationError(
+			fmt.Sprintf("Gallery cannot contain more than %d images", limit),
+			nil,
+		)
+	}
+
+	transactErr := s.app.RunInTransaction(func(txApp core.App) error {
+		image.Set("deleted_at", "")
+		image.Set("deleted_from", "")
+		if err := txApp.Save(image); err != nil {
+			return fmt.Errorf("failed to save image: %w", err)
+		}
+
+		gallery.Set("images+", image.Id)
+		if err := txApp.Save(gallery); err != nil {
+			return fmt.Errorf("failed to save gallery: %w", err)
+		}
+
+		return nil
+	})
+
+	if transactErr != nil {
+		return nil, errors.InternalError("Failed to restore image", transactErr)
+	}
+
+	return image, nil
+}
+
+// DeleteGallery purges a trashed gallery right away, with its images and files
+// (see galleries.Register)
+func (s *TrashServiceImpl) DeleteGallery(info *core.RequestInfo, galleryID string) error {
+	gallery, err := s.trashedGallery(info, galleryID)
+	if err != nil {
+		return err
+	}
+
+	transactErr := s.app.RunInTransaction(func(txApp core.App) error {
+		return txApp.Delete(gallery)
+	})
+
+	if transactErr != nil {
+		return errors.InternalError("Failed to delete gallery", transactErr)
+	}
+
+	return nil
+}
+
+// DeleteImage purges a trashed image right away, with its files
+func (s *TrashServiceImpl) DeleteImage(info *core.RequestInfo, imageID string) error {
+	image, _, err := s.trashedImage(info, imageID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.app.Delete(image); err != nil {
+		return errors.InternalError("Failed to delete image", err)
+	}
+
+	return nil
+}
+
+// trashedGallery loads a trashed gallery of the user
+func (s *TrashServiceImpl) trashedGallery(info *core.RequestInfo, galleryID string) (*core.Record, error) {
+	gallery, err := s.app.FindRecordById("galleries", galleryID)
+	if err != nil || !IsTrashed(gallery) {
+		return nil, errors.NotFound("Gallery not found in trash")
+	}
+
+	if !info.HasSuperuserAuth() && GalleryRole(s.app, info.Auth, gallery) != RoleOwner {
+		return nil, errors.Forbidden("You are not allowed to manage the trash of this gallery")
+	}
+
+	return gallery, nil
+}
+
+// trashedImage loads a trashed image of the user and the gallery it was deleted from
+func (s *TrashServiceImpl) trashedImage(info *core.RequestInfo, imageID string) (*core.Record, *core.Record, error) {
+	image, err := s.app.FindRecordById("images", imageID)
+	if err != nil || !IsTrashed(image) {
+		return nil, nil, errors.NotFound("Image not found in trash")
+	}
+
+	// deleting a gallery cascades to the images trashed from it
+	gallery, err := s.app.FindRecordById("galleries", image.GetString("deleted_from"))
+	if err != nil {
+		return nil, nil, errors.NotFound("Image not found in trash")
+	}
+
+	if !info.HasSuperuserAuth() && GalleryRole(s.app, info.Auth, gallery) != RoleOwner {
+		return nil, nil, errors.Forbidden("You are not allowed to manage the trash of this gallery")
+	}
+
+	return image, gallery, nil
+}
+
+// purgeAt is when the trash purge deletes the record for good
+func (s *TrashServiceImpl) purgeAt(record *core.Record) types.DateTime {
+	deletedAt := record.GetDateTime("deleted_at").Time()
+	purgeAt, _ := types.ParseDateTime(deletedAt.Add(time.Duration(s.cfg.Trash.Retention) * time.Second))
+	return purgeAt
+}
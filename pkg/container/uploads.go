@@ -0,0 +1,306 @@
+package container
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/dorianlgs/photo-cifu/pkg/config"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/storage"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// Upload session statuses
+const (
+	UploadStatusPending    = "pending"
+	UploadStatusProcessing = "processing"
+	UploadStatusCompleted  = "completed"
+	UploadStatusFailed     = "failed"
+)
+
+// UploadKeyPrefix is the storage prefix of objects uploaded through upload sessions
+const UploadKeyPrefix = "uploads/"
+
+// UploadTarget is a presigned URL the client uploads a single file to
+type UploadTarget struct {
+	Role     string `json:"role"`
+	Filename string `json:"filename"`
+	Key      string `json:"key"`
+	Method   string `json:"method"`
+	URL      string `json:"url"`
+}
+
+// UploadSession is returned to the client when an upload session is created
+type UploadSession struct {
+	ID      string         `json:"session_id"`
+	Expires time.Time      `json:"expires"`
+	Uploads []UploadTarget `json:"uploads"`
+}
+
+// uploadFile is a file expected by an upload session, stored in its files field
+type uploadFile struct {
+	Role     string `json:"role"`
+	Filename string `json:"filename"`
+	Key      string `json:"key"`
+}
+
+// UploadServiceImpl implements UploadService
+type UploadServiceImpl struct {
+	app       *pocketbase.PocketBase
+	cfg       *config.Config
+	galleries GalleryService
+}
+
+func NewUploadService(app *pocketbase.PocketBase, cfg *config.Config, galleries GalleryService) UploadService {
+	return &UploadServiceImpl{app: app, cfg: cfg, galleries: galleries}
+}
+
+func (s *UploadServiceImpl) CreateSession(auth *core.Record, req *validation.UploadSessionRequest) (*UploadSession, error) {
+	settings := s.app.Settings()
+	if !settings.S3.Enabled {
+		return nil, errors.BadRequest("Direct uploads require S3 storage to be enabled", nil)
+	}
+
+	// reject oversized uploads early, they are checked again once uploaded
+	for _, file := range append(req.Files, req.Thumbnail) {
+		if file.Size > s.cfg.Gallery.MaxFileSize {
+			return nil, errors.ValidationError(fmt.Sprintf("%s exceeds maximum size limit", file.Filename), nil)
+		}
+	}
+
+	if limit := imageLimit(s.app, s.cfg, auth.Id); req.Mode == validation.UploadModeImages && len(req.Files) > limit {
+		return nil, errors.ValidationError(
+			fmt.Sprintf("Gallery cannot contain more than %d images", limit),
+			nil,
+		)
+	}
+
+	// the plan is checked again when the gallery is created
+	if err := CheckGalleryQuota(s.app, s.cfg, auth.Id); err != nil {
+		return nil, err
+	}
+	var size int64
+	for _, file := range req.Files {
+		size += file.Size
+	}
+	if req.Mode == validation.UploadModeImages && size > s.cfg.Gallery.MaxFileSize {
+		return nil, errors.ValidationError("Images exceed maximum total size limit", nil)
+	}
+	if err := CheckStorageQuota(s.app, s.cfg, auth.Id, size); err != nil {
+		return nil, err
+	}
+
+	collection, err := s.app.FindCollectionByNameOrId("upload_sessions")
+	if err != nil {
+		return nil, errors.InternalError("Failed to find upload sessions collection", err)
+	}
+
+	sessionID := core.GenerateDefaultRandomId()
+	prefix := UploadKeyPrefix + sessionID + "/"
+
+	files := make([]uploadFile, 0, len(req.Files)+1)
+	for i, file := range req.Files {
+		files = append(files, uploadFile{
+			Role:     "image",
+			Filename: file.Filename,
+			Key:      fmt.Sprintf("%s%d_%s", prefix, i, file.Filename),
+		})
+	}
+	files = append(files, uploadFile{
+		Role:     "thumbnail",
+		Filename: req.Thumbnail.Filename,
+		Key:      prefix + "thumbnail_" + req.Thumbnail.Filename,
+	})
+
+	expiry := time.Duration(s.cfg.Uploads.URLExpiry) * time.Second
+	presigner := storage.NewPresigner(settings.S3)
+
+	session := &UploadSession{
+		ID:      sessionID,
+		Expires: time.Now().Add(expiry).UTC(),
+		Uploads: make([]UploadTarget, 0, len(files)),
+	}
+
+	for _, file := range files {
+		url, err := presigner.PresignPut(file.Key, expiry)
+		if err != nil {
+			return nil, errors.InternalError("Failed to presign upload URL", err)
+		}
+		session.Uploads = append(session.Uploads, UploadTarget{
+			Role:     file.Role,
+			Filename: file.Filename,
+			Key:      file.Key,
+			Method:   "PUT",
+			URL:      url,
+		})
+	}
+
+	record := core.NewRecord(collection)
+	record.Id = sessionID
+	record.Set("user", auth.Id)
+	record.Set("mode", req.Mode)
+	record.Set("status", UploadStatusPending)
+	record.Set("name", req.Name)
+	record.Set("location", req.Location)
+	record.Set("files", files)
+	record.Set("expires", session.Expires)
+
+	if err := s.app.Save(record); err != nil {
+		return nil, errors.InternalError("Failed to save upload session", err)
+	}
+
+	return session, nil
+}
+
+func (s *UploadServiceImpl) CompleteSession(auth *core.Record, sessionID string) (string, error) {
+	record, err := s.app.FindRecordById("upload_sessions", sessionID)
+	if err != nil || record.GetString("user") != auth.Id {
+		return "", errors.NotFound("Upload session not found")
+	}
+
+	if record.GetDateTime("expires").Time().Before(time.Now()) {
+		return "", errors.BadRequest("Upload session has expired", nil)
+	}
+
+	// claim the session so concurrent completions don't ingest it twice
+	claimed, err := s.setStatus(sessionID, UploadStatusPending, UploadStatusProcessing)
+	if err != nil {
+		return "", errors.InternalError("Failed to update upload session", err)
+	}
+	if !claimed {
+		return "", errors.BadRequest(fmt.Sprintf("Upload session is already %s", record.GetString("status")), nil)
+	}
+
+	var files []uploadFile
+	if err := record.UnmarshalJSONField("files", &files); err != nil {
+		s.setStatus(sessionID, UploadStatusProcessing, UploadStatusPending)
+		return "", errors.InternalError("Failed to read upload session files", err)
+	}
+
+	data, err := s.readUploads(files)
+	if err != nil {
+		// the client may still finish the missing uploads and retry
+		s.setStatus(sessionID, UploadStatusProcessing, UploadStatusPending)
+		return "", err
+	}
+
+	galleryID, ingestErr := s.ingest(record, files, data)
+
+	if ingestErr != nil {
+		record.Set("status", UploadStatusFailed)
+		record.Set("error", ingestErr.Error())
+	} else {
+		record.Set("status", UploadStatusCompleted)
+		record.Set("gallery", galleryID)
+	}
+	if err := s.app.Save(record); err != nil {
+		s.app.Logger().Error("Failed to save upload session", "sessionID", sessionID, "error", err)
+	}
+
+	s.removeUploads(sessionID)
+
+	if ingestErr != nil {
+		return "", ingestErr
+	}
+
+	return galleryID, nil
+}
+
+// ingest creates the gallery from the uploaded objects with the same limits as regular uploads
+func (s *UploadServiceImpl) ingest(record *core.Record, files []uploadFile, data [][]byte) (string, error) {
+	var thumbnail uploadFile
+	var thumbnailData []byte
+	var images []ImageFile
+
+	for i, file := range files {
+		if file.Role == "thumbnail" {
+			thumbnail = file
+			thumbnailData = data[i]
+			continue
+		}
+		images = append(images, ImageFile{Filename: file.Filename, Data: data[i]})
+	}
+
+	ownerID := record.GetString("user")
+	name := record.GetString("name")
+	location := record.GetString("location")
+
+	if record.GetString("mode") == validation.UploadModeArchive {
+		if len(images) != 1 {
+			return "", errors.BadRequest("Archive upload session must contain exactly one zip file", nil)
+		}
+		return s.galleries.CreateGallery(ownerID, name, location, images[0].Data, thumbnailData, images[0].Filename, thumbnail.Filename)
+	}
+
+	return s.galleries.CreateGalleryFromImages(ownerID, name, location, images, thumbnailData, thumbnail.Filename)
+}
+
+func (s *UploadServiceImpl) readUploads(files []uploadFile) ([][]byte, error) {
+	fsys, err := s.app.NewFilesystem()
+	if err != nil {
+		return nil, errors.InternalError("Failed to open storage", err)
+	}
+	defer fsys.Close()
+
+	data := make([][]byte, len(files))
+	for i, file := range files {
+		attrs, err := fsys.Attributes(file.Key)
+		if err != nil {
+			return nil, errors.ValidationError(fmt.Sprintf("%s has not been uploaded", file.Filename), nil)
+		}
+
+		if attrs.Size > s.cfg.Gallery.MaxFileSize {
+			return nil, errors.ValidationError(fmt.Sprintf("%s exceeds maximum size limit", file.Filename), nil)
+		}
+
+		r, err := fsys.GetReader(file.Key)
+		if err != nil {
+			return nil, errors.InternalError(fmt.Sprintf("Failed to open %s", file.Filename), err)
+		}
+		data[i], err = io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, errors.InternalError(fmt.Sprintf("Failed to read %s", file.Filename), err)
+		}
+	}
+
+	return data, nil
+}
+
+// setStatus moves a session from one status to another, reporting whether it was in the expected status
+func (s *UploadServiceImpl) setStatus(sessionID, from, to string) (bool, error) {
+	result, err := s.app.DB().Update(
+		"upload_sessions",
+		dbx.Params{"status": to, "updated": types.NowDateTime().String()},
+		dbx.HashExp{"id": sessionID, "status": from},
+	).Execute()
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (s *UploadServiceImpl) removeUploads(sessionID string) {
+	fsys, err := s.app.NewFilesystem()
+	if err != nil {
+		s.app.Logger().Warn("Failed to open storage", "error", err)
+		return
+	}
+	defer fsys.Close()
+
+	if errs := fsys.DeletePrefix(UploadKeyPrefix + sessionID + "/"); len(errs) > 0 {
+		s.app.Logger().Warn("Failed to remove uploaded objects", "sessionID", sessionID, "errors", errs)
+	}
+}
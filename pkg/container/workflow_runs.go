@@ -0,0 +1,340 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/backend/history"
+	workflowcore "github.com/cschleiden/go-workflows/core"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// Workflow run statuses, see validation.WorkflowStatuses
+const (
+	WorkflowStatusRunning   = "running"
+	WorkflowStatusWaiting   = "waiting"
+	WorkflowStatusCompleted = "completed"
+	WorkflowStatusFailed    = "failed"
+	WorkflowStatusCanceled  = "canceled"
+	WorkflowStatusUnknown   = "unknown"
+)
+
+// maxWorkflowEvents bounds the history returned for a run, the latest events are kept
+const maxWorkflowEvents = 200
+
+// statusRefreshBatch is the number of unfinished runs whose state is read
+// from the workflow backend at once when statuses are refreshed
+const statusRefreshBatch = 100
+
+// workflowEventTypes are the history events reported for a run, the others
+// are bookkeeping of the workflow engine
+var workflowEventTypes = map[history.EventType]string{
+	history.EventType_WorkflowExecutionStarted:    "started",
+	history.EventType_WorkflowExecutionFinished:   "finished",
+	history.EventType_WorkflowExecutionCanceled:   "canceled",
+	history.EventType_WorkflowExecutionTerminated: "terminated",
+	history.EventType_ActivityScheduled:           "activity_scheduled",
+	history.EventType_ActivityCompleted:           "activity_completed",
+	history.EventType_ActivityFailed:              "activity_failed",
+	history.EventType_SubWorkflowScheduled:        "subworkflow_scheduled",
+	history.EventType_SubWorkflowCompleted:        "subworkflow_completed",
+	history.EventType_SubWorkflowFailed:           "subworkflow_failed",
+	history.EventType_TimerScheduled:              "timer_scheduled",
+	history.EventType_TimerFired:                  "timer_fired",
+	history.EventType_TimerCanceled:               "timer_canceled",
+	history.EventType_SignalReceived:              "signal_received",
+}
+
+// WorkflowRun is a recorded workflow run and its last known status
+type WorkflowRun struct {
+	InstanceID   string         `json:"instance_id"`
+	WorkflowType string         `json:"workflow_type"`
+	Status       string         `json:"status"`
+	User         string         `json:"user"`
+	Gallery      string         `json:"gallery"`
+	Created      types.DateTime `json:"created"`
+	Finished     types.DateTime `json:"finished"`
+}
+
+// WorkflowRunDetail is a workflow run with its result or error and its history
+type WorkflowRunDetail struct {
+	WorkflowRun
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	// History are the latest maxWorkflowEvents events of the run
+	History          []WorkflowEvent `json:"history"`
+	HistoryTruncated bool            `json:"history_truncated"`
+}
+
+// WorkflowEvent is a step of a workflow run, Name is the activity, sub
+// workflow, timer or signal it concerns
+type WorkflowEvent struct {
+	SequenceID int64     `json:"sequence_id"`
+	Type       string    `json:"type"`
+	Name       string    `json:"name,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// WorkflowRunList is a page of workflow runs, latest first
+type WorkflowRunList struct {
+	Page       int           `json:"page"`
+	PerPage    int           `json:"perPage"`
+	TotalItems int           `json:"totalItems"`
+	Items      []WorkflowRun `json:"items"`
+}
+
+// GetWorkflow returns the status, result or error and history of a workflow run.
+// Users see the runs they started, superusers see every run.
+func (s *WorkflowServiceImpl) GetWorkflow(auth *core.Record, instanceID string) (*WorkflowRunDetail, error) {
+	run, err := s.app.FindFirstRecordByData("workflow_runs", "instance_id", instanceID)
+	if err != nil || auth == nil || (!auth.IsSuperuser() && run.GetString("user") != auth.Id) {
+		return nil, errors.NotFound("Workflow not found")
+	}
+
+	detail, err := s.inspect(run)
+	if err != nil {
+		return nil, errors.InternalError("Failed to read workflow history", err)
+	}
+
+	return detail, nil
+}
+
+// ListWorkflows lists the workflow runs visible to the auth record, filtered
+// by type, status and gallery. Runs are listed with their stored status, see
+// RefreshWorkflows.
+func (s *WorkflowServiceImpl) ListWorkflows(auth *core.Record, req *validation.WorkflowListRequest) (*WorkflowRunList, error) {
+	if auth == nil {
+		return nil, errors.Unauthorized("Authentication required")
+	}
+
+	scope := dbx.NewExp("1 = 1")
+	if !auth.IsSuperuser() {
+		scope = dbx.HashExp{"user": auth.Id}
+	}
+
+	conditions := []dbx.Expression{scope}
+	if req.Type != "" {
+		conditions = append(conditions, dbx.HashExp{"workflow_type": req.Type})
+	}
+	if req.Status != "" {
+		conditions = append(conditions, dbx.HashExp{"status": req.Status})
+	}
+	if req.Gallery != "" {
+		conditions = append(conditions, dbx.HashExp{"gallery": req.Gallery})
+	}
+
+	list := &WorkflowRunList{Page: req.Page, PerPage: req.PerPage, Items: []WorkflowRun{}}
+
+	total, err := s.app.CountRecords("workflow_runs", conditions...)
+	if err != nil {
+		return nil, errors.InternalError("Failed to count workflow runs", err)
+	}
+	list.TotalItems = int(total)
+
+	runs := []*core.Record{}
+	err = s.app.RecordQuery("workflow_runs").
+		AndWhere(dbx.And(conditions...)).
+		OrderBy("created DESC", "id DESC").
+		Limit(int64(req.PerPage)).
+		Offset(int64((req.Page - 1) * req.PerPage)).
+		All(&runs)
+	if err != nil {
+		return nil, errors.InternalError("Failed to load workflow runs", err)
+	}
+
+	for _, run := range runs {
+		list.Items = append(list.Items, workflowRun(run))
+	}
+
+	return list, nil
+}
+
+// RefreshWorkflows stores the status of the unfinished runs that finished.
+// Only the state of a run is read from the workflow backend, its history is
+// read once it finished to tell whether it completed or failed. Whether a
+// run still active is running or waiting is stored when the run is read.
+func (s *WorkflowServiceImpl) RefreshWorkflows() error {
+	ctx := context.Background()
+	lastID := ""
+
+	for {
+		runs := []*core.Record{}
+		err := s.app.RecordQuery("workflow_runs").
+			AndWhere(dbx.In("status", WorkflowStatusRunning, WorkflowStatusWaiting)).
+			AndWhere(dbx.NewExp("execution_id != ''")).
+			AndWhere(dbx.NewExp("id > {:lastID}", dbx.Params{"lastID": lastID})).
+			OrderBy("id").
+			Limit(statusRefreshBatch).
+			All(&runs)
+		if err != nil {
+			return err
+		}
+
+		for _, run := range runs {
+			instance := workflowcore.NewWorkflowInstance(run.GetString("instance_id"), run.GetString("execution_id"))
+			state, err := s.backend.GetWorkflowInstanceState(ctx, instance)
+			if err != nil {
+				if !stderrors.Is(err, backend.ErrInstanceNotFound) {
+					s.app.Logger().Warn("Failed to read workflow state", "instanceID", run.GetString("instance_id"), "error", err)
+				}
+				continue
+			}
+			if state != workflowcore.WorkflowInstanceStateFinished {
+				continue
+			}
+			if _, err := s.inspect(run); err != nil {
+				s.app.Logger().Warn("Failed to refresh workflow status", "instanceID", run.GetString("instance_id"), "error", err)
+			}
+		}
+
+		if len(runs) < statusRefreshBatch {
+			return nil
+		}
+		lastID = runs[len(runs)-1].Id
+	}
+}
+
+// inspect reads the state and history of a run from the workflow backend and
+// stores its status when it changed. Runs the backend no longer knows keep
+// their last known status, runs recorded without an execution id are unknown.
+func (s *WorkflowServiceImpl) inspect(run *core.Record) (*WorkflowRunDetail, error) {
+	detail := &WorkflowRunDetail{WorkflowRun: workflowRun(run), History: []WorkflowEvent{}}
+
+	if run.GetString("execution_id") == "" {
+		return detail, nil
+	}
+
+	ctx := context.Background()
+	instance := workflowcore.NewWorkflowInstance(run.GetString("instance_id"), run.GetString("execution_id"))
+
+	state, err := s.backend.GetWorkflowInstanceState(ctx, instance)
+	if stderrors.Is(err, backend.ErrInstanceNotFound) {
+		return detail, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := s.backend.GetWorkflowInstanceHistory(ctx, instance, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	status := WorkflowStatusRunning
+	var finished time.Time
+
+	// activities and sub workflows scheduled and not completed yet, by schedule event
+	pending := map[int64]string{}
+
+	for _, event := range events {
+		eventType, ok := workflowEventTypes[event.Type]
+		if !ok {
+			continue
+		}
+		step := WorkflowEvent{SequenceID: event.SequenceID, Type: eventType, Timestamp: event.Timestamp}
+
+		switch a := event.Attributes.(type) {
+		case *history.ExecutionStartedAttributes:
+			step.Name = a.Name
+		case *history.ExecutionCompletedAttributes:
+			finished = event.Timestamp
+			if a.Error != nil {
+				status = WorkflowStatusFailed
+				detail.Error = a.Error.Message
+				step.Error = a.Error.Message
+			} else {
+				status = WorkflowStatusCompleted
+				if len(a.Result) > 0 {
+					detail.Result = json.RawMessage(a.Result)
+				}
+			}
+		case *history.ExecutionCanceledAttributes:
+			finished = event.Timestamp
+			status = WorkflowStatusCanceled
+		case *history.ActivityScheduledAttributes:
+			step.Name = a.Name
+			pending[event.ScheduleEventID] = a.Name
+		case *history.SubWorkflowScheduledAttributes:
+			step.Name = a.Name
+			pending[event.ScheduleEventID] = a.Name
+		case *history.ActivityFailedAttributes:
+			if a.Error != nil {
+				step.Error = a.Error.Message
+			}
+		case *history.SubWorkflowFailedAttributes:
+			if a.Error != nil {
+				step.Error = a.Error.Message
+			}
+		case *history.TimerScheduledAttributes:
+			step.Name = a.Name
+		case *history.TimerFiredAttributes:
+			step.Name = a.Name
+		case *history.SignalReceivedAttributes:
+			step.Name = a.Name
+		}
+
+		switch event.Type {
+		case history.EventType_ActivityCompleted, history.EventType_ActivityFailed,
+			history.EventType_SubWorkflowCompleted, history.EventType_SubWorkflowFailed:
+			step.Name = pending[event.ScheduleEventID]
+			delete(pending, event.ScheduleEventID)
+		case history.EventType_WorkflowExecutionTerminated:
+			finished = event.Timestamp
+			status = WorkflowStatusFailed
+			detail.Error = "workflow terminated"
+		}
+
+		detail.History = append(detail.History, step)
+	}
+
+	// a started run with nothing left to run is blocked on a signal or a timer
+	if status == WorkflowStatusRunning && state == workflowcore.WorkflowInstanceStateActive && len(events) > 0 && len(pending) == 0 {
+		status = WorkflowStatusWaiting
+	}
+
+	if len(detail.History) > maxWorkflowEvents {
+		detail.History = detail.History[len(detail.History)-maxWorkflowEvents:]
+		detail.HistoryTruncated = true
+	}
+
+	if status != run.GetString("status") || (!finished.IsZero() && run.GetDateTime("finished").IsZero()) {
+		run.Set("status", status)
+		if !finished.IsZero() {
+			run.Set("finished", finished)
+		}
+		if err := s.app.Save(run); err != nil {
+			s.app.Logger().Warn("Failed to save workflow status", "instanceID", run.GetString("instance_id"), "error", err)
+		}
+	}
+
+	detail.WorkflowRun = workflowRun(run)
+	detail.WorkflowRun.Status = status
+
+	return detail, nil
+}
+
+func workflowRun(run *core.Record) WorkflowRun {
+	status := run.GetString("status")
+	if status == "" {
+		status = WorkflowStatusUnknown
+	}
+
+	return WorkflowRun{
+		InstanceID:   run.GetString("instance_id"),
+		WorkflowType: run.GetString("workflow_type"),
+		Status:       status,
+		User:         run.GetString("user"),
+		Gallery:      run.GetString("gallery"),
+		Created:      run.GetDateTime("created"),
+		Finished:     run.GetDateTime("finished"),
+	}
+}
@@ -0,0 +1,59 @@
+package dav
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/container"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+)
+
+// MaxAuthFailures is the number of failed logins allowed per client address
+// within AuthLockout
+const MaxAuthFailures = 10
+
+// AuthLockout is the window failed WebDAV logins are counted in
+const AuthLockout = 15 * time.Minute
+
+// Authenticate resolves the user of a WebDAV request from HTTP basic credentials.
+// The username is the account email and the password is either the account
+// password or a PocketBase auth token (useful for OAuth2-only accounts).
+// Failed logins are counted per client IP, a client past MaxAuthFailures is
+// refused until the window ends. Accounts are not locked, so nobody can lock
+// another user out by failing to log in as them.
+func Authenticate(app core.App, failures *container.AttemptLimiter, r *http.Request, clientIP string) (*core.Record, error) {
+	email, password, ok := r.BasicAuth()
+	if !ok || password == "" {
+		return nil, nil
+	}
+
+	if failures.Blocked(clientIP) {
+		return nil, errors.TooManyRequests("Too many failed login attempts, try again later")
+	}
+
+	if record := authenticate(app, email, password); record != nil {
+		return record, nil
+	}
+
+	failures.Add(clientIP)
+
+	return nil, nil
+}
+
+func authenticate(app core.App, email, password string) *core.Record {
+	if record, err := app.FindAuthRecordByToken(password, core.TokenTypeAuth); err == nil {
+		if email == "" || email == record.Email() {
+			return record
+		}
+		return nil
+	}
+
+	record, err := app.FindAuthRecordByEmail("users", email)
+	if err != nil || !record.ValidatePassword(password) {
+		return nil
+	}
+
+	return record
+}
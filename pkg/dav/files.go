@@ -0,0 +1,190 @@
+package dav
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// fileInfo implements os.FileInfo for galleries and images
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.dir }
+func (fi *fileInfo) Sys() any           { return nil }
+
+func (fi *fileInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+// ContentType avoids reading the whole image to sniff its type on PROPFIND
+func (fi *fileInfo) ContentType(ctx context.Context) (string, error) {
+	if ct := mime.TypeByExtension(filepath.Ext(fi.name)); ct != "" {
+		return ct, nil
+	}
+	return "application/octet-stream", nil
+}
+
+func rootInfo() *fileInfo {
+	return &fileInfo{name: "/", dir: true}
+}
+
+func galleryInfo(name string, gallery *core.Record) *fileInfo {
+	return &fileInfo{name: name, dir: true, modTime: gallery.GetDateTime("updated").Time()}
+}
+
+// dirFile is an open directory listing
+type dirFile struct {
+	info     *fileInfo
+	children []os.FileInfo
+	pos      int
+}
+
+func newDirFile(info *fileInfo, children []os.FileInfo) *dirFile {
+	return &dirFile{info: info, children: children}
+}
+
+func (f *dirFile) Close() error                                 { return nil }
+func (f *dirFile) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (f *dirFile) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+func (f *dirFile) Write(p []byte) (int, error)                  { return 0, os.ErrPermission }
+func (f *dirFile) Stat() (os.FileInfo, error)                   { return f.info, nil }
+
+func (f *dirFile) Readdir(count int) ([]os.FileInfo, error) {
+	remaining := f.children[f.pos:]
+	if count <= 0 {
+		f.pos = len(f.children)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	count = min(count, len(remaining))
+	f.pos += count
+	return remaining[:count], nil
+}
+
+// imageFile is an image opened for reading; its content is loaded on first access
+type imageFile struct {
+	app    core.App
+	image  *core.Record
+	info   *fileInfo
+	reader *bytes.Reader
+}
+
+func newImageFile(app core.App, image *core.Record, info *fileInfo) *imageFile {
+	return &imageFile{app: app, image: image, info: info}
+}
+
+func (f *imageFile) load() error {
+	if f.reader != nil {
+		return nil
+	}
+
+	fsys, err := f.app.NewFilesystem()
+	if err != nil {
+		return err
+	}
+	defer fsys.Close()
+
+	r, err := fsys.GetReader(f.image.BaseFilesPath() + "/" + f.image.GetString("image"))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	f.reader = bytes.NewReader(data)
+	return nil
+}
+
+func (f *imageFile) Read(p []byte) (int, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.reader.Read(p)
+}
+
+func (f *imageFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.reader.Seek(offset, whence)
+}
+
+func (f *imageFile) Close() error                             { return nil }
+func (f *imageFile) Write(p []byte) (int, error)              { return 0, os.ErrPermission }
+func (f *imageFile) Readdir(count int) ([]os.FileInfo, error) { return nil, os.ErrInvalid }
+func (f *imageFile) Stat() (os.FileInfo, error)               { return f.info, nil }
+
+// errFileTooLarge fails uploads larger than the maximum file size
+var errFileTooLarge = stderrors.New("file exceeds the maximum upload size")
+
+// uploadFile buffers written data and hands it to the GalleryService on Close
+type uploadFile struct {
+	fs       *FileSystem
+	gallery  *core.Record
+	image    *core.Record
+	filename string
+	buf      bytes.Buffer
+	closed   bool
+	tooLarge bool
+}
+
+// Write fails as soon as the upload exceeds the maximum file size, so large
+// bodies are not buffered whole
+func (f *uploadFile) Write(p []byte) (int, error) {
+	if f.tooLarge || int64(f.buf.Len()+len(p)) > f.fs.maxFileSize {
+		f.tooLarge = true
+		return 0, errFileTooLarge
+	}
+	return f.buf.Write(p)
+}
+
+func (f *uploadFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+
+	if f.tooLarge {
+		return errFileTooLarge
+	}
+
+	// some clients create an empty placeholder before sending the content
+	if f.buf.Len() == 0 {
+		return nil
+	}
+
+	return toFSError(f.fs.upload(f.gallery, f.image, f.filename, f.buf.Bytes()))
+}
+
+func (f *uploadFile) Stat() (os.FileInfo, error) {
+	return &fileInfo{name: f.filename, size: int64(f.buf.Len()), modTime: time.Now()}, nil
+}
+
+func (f *uploadFile) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (f *uploadFile) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+func (f *uploadFile) Readdir(count int) ([]os.FileInfo, error)     { return nil, os.ErrInvalid }
@@ -0,0 +1,423 @@
+package dav
+
+import (
+	"context"
+	stderrors "errors"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"golang.org/x/net/webdav"
+
+	"github.com/dorianlgs/photo-cifu/pkg/container"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// FileSystem exposes galleries as directories and their images as files.
+// Reads are checked against the gallery access rules of the requesting user,
+// while every modification goes through the GalleryService.
+type FileSystem struct {
+	app       core.App
+	galleries container.GalleryService
+	info      *core.RequestInfo
+	// maxFileSize bounds the data buffered for an upload
+	maxFileSize int64
+}
+
+var _ webdav.FileSystem = (*FileSystem)(nil)
+
+// NewFileSystem creates a WebDAV file system scoped to the given request
+func NewFileSystem(app core.App, galleries container.GalleryService, info *core.RequestInfo, maxFileSize int64) *FileSystem {
+	return &FileSystem{app: app, galleries: galleries, info: info, maxFileSize: maxFileSize}
+}
+
+// node is a resolved WebDAV path
+type node struct {
+	gallery *core.Record
+	image   *core.Record
+	name    string
+}
+
+func (n *node) isRoot() bool {
+	return n.gallery == nil
+}
+
+func (n *node) isDir() bool {
+	return n.image == nil
+}
+
+// Mkdir is not supported, galleries require a thumbnail and must be created through the app
+func (fs *FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return os.ErrPermission
+}
+
+func (fs *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	writing := flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) != 0
+
+	if !writing {
+		n, err := fs.resolve(name)
+		if err != nil {
+			return nil, err
+		}
+		if n.isDir() {
+			return fs.openDir(n)
+		}
+		return newImageFile(fs.app, n.image, fs.fileInfo(n.name, n.image)), nil
+	}
+
+	dir, filename := path.Split(cleanPath(name))
+	if filename == "" || isHiddenFile(filename) {
+		return nil, os.ErrPermission
+	}
+
+	parent, err := fs.resolve(dir)
+	if err != nil {
+		return nil, err
+	}
+	if parent.isRoot() || !parent.isDir() {
+		return nil, os.ErrPermission
+	}
+
+	var existing *core.Record
+	if n, err := fs.resolve(name); err == nil {
+		if n.isDir() {
+			return nil, os.ErrPermission
+		}
+		existing = n.image
+	}
+	if existing == nil && flag&os.O_CREATE == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	// contributors and editors can add images, only the owner can replace them
+	roles := []string{container.RoleOwner}
+	if existing == nil {
+		roles = append(roles, container.RoleEditor, container.RoleContributor)
+	}
+	if err := container.CheckGalleryRole(fs.app, fs.info, parent.gallery, roles...); err != nil {
+		return nil, toFSError(err)
+	}
+
+	return &uploadFile{fs: fs, gallery: parent.gallery, image: existing, filename: filename}, nil
+}
+
+func (fs *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	n, err := fs.resolve(name)
+	if err != nil {
+		return err
+	}
+
+	// removing whole galleries is not supported over WebDAV
+	if n.isDir() {
+		return os.ErrPermission
+	}
+	if err := container.CheckGalleryRole(fs.app, fs.info, n.gallery, container.RoleOwner); err != nil {
+		return toFSError(err)
+	}
+
+	return toFSError(fs.galleries.DeleteImage(n.gallery.Id, n.image.Id))
+}
+
+// Rename moves images between galleries. Renaming files is not supported,
+// images keep the name they were uploaded with.
+func (fs *FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	src, err := fs.resolve(oldName)
+	if err != nil {
+		return err
+	}
+	if src.isDir() {
+		return os.ErrPermission
+	}
+
+	dir, filename := path.Split(cleanPath(newName))
+	if filename != src.name {
+		return os.ErrPermission
+	}
+
+	dst, err := fs.resolve(dir)
+	if err != nil {
+		return err
+	}
+	if dst.isRoot() || !dst.isDir() {
+		return os.ErrPermission
+	}
+
+	// moving removes the image from its gallery and adds it to the other one
+	if err := container.CheckGalleryRole(fs.app, fs.info, src.gallery, container.RoleOwner); err != nil {
+		return toFSError(err)
+	}
+	if err := container.CheckGalleryRole(fs.app, fs.info, dst.gallery, container.RoleOwner, container.RoleEditor, container.RoleContributor); err != nil {
+		return toFSError(err)
+	}
+
+	return toFSError(fs.galleries.MoveImage(src.gallery.Id, src.image.Id, dst.gallery.Id))
+}
+
+func (fs *FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	n, err := fs.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case n.isRoot():
+		return rootInfo(), nil
+	case n.isDir():
+		return galleryInfo(n.name, n.gallery), nil
+	default:
+		return fs.fileInfo(n.name, n.image), nil
+	}
+}
+
+// resolve maps a WebDAV path to the gallery and image it refers to
+func (fs *FileSystem) resolve(name string) (*node, error) {
+	name = cleanPath(name)
+	if name == "" {
+		return &node{}, nil
+	}
+
+	parts := strings.Split(name, "/")
+	if len(parts) > 2 {
+		return nil, os.ErrNotExist
+	}
+
+	gallery, err := fs.findGallery(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parts) == 1 {
+		return &node{gallery: gallery, name: parts[0]}, nil
+	}
+
+	images, err := fs.listImages(gallery)
+	if err != nil {
+		return nil, err
+	}
+
+	image, ok := images[parts[1]]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	return &node{gallery: gallery, image: image, name: parts[1]}, nil
+}
+
+// findGallery returns the accessible gallery of a directory name, the
+// galleries sharing a name are told apart by the id listGalleries appends
+func (fs *FileSystem) findGallery(name string) (*core.Record, error) {
+	if base, id, ok := splitDisambiguated(name); ok {
+		records, err := container.FindUserGalleries(fs.app, fs.info, dbx.HashExp{"id": id})
+		if err != nil {
+			return nil, toFSError(err)
+		}
+		if len(records) == 1 && dirName(records[0]) == base {
+			return records[0], nil
+		}
+	}
+
+	// galleries without a name are listed by their id
+	records, err := container.FindUserGalleries(fs.app, fs.info, dbx.Or(
+		dbx.NewExp("trim(replace(name, '/', '-')) = {:name}", dbx.Params{"name": name}),
+		dbx.NewExp("trim(name) = '' AND id = {:name}", dbx.Params{"name": name}),
+	))
+	if err != nil {
+		return nil, toFSError(err)
+	}
+
+	var found *core.Record
+	for _, r := range records {
+		if dirName(r) != name {
+			continue
+		}
+		// galleries sharing the name are only listed with their id
+		if found != nil {
+			return nil, os.ErrNotExist
+		}
+		found = r
+	}
+	if found == nil {
+		return nil, os.ErrNotExist
+	}
+
+	return found, nil
+}
+
+// listGalleries returns the accessible galleries keyed by their directory name
+func (fs *FileSystem) listGalleries() (map[string]*core.Record, error) {
+	records, err := container.FindUserGalleries(fs.app, fs.info)
+	if err != nil {
+		return nil, toFSError(err)
+	}
+
+	counts := map[string]int{}
+	for _, r := range records {
+		counts[dirName(r)]++
+	}
+
+	galleries := make(map[string]*core.Record, len(records))
+	for _, r := range records {
+		name := dirName(r)
+		// disambiguate galleries sharing the same name
+		if counts[name] > 1 {
+			name += " (" + r.Id + ")"
+		}
+		galleries[name] = r
+	}
+
+	return galleries, nil
+}
+
+// listImages returns the images of a gallery keyed by their file name, the
+// name they were uploaded with
+func (fs *FileSystem) listImages(gallery *core.Record) (map[string]*core.Record, error) {
+	records, err := fs.app.FindRecordsByIds("images", gallery.GetStringSlice("images"))
+	if err != nil {
+		return nil, toFSError(err)
+	}
+
+	counts := map[string]int{}
+	visible := make([]*core.Record, 0, len(records))
+	for _, r := range records {
+		if r.GetString("image") == "" {
+			continue
+		}
+		visible = append(visible, r)
+		counts[fileName(r)]++
+	}
+
+	images := make(map[string]*core.Record, len(visible))
+	for _, r := range visible {
+		name := fileName(r)
+		// disambiguate images uploaded with the same name, keeping the extension
+		if counts[name] > 1 {
+			ext := path.Ext(name)
+			name = strings.TrimSuffix(name, ext) + " (" + r.Id + ")" + ext
+		}
+		images[name] = r
+	}
+
+	return images, nil
+}
+
+func (fs *FileSystem) openDir(n *node) (webdav.File, error) {
+	var children []os.FileInfo
+
+	if n.isRoot() {
+		galleries, err := fs.listGalleries()
+		if err != nil {
+			return nil, err
+		}
+		for name, gallery := range galleries {
+			children = append(children, galleryInfo(name, gallery))
+		}
+		return newDirFile(rootInfo(), children), nil
+	}
+
+	images, err := fs.listImages(n.gallery)
+	if err != nil {
+		return nil, err
+	}
+	for name, image := range images {
+		children = append(children, fs.fileInfo(name, image))
+	}
+
+	return newDirFile(galleryInfo(n.name, n.gallery), children), nil
+}
+
+func (fs *FileSystem) fileInfo(name string, image *core.Record) *fileInfo {
+	var size int64
+
+	if fsys, err := fs.app.NewFilesystem(); err == nil {
+		if attrs, err := fsys.Attributes(image.BaseFilesPath() + "/" + image.GetString("image")); err == nil {
+			size = attrs.Size
+		}
+		fsys.Close()
+	}
+
+	return &fileInfo{
+		name:    name,
+		size:    size,
+		modTime: image.GetDateTime("updated").Time(),
+	}
+}
+
+// upload validates and stores the data written to an uploadFile
+func (fs *FileSystem) upload(gallery, image *core.Record, filename string, data []byte) error {
+	req := &validation.ImageUploadRequest{
+		GalleryID: gallery.Id,
+		Filename:  filename,
+		Data:      data,
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	if image != nil {
+		return fs.galleries.ReplaceImage(gallery.Id, image.Id, filename, data)
+	}
+
+	_, err := fs.galleries.AddImage(gallery.Id, filename, data)
+	return err
+}
+
+// Helper functions
+func cleanPath(name string) string {
+	return strings.Trim(path.Clean("/"+name), "/")
+}
+
+func dirName(gallery *core.Record) string {
+	name := strings.TrimSpace(strings.ReplaceAll(gallery.GetString("name"), "/", "-"))
+	if name == "" {
+		return gallery.Id
+	}
+	return name
+}
+
+// fileName is the name an image was uploaded with, images stored before the
+// original filename was kept use their stored name
+func fileName(image *core.Record) string {
+	if name := strings.ReplaceAll(image.GetString("original_filename"), "/", "-"); name != "" {
+		return name
+	}
+	return image.GetString("image")
+}
+
+// splitDisambiguated splits a "name (id)" entry into its name and record id
+func splitDisambiguated(name string) (string, string, bool) {
+	if !strings.HasSuffix(name, ")") {
+		return "", "", false
+	}
+	i := strings.LastIndex(name, " (")
+	if i < 0 {
+		return "", "", false
+	}
+	return name[:i], name[i+2 : len(name)-1], true
+}
+
+// isHiddenFile filters out the metadata files created by desktop clients
+func isHiddenFile(filename string) bool {
+	return strings.HasPrefix(filename, ".") || filename == "Thumbs.db" || filename == "desktop.ini"
+}
+
+// toFSError maps service errors to the os errors understood by the webdav handler
+func toFSError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var appErr *errors.AppError
+	if stderrors.As(err, &appErr) {
+		switch appErr.Code {
+		case "NOT_FOUND":
+			return os.ErrNotExist
+		case "FORBIDDEN", "UNAUTHORIZED":
+			return os.ErrPermission
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,262 @@
+// Package enhance applies the automatic corrections of the image enhancement
+// workflow: levels, white balance, sharpening, denoising and resizing.
+package enhance
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"slices"
+	"sort"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp"
+)
+
+// Operations, applied in the order they are given
+const (
+	OpAutoLevels       = "auto_levels"
+	OpAutoWhiteBalance = "auto_white_balance"
+	OpSharpen          = "sharpen"
+	OpDenoise          = "denoise"
+	OpResize           = "resize"
+)
+
+// Operations are the supported operations
+var Operations = []string{OpAutoLevels, OpAutoWhiteBalance, OpSharpen, OpDenoise, OpResize}
+
+// levelsClip is the fraction of the darkest and brightest pixels clipped by auto levels
+const levelsClip = 0.005
+
+// maxGain bounds the channel gains of auto white balance, so images dominated
+// by a single color are not turned gray
+const maxGain = 2.0
+
+// sharpenSigma is the radius of the unsharp mask applied by sharpen
+const sharpenSigma = 1.0
+
+// Options are the operations to apply and their parameters
+type Options struct {
+	Operations []string
+	// MaxWidth and MaxHeight bound the size of resized images, which keep
+	// their aspect ratio and are never upscaled. Zero leaves a side unbounded.
+	MaxWidth  int
+	MaxHeight int
+}
+
+// Validate checks the operations are supported, given once, and that resize has a bound
+func (o *Options) Validate() error {
+	if len(o.Operations) == 0 {
+		return fmt.Errorf("at least one operation is required")
+	}
+
+	for i, op := range o.Operations {
+		if !slices.Contains(Operations, op) {
+			return fmt.Errorf("unsupported operation %q", op)
+		}
+		if slices.Contains(o.Operations[:i], op) {
+			return fmt.Errorf("operation %q is given more than once", op)
+		}
+	}
+
+	if o.MaxWidth < 0 || o.MaxHeight < 0 {
+		return fmt.Errorf("max width and height must not be negative")
+	}
+	if slices.Contains(o.Operations, OpResize) && o.MaxWidth == 0 && o.MaxHeight == 0 {
+		return fmt.Errorf("resize requires a max width or height")
+	}
+
+	return nil
+}
+
+// Enhance decodes the image, applies the operations and encodes the result in
+// the format of filename. Formats that cannot be encoded are written as JPEG,
+// the returned extension is the one of the encoded format.
+func Enhance(r io.Reader, filename string, opts Options) ([]byte, string, error) {
+	src, err := imaging.Decode(r, imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	img := Apply(src, opts)
+
+	format, err := imaging.FormatFromFilename(filename)
+	if err != nil {
+		format = imaging.JPEG
+	}
+
+	buf := new(bytes.Buffer)
+	if err := imaging.Encode(buf, img, format, imaging.JPEGQuality(92)); err != nil {
+		return nil, "", fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	return buf.Bytes(), extensions[format], nil
+}
+
+var extensions = map[imaging.Format]string{
+	imaging.JPEG: ".jpg",
+	imaging.PNG:  ".png",
+	imaging.GIF:  ".gif",
+	imaging.TIFF: ".tif",
+	imaging.BMP:  ".bmp",
+}
+
+// Apply applies the operations to the image in their order
+func Apply(src image.Image, opts Options) *image.NRGBA {
+	img := imaging.Clone(src)
+
+	for _, op := range opts.Operations {
+		switch op {
+		case OpAutoLevels:
+			img = autoLevels(img)
+		case OpAutoWhiteBalance:
+			img = autoWhiteBalance(img)
+		case OpSharpen:
+			img = imaging.Sharpen(img, sharpenSigma)
+		case OpDenoise:
+			img = median(img)
+		case OpResize:
+			img = fit(img, opts.MaxWidth, opts.MaxHeight)
+		}
+	}
+
+	return img
+}
+
+// autoLevels stretches the luminance range of the image to the full range,
+// ignoring the darkest and brightest levelsClip of the pixels
+func autoLevels(img *image.NRGBA) *image.NRGBA {
+	var histogram [256]int
+	total := 0
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i+3] == 0 {
+			continue
+		}
+		histogram[luminance(img.Pix[i], img.Pix[i+1], img.Pix[i+2])]++
+		total++
+	}
+	if total == 0 {
+		return img
+	}
+
+	clip := int(float64(total) * levelsClip)
+	low, high := 0, 255
+	for count := 0; low < 255; low++ {
+		if count += histogram[low]; count > clip {
+			break
+		}
+	}
+	for count := 0; high > 0; high-- {
+		if count += histogram[high]; count > clip {
+			break
+		}
+	}
+	if high <= low {
+		return img
+	}
+
+	var lut [256]uint8
+	for v := range lut {
+		lut[v] = clamp(float64(v-low) * 255 / float64(high-low))
+	}
+
+	return imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{R: lut[c.R], G: lut[c.G], B: lut[c.B], A: c.A}
+	})
+}
+
+// autoWhiteBalance scales the channels so their averages match, the gray world assumption
+func autoWhiteBalance(img *image.NRGBA) *image.NRGBA {
+	var sum [3]float64
+	total := 0
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i+3] == 0 {
+			continue
+		}
+		sum[0] += float64(img.Pix[i])
+		sum[1] += float64(img.Pix[i+1])
+		sum[2] += float64(img.Pix[i+2])
+		total++
+	}
+	if total == 0 || sum[0] == 0 || sum[1] == 0 || sum[2] == 0 {
+		return img
+	}
+
+	gray := (sum[0] + sum[1] + sum[2]) / 3
+	var gains [3]float64
+	for i := range gains {
+		gains[i] = min(max(gray/sum[i], 1/maxGain), maxGain)
+	}
+
+	return imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{
+			R: clamp(float64(c.R) * gains[0]),
+			G: clamp(float64(c.G) * gains[1]),
+			B: clamp(float64(c.B) * gains[2]),
+			A: c.A,
+		}
+	})
+}
+
+// median replaces every channel of each pixel by its median over the 3x3
+// neighbourhood, removing noise while keeping edges
+func median(img *image.NRGBA) *image.NRGBA {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+
+	var window [9]int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			o := y*dst.Stride + x*4
+			for ch := 0; ch < 3; ch++ {
+				n := 0
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						sx := min(max(x+dx, 0), w-1)
+						sy := min(max(y+dy, 0), h-1)
+						window[n] = int(img.Pix[sy*img.Stride+sx*4+ch])
+						n++
+					}
+				}
+				sort.Ints(window[:])
+				dst.Pix[o+ch] = uint8(window[4])
+			}
+			dst.Pix[o+3] = img.Pix[y*img.Stride+x*4+3]
+		}
+	}
+
+	return dst
+}
+
+// fit downscales the image to fit within the bounds, zero leaves a side unbounded
+func fit(img *image.NRGBA, maxWidth, maxHeight int) *image.NRGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if maxWidth == 0 {
+		maxWidth = w
+	}
+	if maxHeight == 0 {
+		maxHeight = h
+	}
+	if w <= maxWidth && h <= maxHeight {
+		return img
+	}
+
+	return imaging.Fit(img, maxWidth, maxHeight, imaging.Lanczos)
+}
+
+func luminance(r, g, b uint8) uint8 {
+	return clamp(0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b))
+}
+
+func clamp(v float64) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
@@ -49,6 +49,38 @@ func NotFound(message string) *AppError {
 	}
 }
 
+func Unauthorized(message string) *AppError {
+	return &AppError{
+		Code:    "UNAUTHORIZED",
+		Message: message,
+		Status:  http.StatusUnauthorized,
+	}
+}
+
+func Forbidden(message string) *AppError {
+	return &AppError{
+		Code:    "FORBIDDEN",
+		Message: message,
+		Status:  http.StatusForbidden,
+	}
+}
+
+func TooManyRequests(message string) *AppError {
+	return &AppError{
+		Code:    "TOO_MANY_REQUESTS",
+		Message: message,
+		Status:  http.StatusTooManyRequests,
+	}
+}
+
+func NotImplemented(message string) *AppError {
+	return &AppError{
+		Code:    "NOT_IMPLEMENTED",
+		Message: message,
+		Status:  http.StatusNotImplemented,
+	}
+}
+
 func ValidationError(message string, cause error) *AppError {
 	return &AppError{
 		Code:    "VALIDATION_ERROR",
@@ -64,10 +96,18 @@ func HandleError(e *core.RequestEvent, err error) error {
 		switch appErr.Status {
 		case http.StatusBadRequest:
 			return e.BadRequestError(appErr.Message, appErr.Cause)
+		case http.StatusUnauthorized:
+			return e.UnauthorizedError(appErr.Message, appErr.Cause)
+		case http.StatusForbidden:
+			return e.ForbiddenError(appErr.Message, appErr.Cause)
 		case http.StatusNotFound:
 			return e.NotFoundError(appErr.Message, appErr.Cause)
+		case http.StatusTooManyRequests:
+			return e.TooManyRequestsError(appErr.Message, appErr.Cause)
 		case http.StatusUnprocessableEntity:
 			return e.BadRequestError(appErr.Message, appErr.Cause)
+		case http.StatusNotImplemented:
+			return e.Error(http.StatusNotImplemented, appErr.Message, appErr.Cause)
 		default:
 			return e.InternalServerError(appErr.Message, appErr.Cause)
 		}
@@ -0,0 +1,324 @@
+// Package exif reads the few EXIF fields the app uses from JPEG and TIFF files.
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrNoExif is returned for files without EXIF metadata
+var ErrNoExif = errors.New("exif: no exif metadata")
+
+var errInvalid = errors.New("exif: invalid exif metadata")
+
+// EXIF tags
+const (
+	tagMake               = 0x010F
+	tagModel              = 0x0110
+	tagDateTime           = 0x0132
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagDateTimeOriginal   = 0x9003
+	tagDateTimeDigitized  = 0x9004
+	tagOffsetTimeOriginal = 0x9011
+	tagFocalLength        = 0x920A
+	tagLensMake           = 0xA433
+	tagLensModel          = 0xA434
+)
+
+// GPS IFD tags
+const (
+	tagGPSLatitudeRef  = 0x0001
+	tagGPSLatitude     = 0x0002
+	tagGPSLongitudeRef = 0x0003
+	tagGPSLongitude    = 0x0004
+)
+
+// EXIF value types
+const (
+	typeASCII    = 2
+	typeShort    = 3
+	typeLong     = 4
+	typeRational = 5
+)
+
+const dateTimeLayout = "2006:01:02 15:04:05"
+
+// Metadata holds the EXIF fields of an image
+type Metadata struct {
+	Make      string
+	Model     string
+	LensMake  string
+	LensModel string
+	// FocalLength in millimeters, 0 when unknown
+	FocalLength float64
+	// Taken is the capture time. Without an offset tag it is the camera's
+	// local time stored as UTC.
+	Taken time.Time
+	// HasGPS reports whether Latitude and Longitude were read from the GPS IFD
+	HasGPS    bool
+	Latitude  float64
+	Longitude float64
+}
+
+// Camera returns the make and model, without repeating the make when the model already contains it
+func (m *Metadata) Camera() string {
+	if m.Model == "" {
+		return m.Make
+	}
+	if m.Make == "" || strings.HasPrefix(strings.ToLower(m.Model), strings.ToLower(m.Make)) {
+		return m.Model
+	}
+	return m.Make + " " + m.Model
+}
+
+// Lens returns the lens model, prefixed with the lens make when it isn't part of it
+func (m *Metadata) Lens() string {
+	if m.LensModel == "" || m.LensMake == "" || strings.HasPrefix(strings.ToLower(m.LensModel), strings.ToLower(m.LensMake)) {
+		return m.LensModel
+	}
+	return m.LensMake + " " + m.LensModel
+}
+
+// Decode reads the EXIF metadata of a JPEG or TIFF file
+func Decode(data []byte) (*Metadata, error) {
+	if bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")) {
+		return decodeTIFF(data)
+	}
+
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, ErrNoExif
+	}
+
+	// walk the JPEG segments up to the APP1 segment holding the EXIF data
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, ErrNoExif
+		}
+
+		marker := data[pos+1]
+		if marker == 0xD8 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) || marker == 0xFF {
+			pos += 2
+			continue
+		}
+
+		// start of scan, the metadata segments are all before it
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil, errInvalid
+		}
+
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return decodeTIFF(segment[6:])
+		}
+
+		pos += 2 + length
+	}
+
+	return nil, ErrNoExif
+}
+
+// reader reads IFD entries from a TIFF structure
+type reader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+type entry struct {
+	typ   uint16
+	count uint32
+	value []byte // the 4 byte value or offset field
+}
+
+func decodeTIFF(data []byte) (*Metadata, error) {
+	if len(data) < 8 {
+		return nil, errInvalid
+	}
+
+	r := &reader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return nil, errInvalid
+	}
+
+	ifd0, err := r.readIFD(r.order.Uint32(data[4:]))
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &Metadata{
+		Make:  r.string(ifd0[tagMake]),
+		Model: r.string(ifd0[tagModel]),
+	}
+
+	dateTime := r.string(ifd0[tagDateTime])
+
+	if offset, ok := r.uint(ifd0[tagExifIFD]); ok {
+		if exifIFD, err := r.readIFD(offset); err == nil {
+			meta.LensMake = r.string(exifIFD[tagLensMake])
+			meta.LensModel = r.string(exifIFD[tagLensModel])
+			meta.FocalLength = r.rational(exifIFD[tagFocalLength])
+
+			if original := r.string(exifIFD[tagDateTimeOriginal]); original != "" {
+				dateTime = original
+			} else if digitized := r.string(exifIFD[tagDateTimeDigitized]); digitized != "" {
+				dateTime = digitized
+			}
+
+			if zone := r.string(exifIFD[tagOffsetTimeOriginal]); zone != "" && dateTime != "" {
+				if t, err := time.Parse(dateTimeLayout+"-07:00", dateTime+zone); err == nil {
+					meta.Taken = t.UTC()
+				}
+			}
+		}
+	}
+
+	if offset, ok := r.uint(ifd0[tagGPSIFD]); ok {
+		if gpsIFD, err := r.readIFD(offset); err == nil {
+			lat, latOK := r.degrees(gpsIFD[tagGPSLatitude], r.string(gpsIFD[tagGPSLatitudeRef]), "S")
+			lon, lonOK := r.degrees(gpsIFD[tagGPSLongitude], r.string(gpsIFD[tagGPSLongitudeRef]), "W")
+			if latOK && lonOK && lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180 {
+				meta.HasGPS = true
+				meta.Latitude = lat
+				meta.Longitude = lon
+			}
+		}
+	}
+
+	if meta.Taken.IsZero() && dateTime != "" {
+		if t, err := time.Parse(dateTimeLayout, dateTime); err == nil {
+			meta.Taken = t
+		}
+	}
+
+	return meta, nil
+}
+
+// readIFD reads the entries of the IFD at the offset
+func (r *reader) readIFD(offset uint32) (map[uint16]*entry, error) {
+	if int64(offset)+2 > int64(len(r.data)) {
+		return nil, errInvalid
+	}
+
+	count := int(r.order.Uint16(r.data[offset:]))
+	start := int(offset) + 2
+	if start+count*12 > len(r.data) {
+		return nil, errInvalid
+	}
+
+	entries := make(map[uint16]*entry, count)
+	for i := 0; i < count; i++ {
+		b := r.data[start+i*12:]
+		entries[r.order.Uint16(b)] = &entry{
+			typ:   r.order.Uint16(b[2:]),
+			count: r.order.Uint32(b[4:]),
+			value: b[8:12],
+		}
+	}
+
+	return entries, nil
+}
+
+// bytes returns the raw value of the entry, which is stored inline when it fits in 4 bytes
+func (r *reader) bytes(e *entry, size int) []byte {
+	n := int64(e.count) * int64(size)
+	if n <= 4 {
+		return e.value[:n]
+	}
+
+	offset := int64(r.order.Uint32(e.value))
+	if offset+n > int64(len(r.data)) {
+		return nil
+	}
+	return r.data[offset : offset+n]
+}
+
+func (r *reader) string(e *entry) string {
+	if e == nil || e.typ != typeASCII {
+		return ""
+	}
+
+	value := r.bytes(e, 1)
+	if i := bytes.IndexByte(value, 0); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(string(value))
+}
+
+func (r *reader) uint(e *entry) (uint32, bool) {
+	if e == nil || e.count != 1 {
+		return 0, false
+	}
+
+	switch e.typ {
+	case typeShort:
+		return uint32(r.order.Uint16(e.value)), true
+	case typeLong:
+		return r.order.Uint32(e.value), true
+	}
+	return 0, false
+}
+
+// rational reads a single unsigned rational, 0 when it is missing or undefined
+func (r *reader) rational(e *entry) float64 {
+	if e == nil || e.typ != typeRational || e.count != 1 {
+		return 0
+	}
+
+	value := r.bytes(e, 8)
+	if value == nil {
+		return 0
+	}
+
+	numerator := r.order.Uint32(value)
+	denominator := r.order.Uint32(value[4:])
+	if denominator == 0 {
+		return 0
+	}
+	return float64(numerator) / float64(denominator)
+}
+
+// degrees reads a GPS coordinate stored as degrees, minutes and seconds
+// rationals, negated when the reference is the negative hemisphere
+func (r *reader) degrees(e *entry, ref, negative string) (float64, bool) {
+	if e == nil || e.typ != typeRational || e.count != 3 {
+		return 0, false
+	}
+
+	value := r.bytes(e, 8)
+	if value == nil {
+		return 0, false
+	}
+
+	var parts [3]float64
+	for i := range parts {
+		numerator := r.order.Uint32(value[i*8:])
+		denominator := r.order.Uint32(value[i*8+4:])
+		if denominator == 0 {
+			if numerator != 0 {
+				return 0, false
+			}
+			continue
+		}
+		parts[i] = float64(numerator) / float64(denominator)
+	}
+
+	degrees := parts[0] + parts[1]/60 + parts[2]/3600
+	if strings.EqualFold(ref, negative) {
+		degrees = -degrees
+	}
+	return degrees, true
+}
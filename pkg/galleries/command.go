@@ -0,0 +1,141 @@
+package galleries
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+
+	"github.com/dorianlgs/photo-cifu/pkg/container"
+)
+
+// NewCommand creates the galleries command group
+func NewCommand(app core.App) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "galleries",
+		Short: "Manage galleries",
+	}
+
+	command.AddCommand(assignOwnerCommand(app))
+	command.AddCommand(readMetadataCommand(app))
+
+	return command
+}
+
+// assignOwnerCommand backfills the owner of galleries created before ownership existed
+func assignOwnerCommand(app core.App) *cobra.Command {
+	var email string
+	var galleryIDs []string
+	var force bool
+
+	command := &cobra.Command{
+		Use:          "assign-owner",
+		Example:      "galleries assign-owner --email test@example.com",
+		Short:        "Assigns galleries without an owner (or the given galleries) to a user",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			user, err := app.FindAuthRecordByEmail("users", email)
+			if err != nil {
+				return fmt.Errorf("user %q not found", email)
+			}
+
+			var galleries []*core.Record
+			if len(galleryIDs) > 0 {
+				galleries, err = app.FindRecordsByIds("galleries", galleryIDs)
+				if err == nil && len(galleries) != len(galleryIDs) {
+					err = fmt.Errorf("some galleries were not found")
+				}
+			} else {
+				galleries, err = app.FindAllRecords("galleries", dbx.HashExp{"owner": ""})
+			}
+			if err != nil {
+				return fmt.Errorf("failed to load galleries: %w", err)
+			}
+
+			assigned := 0
+			for _, gallery := range galleries {
+				if owner := gallery.GetString("owner"); owner != "" && owner != user.Id && !force {
+					command.Printf("Skipping %s (%s), it is owned by another user; use --force to reassign\n", gallery.Id, gallery.GetString("name"))
+					continue
+				}
+
+				gallery.Set("owner", user.Id)
+				if err := app.SaveNoValidate(gallery); err != nil {
+					return fmt.Errorf("failed to save gallery %s: %w", gallery.Id, err)
+				}
+				assigned++
+			}
+
+			command.Printf("Assigned %d galleries to %s.\n", assigned, user.Email())
+
+			return nil
+		},
+	}
+
+	command.Flags().StringVar(&email, "email", "", "the email of the new owner")
+	command.Flags().StringSliceVar(&galleryIDs, "gallery", nil, "the galleries to assign (default: all galleries without an owner)")
+	command.Flags().BoolVar(&force, "force", false, "reassign galleries that already have another owner")
+	command.MarkFlagRequired("email")
+
+	return command
+}
+
+// readMetadataCommand backfills the EXIF metadata of images uploaded before it was read on upload
+func readMetadataCommand(app core.App) *cobra.Command {
+	var all bool
+
+	command := &cobra.Command{
+		Use:          "read-metadata",
+		Example:      "galleries read-metadata",
+		Short:        "Reads the size of images and their camera, lens, focal length, capture time and GPS position from their EXIF metadata",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			var filters []dbx.Expression
+			if !all {
+				filters = append(filters, dbx.Or(dbx.HashExp{"camera": "", "taken_at": ""}, dbx.HashExp{"size": 0}))
+			}
+
+			images, err := app.FindAllRecords("images", filters...)
+			if err != nil {
+				return fmt.Errorf("failed to load images: %w", err)
+			}
+
+			fsys, err := app.NewFilesystem()
+			if err != nil {
+				return fmt.Errorf("failed to open storage: %w", err)
+			}
+			defer fsys.Close()
+
+			updated := 0
+			for _, image := range images {
+				r, err := fsys.GetReader(image.BaseFilesPath() + "/" + image.GetString("image"))
+				if err != nil {
+					command.Printf("Skipping %s, its file could not be opened: %v\n", image.Id, err)
+					continue
+				}
+				data, err := io.ReadAll(r)
+				r.Close()
+				if err != nil {
+					command.Printf("Skipping %s, its file could not be read: %v\n", image.Id, err)
+					continue
+				}
+
+				container.SetImageMetadata(image, data)
+				if err := app.SaveNoValidate(image); err != nil {
+					return fmt.Errorf("failed to save image %s: %w", image.Id, err)
+				}
+				updated++
+			}
+
+			command.Printf("Read the metadata of %d images.\n", updated)
+
+			return nil
+		},
+	}
+
+	command.Flags().BoolVar(&all, "all", false, "read the metadata of all images, not only those without it")
+
+	return command
+}
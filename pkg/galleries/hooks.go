@@ -0,0 +1,87 @@
+package galleries
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/container"
+)
+
+// Register deletes the images of a gallery along with it, however the gallery
+// is deleted. The images relation doesn't cascade, so without this the images
+// and their files would be left behind.
+//
+// Deleting galleries and images through the records API moves them to the
+// trash, deleting them again from there removes them for good.
+func Register(app core.App) {
+	app.OnRecordDeleteRequest("galleries").BindFunc(func(e *core.RecordRequestEvent) error {
+		if container.IsTrashed(e.Record) {
+			return e.Next()
+		}
+
+		if err := container.MoveGalleryToTrash(e.App, e.Record); err != nil {
+			return e.BadRequestError("Failed to move the gallery to the trash.", err)
+		}
+
+		return e.NoContent(http.StatusNoContent)
+	})
+
+	app.OnRecordDeleteRequest("images").BindFunc(func(e *core.RecordRequestEvent) error {
+		// images without a gallery have nothing to be restored to
+		gallery := container.FindImageGallery(e.App, e.Record.Id)
+		if container.IsTrashed(e.Record) || gallery == nil {
+			return e.Next()
+		}
+
+		if err := container.MoveImageToTrash(e.App, gallery, e.Record); err != nil {
+			return e.BadRequestError("Failed to move the image to the trash.", err)
+		}
+
+		return e.NoContent(http.StatusNoContent)
+	})
+
+	app.OnRecordDelete("galleries").BindFunc(func(e *core.RecordEvent) error {
+		imageIDs := e.Record.GetStringSlice("images")
+
+		originalApp := e.App
+		defer func() { e.App = originalApp }()
+
+		return e.App.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+
+			if err := e.Next(); err != nil {
+				return err
+			}
+
+			return DeleteImages(txApp, imageIDs)
+		})
+	})
+}
+
+// DeleteImages deletes the images that are not in any gallery. Their likes,
+// comments and selection picks cascade, and their files and thumbnails are
+// removed from storage once the transaction is committed.
+func DeleteImages(app core.App, imageIDs []string) error {
+	if len(imageIDs) == 0 {
+		return nil
+	}
+
+	images, err := app.FindRecordsByIds("images", imageIDs)
+	if err != nil {
+		return fmt.Errorf("failed to load images: %w", err)
+	}
+
+	for _, image := range images {
+		// images moved or shared with another gallery stay
+		if container.FindImageGallery(app, image.Id) != nil {
+			continue
+		}
+		if err := app.Delete(image); err != nil {
+			return fmt.Errorf("failed to delete image %s: %w", image.Id, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package geo
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+)
+
+// NewCommand creates the geo command group
+func NewCommand(app core.App) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "geo",
+		Short: "Manage the geo index",
+	}
+
+	command.AddCommand(reindexCommand(app))
+
+	return command
+}
+
+// reindexCommand rebuilds the geo index from the images and galleries collections
+func reindexCommand(app core.App) *cobra.Command {
+	return &cobra.Command{
+		Use:          "reindex",
+		Example:      "geo reindex",
+		Short:        "Rebuilds the geo index of geotagged images",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			count, err := Rebuild(app)
+			if err != nil {
+				return fmt.Errorf("failed to rebuild the geo index: %w", err)
+			}
+
+			command.Printf("Indexed %d geotagged images.\n", count)
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,251 @@
+// Package geo maintains the R*Tree index of geotagged images and the
+// distance and clustering math of map queries.
+package geo
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Table is the R*Tree table holding the positions of geotagged images
+const Table = "geo_index"
+
+// Register keeps the index in sync with the images and galleries collections
+// and fills it on start if it is empty
+func Register(app core.App) {
+	app.OnRecordAfterCreateSuccess("images").BindFunc(func(e *core.RecordEvent) error {
+		logError(e.App, IndexImage(e.App, e.Record), e.Record)
+		return e.Next()
+	})
+	app.OnRecordAfterUpdateSuccess("images").BindFunc(func(e *core.RecordEvent) error {
+		logError(e.App, IndexImage(e.App, e.Record), e.Record)
+		return e.Next()
+	})
+	app.OnRecordAfterDeleteSuccess("images").BindFunc(func(e *core.RecordEvent) error {
+		logError(e.App, remove(e.App, e.Record.Id), e.Record)
+		return e.Next()
+	})
+
+	app.OnRecordAfterCreateSuccess("galleries").BindFunc(func(e *core.RecordEvent) error {
+		logError(e.App, IndexGallery(e.App, e.Record), e.Record)
+		return e.Next()
+	})
+	app.OnRecordAfterUpdateSuccess("galleries").BindFunc(func(e *core.RecordEvent) error {
+		logError(e.App, IndexGallery(e.App, e.Record), e.Record)
+		return e.Next()
+	})
+	app.OnRecordAfterDeleteSuccess("galleries").BindFunc(func(e *core.RecordEvent) error {
+		logError(e.App, detachGallery(e.App, e.Record.Id), e.Record)
+		return e.Next()
+	})
+
+	app.OnServe().BindFunc(func(e *core.ServeEvent) error {
+		var indexed, geotagged int
+		err := e.App.DB().Select("count(*)").From(Table).Row(&indexed)
+		if err == nil && indexed == 0 {
+			err = e.App.DB().Select("count(*)").From("images").
+				Where(dbx.NewExp("json_extract([[gps]], '$.lat') != 0 OR json_extract([[gps]], '$.lon') != 0")).
+				Row(&geotagged)
+			if err == nil && geotagged > 0 {
+				if count, err := Rebuild(e.App); err != nil {
+					e.App.Logger().Warn("Failed to build the geo index", "error", err)
+				} else {
+					e.App.Logger().Info("Built the geo index", "images", count)
+				}
+			}
+		}
+		return e.Next()
+	})
+}
+
+// Position returns the GPS position of an image record. Images without a
+// position have the zero point, which is never a real photo location.
+func Position(image *core.Record) (types.GeoPoint, bool) {
+	point := types.GeoPoint{}
+	if err := image.UnmarshalJSONField("gps", &point); err != nil {
+		return point, false
+	}
+	return point, point.Lat != 0 || point.Lon != 0
+}
+
+// IndexImage indexes the position of the image with the gallery it belongs to
+func IndexImage(app core.App, image *core.Record) error {
+	if err := remove(app, image.Id); err != nil {
+		return err
+	}
+
+	point, ok := Position(image)
+	if !ok {
+		return nil
+	}
+
+	galleryID := ""
+	if gallery, err := app.FindFirstRecordByFilter("galleries", "images.id ?= {:id}", dbx.Params{"id": image.Id}); err == nil {
+		galleryID = gallery.Id
+	}
+
+	return insert(app, image.Id, galleryID, point)
+}
+
+// IndexGallery points the indexed images of the gallery to it
+func IndexGallery(app core.App, gallery *core.Record) error {
+	if err := detachGallery(app, gallery.Id); err != nil {
+		return err
+	}
+
+	imageIDs := gallery.GetStringSlice("images")
+	if len(imageIDs) == 0 {
+		return nil
+	}
+
+	ids := make([]any, len(imageIDs))
+	for i, id := range imageIDs {
+		ids[i] = id
+	}
+
+	_, err := app.DB().Update(Table, dbx.Params{"gallery": gallery.Id}, dbx.In("image", ids...)).Execute()
+	if err != nil {
+		return fmt.Errorf("failed to update the images of gallery %s in the geo index: %w", gallery.Id, err)
+	}
+	return nil
+}
+
+// Rebuild recreates the whole index and returns the number of indexed images
+func Rebuild(app core.App) (int, error) {
+	count := 0
+
+	err := app.RunInTransaction(func(txApp core.App) error {
+		if _, err := txApp.DB().Delete(Table, nil).Execute(); err != nil {
+			return fmt.Errorf("failed to clear the geo index: %w", err)
+		}
+
+		galleries, err := txApp.FindAllRecords("galleries")
+		if err != nil {
+			return fmt.Errorf("failed to load galleries: %w", err)
+		}
+
+		galleryOf := map[string]string{}
+		for _, gallery := range galleries {
+			for _, imageID := range gallery.GetStringSlice("images") {
+				galleryOf[imageID] = gallery.Id
+			}
+		}
+
+		images, err := txApp.FindAllRecords("images")
+		if err != nil {
+			return fmt.Errorf("failed to load images: %w", err)
+		}
+		for _, image := range images {
+			point, ok := Position(image)
+			if !ok {
+				continue
+			}
+			if err := insert(txApp, image.Id, galleryOf[image.Id], point); err != nil {
+				return err
+			}
+			count++
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+func insert(app core.App, imageID, galleryID string, point types.GeoPoint) error {
+	_, err := app.DB().Insert(Table, dbx.Params{
+		"min_lat": point.Lat,
+		"max_lat": point.Lat,
+		"min_lon": point.Lon,
+		"max_lon": point.Lon,
+		"image":   imageID,
+		"gallery": galleryID,
+	}).Execute()
+	if err != nil {
+		return fmt.Errorf("failed to index the position of image %s: %w", imageID, err)
+	}
+	return nil
+}
+
+func remove(app core.App, imageID string) error {
+	if _, err := app.DB().Delete(Table, dbx.HashExp{"image": imageID}).Execute(); err != nil {
+		return fmt.Errorf("failed to remove image %s from the geo index: %w", imageID, err)
+	}
+	return nil
+}
+
+// detachGallery clears the gallery of its images, which outlive it
+func detachGallery(app core.App, galleryID string) error {
+	_, err := app.DB().Update(Table, dbx.Params{"gallery": ""}, dbx.HashExp{"gallery": galleryID}).Execute()
+	if err != nil {
+		return fmt.Errorf("failed to detach images of gallery %s: %w", galleryID, err)
+	}
+	return nil
+}
+
+func logError(app core.App, err error, record *core.Record) {
+	if err != nil {
+		app.Logger().Warn("Failed to update the geo index", "collection", record.Collection().Name, "id", record.Id, "error", err)
+	}
+}
+
+// earthRadius is the mean radius of the earth in meters
+const earthRadius = 6371008.8
+
+// Distance returns the great-circle distance in meters between two points
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	rad1 := lat1 * math.Pi / 180
+	rad2 := lat2 * math.Pi / 180
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLon := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(rad1)*math.Cos(rad2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Around returns the bounding box containing every point within radius meters
+// of the center, as min and max latitude and longitude. minLon is greater than
+// maxLon when the box crosses the antimeridian.
+func Around(lat, lon, radius float64) (minLat, minLon, maxLat, maxLon float64) {
+	dLat := radius / earthRadius * 180 / math.Pi
+	minLat = math.Max(lat-dLat, -90)
+	maxLat = math.Min(lat+dLat, 90)
+
+	// longitudes are closest together at the latitude farthest from the
+	// equator, and the box spans all of them around the poles
+	cos := math.Cos(math.Max(math.Abs(minLat), math.Abs(maxLat)) * math.Pi / 180)
+	if minLat == -90 || maxLat == 90 || cos < 1e-9 {
+		return minLat, -180, maxLat, 180
+	}
+
+	dLon := dLat / cos
+	if dLon >= 180 {
+		return minLat, -180, maxLat, 180
+	}
+
+	return minLat, wrap(lon - dLon), maxLat, wrap(lon + dLon)
+}
+
+// wrap normalizes a longitude to [-180, 180]
+func wrap(lon float64) float64 {
+	for lon < -180 {
+		lon += 360
+	}
+	for lon > 180 {
+		lon -= 360
+	}
+	return lon
+}
+
+// clusterPixels is the size of a cluster cell on 256 pixel map tiles
+const clusterPixels = 64
+
+// CellSize returns the size in degrees of the grid cells markers are
+// clustered in at the zoom level of a web map
+func CellSize(zoom int) float64 {
+	return 360 / (math.Exp2(float64(zoom)) * 256 / clusterPixels)
+}
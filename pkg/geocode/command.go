@@ -0,0 +1,102 @@
+package geocode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+
+	"github.com/dorianlgs/photo-cifu/pkg/config"
+)
+
+// NewCommand creates the geocode command group
+func NewCommand(app core.App) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "geocode",
+		Short: "Resolve gallery locations and image positions to places",
+	}
+
+	command.AddCommand(lookupCommand())
+	command.AddCommand(backfillCommand(app))
+
+	return command
+}
+
+// lookupCommand shows the place a location or position resolves to
+func lookupCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "lookup <location | lat,lon>",
+		Example:      "geocode lookup \"Brooklyn NY\"",
+		Short:        "Shows the place a location or GPS position resolves to",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			cfg := config.New()
+			g, err := Load(cfg.Geocode.File)
+			if err != nil {
+				return err
+			}
+
+			query := strings.Join(args, " ")
+
+			var place *Place
+			var lat, lon float64
+			if _, err := fmt.Sscanf(query, "%f,%f", &lat, &lon); err == nil {
+				place = g.Reverse(lat, lon, float64(cfg.Geocode.ReverseRadius))
+			} else {
+				place = g.Lookup(query)
+			}
+			if place == nil {
+				return fmt.Errorf("no place found for %q", query)
+			}
+
+			command.Printf("%s (%.5f, %.5f)\n", place.Label(), place.Lat, place.Lon)
+
+			return nil
+		},
+	}
+}
+
+// backfillCommand resolves the places of galleries and images saved before geocoding
+func backfillCommand(app core.App) *cobra.Command {
+	return &cobra.Command{
+		Use:          "backfill",
+		Example:      "geocode backfill",
+		Short:        "Resolves the places of all galleries and geotagged images",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			cfg := config.New()
+			g, err := Load(cfg.Geocode.File)
+			if err != nil {
+				return err
+			}
+
+			galleries, err := app.FindAllRecords("galleries")
+			if err != nil {
+				return fmt.Errorf("failed to load galleries: %w", err)
+			}
+			for _, gallery := range galleries {
+				SetGalleryPlace(g, gallery)
+				if err := app.SaveNoValidate(gallery); err != nil {
+					return fmt.Errorf("failed to save gallery %s: %w", gallery.Id, err)
+				}
+			}
+
+			images, err := app.FindAllRecords("images")
+			if err != nil {
+				return fmt.Errorf("failed to load images: %w", err)
+			}
+			for _, image := range images {
+				SetImagePlace(g, image, float64(cfg.Geocode.ReverseRadius))
+				if err := app.SaveNoValidate(image); err != nil {
+					return fmt.Errorf("failed to save image %s: %w", image.Id, err)
+				}
+			}
+
+			command.Printf("Resolved the places of %d galleries and %d images.\n", len(galleries), len(images))
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,159 @@
+//go:build ignore
+
+// gen builds the embedded places dataset from the cities.json export of the
+// GeoNames cities1000 dump (https://github.com/lutangar/cities.json, CC-BY-4.0).
+// The export has no population, so each place is weighted by the number of
+// places within 20 km. Places in the public domain list of the largest cities
+// per country (https://github.com/tidwall/cities) weigh more, by their rank,
+// and get the name used there as an alternate name.
+//
+//	go run gen.go -in cities.json -major major_cities.txt -out cities.tsv.gz
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type city struct {
+	Name    string `json:"name"`
+	Lat     string `json:"lat"`
+	Lng     string `json:"lng"`
+	Country string `json:"country"`
+	Admin1  string `json:"admin1"`
+}
+
+const weightRadius = 20000 // meters
+
+// majorRadius is the distance within which a major city matches a place
+const majorRadius = 10000 // meters
+
+// majorCity matches the entries of the tidwall/cities source file
+var majorCity = regexp.MustCompile(`\{\d+, "([^"]*)", "([^"]*)", ([-\d.]+), ([-\d.]+),`)
+
+func main() {
+	in := flag.String("in", "cities.json", "the cities.json file")
+	major := flag.String("major", "major_cities.txt", "the cities.go source of the largest cities per country")
+	out := flag.String("out", "cities.tsv.gz", "the dataset to write")
+	flag.Parse()
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var cities []city
+	if err := json.Unmarshal(data, &cities); err != nil {
+		log.Fatal(err)
+	}
+
+	lats := make([]float64, len(cities))
+	lons := make([]float64, len(cities))
+	grid := map[[2]int][]int{}
+	for i, c := range cities {
+		if lats[i], err = strconv.ParseFloat(c.Lat, 64); err != nil {
+			log.Fatalf("%s: %v", c.Name, err)
+		}
+		if lons[i], err = strconv.ParseFloat(c.Lng, 64); err != nil {
+			log.Fatalf("%s: %v", c.Name, err)
+		}
+		grid[cellOf(lats[i], lons[i])] = append(grid[cellOf(lats[i], lons[i])], i)
+	}
+
+	// the largest cities boost the weight of the nearest place, which shares
+	// their name or lies within half the radius
+	boost := make([]int, len(cities))
+	alternate := make([]string, len(cities))
+	source, err := os.ReadFile(*major)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ranks := map[string]int{}
+	for _, m := range majorCity.FindAllStringSubmatch(string(source), -1) {
+		country, name := m[1], m[2]
+		lat, _ := strconv.ParseFloat(m[3], 64)
+		lon, _ := strconv.ParseFloat(m[4], 64)
+		rank := ranks[country]
+		ranks[country]++
+
+		match, matchDistance, named := -1, float64(majorRadius), false
+		cell := cellOf(lat, lon)
+		for dr := -1; dr <= 1; dr++ {
+			for dc := -1; dc <= 1; dc++ {
+				for _, j := range grid[[2]int{cell[0] + dr, cell[1] + dc}] {
+					d := distance(lat, lon, lats[j], lons[j])
+					sameName := strings.EqualFold(cities[j].Name, name)
+					if d > majorRadius || (!sameName && d > majorRadius/2) || (named && !sameName) {
+						continue
+					}
+					if (sameName && !named) || d < matchDistance {
+						match, matchDistance, named = j, d, sameName
+					}
+				}
+			}
+		}
+		if match < 0 {
+			continue
+		}
+
+		boost[match] = max(boost[match], 1000-5*rank)
+		if !named {
+			alternate[match] = name
+		}
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	w, err := gzip.NewWriterLevel(file, gzip.BestCompression)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Fprintln(w, "# name\tlat\tlon\tcountry\tadmin1\tweight\talternate names")
+	for i, c := range cities {
+		cell := cellOf(lats[i], lons[i])
+		weight := 0
+		for dr := -1; dr <= 1; dr++ {
+			for dc := -1; dc <= 1; dc++ {
+				for _, j := range grid[[2]int{cell[0] + dr, cell[1] + dc}] {
+					if distance(lats[i], lons[i], lats[j], lons[j]) <= weightRadius {
+						weight++
+					}
+				}
+			}
+		}
+		fmt.Fprintf(w, "%s\t%.5f\t%.5f\t%s\t%s\t%d\t%s\n", c.Name, lats[i], lons[i], c.Country, c.Admin1, weight+boost[i], alternate[i])
+	}
+
+	if err := w.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// cellOf returns the half degree grid cell of a point, wider than the weight
+// radius up to high latitudes
+func cellOf(lat, lon float64) [2]int {
+	return [2]int{int(math.Floor(lat * 2)), int(math.Floor(lon * 2))}
+}
+
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	rad1 := lat1 * math.Pi / 180
+	rad2 := lat2 * math.Pi / 180
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLon := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(rad1)*math.Cos(rad2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * 6371008.8 * math.Asin(math.Min(1, math.Sqrt(a)))
+}
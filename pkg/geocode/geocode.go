@@ -0,0 +1,361 @@
+// Package geocode resolves place names and GPS positions against an offline
+// GeoNames cities dataset, without any network calls.
+package geocode
+
+//go:generate go run gen.go -in cities.json -major major_cities.txt -out cities.tsv.gz
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/dorianlgs/photo-cifu/pkg/geo"
+)
+
+// cities is the embedded dataset, generated from the GeoNames cities1000 dump
+//
+//go:embed cities.tsv.gz
+var cities []byte
+
+// maxPrefixMatches bounds the names scanned when a query only matches name prefixes
+const maxPrefixMatches = 1000
+
+// Place is a populated place of the dataset
+type Place struct {
+	Name string `json:"name"`
+	// Admin1 is the GeoNames first level division code, the state for the US
+	Admin1 string `json:"admin1,omitempty"`
+	// Country is the ISO 3166-1 alpha-2 code
+	Country string  `json:"country"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+
+	// weight ranks places sharing a name, the population when the dataset has it
+	weight int
+}
+
+// CountryName returns the English name of the place's country
+func (p *Place) CountryName() string {
+	if name, ok := countryNames[p.Country]; ok {
+		return name
+	}
+	return p.Country
+}
+
+// Label returns the canonical name of the place with its country, and its
+// state for US places, e.g. "Brooklyn, NY, United States"
+func (p *Place) Label() string {
+	parts := []string{p.Name}
+	if p.Country == "US" && p.Admin1 != "" {
+		parts = append(parts, p.Admin1)
+	}
+	return strings.Join(append(parts, p.CountryName()), ", ")
+}
+
+// Geocoder looks up places by name and position
+type Geocoder struct {
+	// names maps the folded names to their places
+	names map[string][]*Place
+	// sorted are the folded names in order, for prefix matches
+	sorted []string
+	// grid holds the places in one degree cells
+	grid map[[2]int][]*Place
+}
+
+var (
+	loadedMu sync.Mutex
+	loaded   = map[string]*Geocoder{}
+)
+
+// Load returns the geocoder of the GeoNames file at path, or of the embedded
+// dataset when path is empty. Geocoders are loaded once and shared.
+func Load(path string) (*Geocoder, error) {
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+
+	if g, ok := loaded[path]; ok {
+		return g, nil
+	}
+
+	var g *Geocoder
+	var err error
+	if path == "" {
+		g, err = load(bytes.NewReader(cities))
+	} else {
+		g, err = LoadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	loaded[path] = g
+	return g, nil
+}
+
+// LoadFile loads a GeoNames dump such as cities15000.txt, optionally gzipped.
+// GeoNames dumps also provide the population and alternate names of places.
+func LoadFile(path string) (*Geocoder, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the GeoNames file: %w", err)
+	}
+	defer file.Close()
+
+	return load(file)
+}
+
+// load reads a dataset in the embedded or the GeoNames dump format
+func load(r io.Reader) (*Geocoder, error) {
+	reader := bufio.NewReader(r)
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the places dataset: %w", err)
+		}
+		defer gz.Close()
+		reader = bufio.NewReader(gz)
+	}
+
+	g := &Geocoder{
+		names: map[string][]*Place{},
+		grid:  map[[2]int][]*Place{},
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		place, names, err := parseLine(strings.Split(text, "\t"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid place on line %d: %w", line, err)
+		}
+		g.add(place, names)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the places dataset: %w", err)
+	}
+
+	g.sorted = make([]string, 0, len(g.names))
+	for name := range g.names {
+		g.sorted = append(g.sorted, name)
+	}
+	sort.Strings(g.sorted)
+
+	return g, nil
+}
+
+// parseLine parses a row of the embedded dataset (name, lat, lon, country,
+// admin1, weight, alternate names) or of a GeoNames dump, returning the place
+// and its names
+func parseLine(fields []string) (*Place, []string, error) {
+	var name, lat, lon, country, admin1, weight string
+	var names []string
+
+	switch {
+	case len(fields) >= 15:
+		// GeoNames: geonameid, name, asciiname, alternatenames, latitude, longitude,
+		// feature class, feature code, country code, cc2, admin1 code, admin2 code,
+		// admin3 code, admin4 code, population, ...
+		name, lat, lon, country, admin1, weight = fields[1], fields[4], fields[5], fields[8], fields[10], fields[14]
+		names = append(names, fields[2])
+		if fields[3] != "" {
+			names = append(names, strings.Split(fields[3], ",")...)
+		}
+	case len(fields) == 7:
+		name, lat, lon, country, admin1, weight = fields[0], fields[1], fields[2], fields[3], fields[4], fields[5]
+		if fields[6] != "" {
+			names = append(names, strings.Split(fields[6], ",")...)
+		}
+	default:
+		return nil, nil, fmt.Errorf("expected 7 or at least 15 columns, got %d", len(fields))
+	}
+
+	place := &Place{Name: name, Admin1: admin1, Country: country}
+
+	var err error
+	if place.Lat, err = strconv.ParseFloat(lat, 64); err != nil {
+		return nil, nil, err
+	}
+	if place.Lon, err = strconv.ParseFloat(lon, 64); err != nil {
+		return nil, nil, err
+	}
+	if weight != "" {
+		if place.weight, err = strconv.Atoi(weight); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return place, append([]string{name}, names...), nil
+}
+
+func (g *Geocoder) add(place *Place, names []string) {
+	seen := map[string]bool{}
+	for _, name := range names {
+		key := fold(name)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		g.names[key] = append(g.names[key], place)
+	}
+
+	cell := cellOf(place.Lat, place.Lon)
+	g.grid[cell] = append(g.grid[cell], place)
+}
+
+// Lookup resolves free text like "nyc", "Brooklyn NY" or "Paris, France" to
+// the most prominent matching place, or nil if nothing matches
+func (g *Geocoder) Lookup(query string) *Place {
+	text := fold(query)
+	if alias, ok := placeAliases[text]; ok {
+		text = alias
+	}
+
+	// comma separated parts are a name followed by its qualifiers
+	var parts []string
+	for _, part := range strings.Split(text, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	if len(parts) > 1 {
+		if place := g.best(g.names[parts[0]], parts[1:]); place != nil {
+			return place
+		}
+		return g.prefix(parts[0], parts[1:])
+	}
+
+	// without commas the trailing words may be the qualifier, as in "Brooklyn NY"
+	words := strings.Fields(parts[0])
+	for n := len(words); n > 0; n-- {
+		var qualifiers []string
+		if n < len(words) {
+			qualifiers = []string{strings.Join(words[n:], " ")}
+		}
+		if place := g.best(g.names[strings.Join(words[:n], " ")], qualifiers); place != nil {
+			return place
+		}
+	}
+
+	return g.prefix(parts[0], nil)
+}
+
+// Reverse returns the place nearest to the position within radius meters, or nil
+func (g *Geocoder) Reverse(lat, lon, radius float64) *Place {
+	// longitudes are closest together at the latitude farthest from the equator
+	minLat, maxLat := math.Max(lat-radius/111000, -90), math.Min(lat+radius/111000, 90)
+	cos := math.Cos(math.Max(math.Abs(minLat), math.Abs(maxLat)) * math.Pi / 180)
+	lonCells := 180
+	if cos > 1e-9 {
+		lonCells = min(int(math.Ceil(radius/(111000*cos))), 180)
+	}
+
+	var nearest *Place
+	nearestDistance := radius
+	center := cellOf(lat, lon)
+	for row := cellOf(minLat, lon)[0]; row <= cellOf(maxLat, lon)[0]; row++ {
+		for dc := -lonCells; dc <= lonCells; dc++ {
+			col := ((center[1]+dc+180)%360+360)%360 - 180
+			for _, place := range g.grid[[2]int{row, col}] {
+				if distance := geo.Distance(lat, lon, place.Lat, place.Lon); distance <= nearestDistance {
+					nearest, nearestDistance = place, distance
+				}
+			}
+		}
+	}
+
+	return nearest
+}
+
+// best returns the heaviest place matching all qualifiers
+func (g *Geocoder) best(places []*Place, qualifiers []string) *Place {
+	var best *Place
+	for _, place := range places {
+		if !matches(place, qualifiers) {
+			continue
+		}
+		if best == nil || place.weight > best.weight {
+			best = place
+		}
+	}
+	return best
+}
+
+// prefix returns the heaviest place with a name starting with the whole words
+// of the query, so "New York" finds "New York City"
+func (g *Geocoder) prefix(name string, qualifiers []string) *Place {
+	prefix := name + " "
+	var best *Place
+	start := sort.SearchStrings(g.sorted, prefix)
+	for i := start; i < len(g.sorted) && i-start < maxPrefixMatches && strings.HasPrefix(g.sorted[i], prefix); i++ {
+		if place := g.best(g.names[g.sorted[i]], qualifiers); place != nil && (best == nil || place.weight > best.weight) {
+			best = place
+		}
+	}
+	return best
+}
+
+// matches reports whether the qualifiers name the place's country or state
+func matches(place *Place, qualifiers []string) bool {
+	for _, qualifier := range qualifiers {
+		switch {
+		case strings.EqualFold(qualifier, place.Country):
+		case strings.EqualFold(qualifier, place.Admin1):
+		case countryCodes[qualifier] == place.Country:
+		case place.Country == "US" && usStates[qualifier] == place.Admin1:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// fold lowercases the text, strips diacritics and replaces punctuation other
+// than commas with spaces, so names compare the way people type them
+func fold(text string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), text)
+	if err != nil {
+		folded = text
+	}
+
+	folded = strings.Map(func(r rune) rune {
+		switch {
+		case r == ',':
+			return r
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			return unicode.ToLower(r)
+		default:
+			return ' '
+		}
+	}, folded)
+
+	return strings.Join(strings.Fields(folded), " ")
+}
+
+// cellOf returns the one degree grid cell of a position
+func cellOf(lat, lon float64) [2]int {
+	return [2]int{int(math.Floor(lat)), int(math.Floor(lon))}
+}
@@ -0,0 +1,92 @@
+package geocode
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/dorianlgs/photo-cifu/pkg/config"
+	"github.com/dorianlgs/photo-cifu/pkg/geo"
+)
+
+// Register resolves the location of galleries and the GPS position of images
+// to places whenever they change
+func Register(app core.App) {
+	cfg := config.New()
+
+	geocoder := func(app core.App) *Geocoder {
+		g, err := Load(cfg.Geocode.File)
+		if err != nil {
+			app.Logger().Warn("Failed to load the places dataset", "file", cfg.Geocode.File, "error", err)
+		}
+		return g
+	}
+
+	app.OnRecordCreate("galleries").BindFunc(func(e *core.RecordEvent) error {
+		if g := geocoder(e.App); g != nil {
+			SetGalleryPlace(g, e.Record)
+		}
+		return e.Next()
+	})
+	app.OnRecordUpdate("galleries").BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetString("location") != e.Record.Original().GetString("location") {
+			if g := geocoder(e.App); g != nil {
+				SetGalleryPlace(g, e.Record)
+			}
+		}
+		return e.Next()
+	})
+
+	app.OnRecordCreate("images").BindFunc(func(e *core.RecordEvent) error {
+		if g := geocoder(e.App); g != nil {
+			SetImagePlace(g, e.Record, float64(cfg.Geocode.ReverseRadius))
+		}
+		return e.Next()
+	})
+	app.OnRecordUpdate("images").BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetString("gps") != e.Record.Original().GetString("gps") {
+			if g := geocoder(e.App); g != nil {
+				SetImagePlace(g, e.Record, float64(cfg.Geocode.ReverseRadius))
+			}
+		}
+		return e.Next()
+	})
+
+	// load the dataset in the background so the first upload doesn't wait for it
+	app.OnServe().BindFunc(func(e *core.ServeEvent) error {
+		go geocoder(e.App)
+		return e.Next()
+	})
+}
+
+// SetGalleryPlace sets the canonical place, country and coordinates of a
+// gallery from its free text location, clearing them when nothing matches
+func SetGalleryPlace(g *Geocoder, gallery *core.Record) {
+	place := g.Lookup(gallery.GetString("location"))
+	if place == nil {
+		gallery.Set("place", "")
+		gallery.Set("country", "")
+		gallery.Set("coordinates", types.GeoPoint{})
+		return
+	}
+
+	gallery.Set("place", place.Label())
+	gallery.Set("country", place.Country)
+	gallery.Set("coordinates", types.GeoPoint{Lon: place.Lon, Lat: place.Lat})
+}
+
+// SetImagePlace sets the place and country of an image from the nearest
+// place to its GPS position within radius meters
+func SetImagePlace(g *Geocoder, image *core.Record, radius float64) {
+	var place *Place
+	if point, ok := geo.Position(image); ok {
+		place = g.Reverse(point.Lat, point.Lon, radius)
+	}
+	if place == nil {
+		image.Set("place", "")
+		image.Set("country", "")
+		return
+	}
+
+	image.Set("place", place.Label())
+	image.Set("country", place.Country)
+}
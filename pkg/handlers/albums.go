@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// CreateAlbum creates a smart album owned by the authenticated user
+func (h *Handlers) CreateAlbum(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	req := &validation.SmartAlbumRequest{}
+	if err := e.BindBody(req); err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	if err := req.Validate(true); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	album, err := h.container.Services.Album.Create(info, req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, album)
+}
+
+// UpdateAlbum changes the name, filters or sort of a smart album
+func (h *Handlers) UpdateAlbum(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	req := &validation.SmartAlbumRequest{}
+	if err := e.BindBody(req); err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	if err := req.Validate(false); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	album, err := h.container.Services.Album.Update(info, e.Request.PathValue("id"), req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, album)
+}
+
+// AlbumImages returns a page of the images currently matching a smart album
+func (h *Handlers) AlbumImages(e *core.RequestEvent) error {
+	client, err := h.galleryClient(e)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	req := imagePageRequest(e)
+	if err := req.Validate(validation.AlbumSorts); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	page, err := h.container.Services.Album.Images(client, e.Request.PathValue("id"), req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, page)
+}
+
+// CreateAlbumShare creates a share link for a smart album
+func (h *Handlers) CreateAlbumShare(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	req := &validation.ShareCreateRequest{}
+	if err := e.BindBody(req); err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	link, err := h.container.Services.Share.CreateAlbumShare(info, e.Request.PathValue("id"), req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, link)
+}
+
+// imagePageRequest reads the sort and paging query parameters of an image page
+func imagePageRequest(e *core.RequestEvent) *validation.ImagePageRequest {
+	query := e.Request.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	perPage, _ := strconv.Atoi(query.Get("perPage"))
+
+	return &validation.ImagePageRequest{
+		Sort:    query.Get("sort"),
+		Page:    page,
+		PerPage: perPage,
+	}
+}
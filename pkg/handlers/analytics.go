@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// GalleryAnalytics returns the daily views, image opens and downloads of a gallery
+func (h *Handlers) GalleryAnalytics(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	query := e.Request.URL.Query()
+	req := &validation.AnalyticsRequest{
+		From: query.Get("from"),
+		To:   query.Get("to"),
+	}
+
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	analytics, err := h.container.Services.Analytics.Gallery(info, e.Request.PathValue("id"), req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, analytics)
+}
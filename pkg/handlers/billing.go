@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// maxWebhookSize caps the size of Stripe webhook payloads
+const maxWebhookSize = 1 << 20
+
+// Billing returns the plan and subscription status of the user
+func (h *Handlers) Billing(e *core.RequestEvent) error {
+	billing, err := h.container.Services.Billing.Billing(e.Auth)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, billing)
+}
+
+// BillingCheckout creates a Stripe checkout session for a plan
+func (h *Handlers) BillingCheckout(e *core.RequestEvent) error {
+	req := &validation.CheckoutRequest{}
+	if err := e.BindBody(req); err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	checkout, err := h.container.Services.Billing.Checkout(e.Auth, requestBaseURL(e), req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, checkout)
+}
+
+// StripeWebhook applies the checkout and subscription events sent by Stripe.
+// The signature covers the raw payload, so the body is not bound.
+func (h *Handlers) StripeWebhook(e *core.RequestEvent) error {
+	payload, err := io.ReadAll(http.MaxBytesReader(e.Response, e.Request.Body, maxWebhookSize))
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to read webhook payload", err))
+	}
+
+	if err := h.container.Services.Billing.HandleWebhook(payload, e.Request.Header.Get("Stripe-Signature")); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, map[string]bool{"received": true})
+}
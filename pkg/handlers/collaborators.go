@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// InviteCollaborator invites a user by email to collaborate on a gallery
+func (h *Handlers) InviteCollaborator(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	req := &validation.CollaboratorInviteRequest{
+		Email: getStringFromBody(info.Body, "email"),
+		Role:  getStringFromBody(info.Body, "role"),
+	}
+
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	collaborator, err := h.container.Services.Collaborator.Invite(info, e.Request.PathValue("id"), req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, map[string]any{
+		"collaborator_id": collaborator.Id,
+		"accepted":        collaborator.GetString("user") != "",
+		"message":         "Collaborator invited successfully",
+	})
+}
+
+// AcceptInvitation links a pending collaborator invitation to the authenticated user
+func (h *Handlers) AcceptInvitation(e *core.RequestEvent) error {
+	if err := h.container.Services.Collaborator.Accept(e.Auth, e.Request.PathValue("id")); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, map[string]string{
+		"message": "Invitation accepted successfully",
+	})
+}
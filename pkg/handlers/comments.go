@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// ListComments returns the comment threads of an image visible to the client
+func (h *Handlers) ListComments(e *core.RequestEvent) error {
+	client, err := h.galleryClient(e)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	comments, err := h.container.Services.Comment.List(client, e.Request.PathValue("id"))
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, map[string]any{
+		"items": comments,
+	})
+}
+
+// PostComment adds a comment or a reply to an image
+func (h *Handlers) PostComment(e *core.RequestEvent) error {
+	client, err := h.galleryClient(e)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	req := &validation.CommentRequest{}
+	if err := e.BindBody(req); err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	comment, err := h.container.Services.Comment.Post(client, e.Request.PathValue("id"), req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, comment)
+}
+
+// EditComment changes the text of the client's own comment
+func (h *Handlers) EditComment(e *core.RequestEvent) error {
+	client, err := h.galleryClient(e)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	req := &validation.CommentRequest{}
+	if err := e.BindBody(req); err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	comment, err := h.container.Services.Comment.Edit(client, e.Request.PathValue("id"), req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, comment)
+}
+
+// DeleteComment removes a comment and its replies
+func (h *Handlers) DeleteComment(e *core.RequestEvent) error {
+	client, err := h.galleryClient(e)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	if err := h.container.Services.Comment.Delete(client, e.Request.PathValue("id")); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, map[string]string{
+		"message": "Comment deleted successfully",
+	})
+}
+
+// HideComment hides a comment from everyone but the gallery owner
+func (h *Handlers) HideComment(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	comment, err := h.container.Services.Comment.Hide(info, e.Request.PathValue("id"))
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, comment)
+}
+
+// ApproveComment publishes a pending or hidden comment
+func (h *Handlers) ApproveComment(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	comment, err := h.container.Services.Comment.Approve(info, e.Request.PathValue("id"))
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, comment)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+	"golang.org/x/net/webdav"
+
+	"github.com/dorianlgs/photo-cifu/pkg/dav"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+)
+
+// davPrefix is the mount point of the WebDAV file system
+const davPrefix = "/dav"
+
+// davMethods lists the HTTP methods handled by the WebDAV file system
+var davMethods = []string{
+	http.MethodGet, http.MethodPut, http.MethodDelete, http.MethodOptions,
+	"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK",
+}
+
+// WebDAV serves galleries as a network drive
+func (h *Handlers) WebDAV(e *core.RequestEvent) error {
+	// the request info is built by hand since e.RequestInfo() would try to parse the uploaded file as a body
+	info := &core.RequestInfo{
+		Context: core.RequestInfoContextDefault,
+		Method:  e.Request.Method,
+		Auth:    e.Auth,
+	}
+	if info.Auth == nil {
+		auth, err := dav.Authenticate(h.container.App, h.davFailures, e.Request, e.RealIP())
+		if err != nil {
+			return errors.HandleError(e, err)
+		}
+		info.Auth = auth
+	}
+	if info.Auth == nil {
+		e.Response.Header().Set("WWW-Authenticate", `Basic realm="PhotoCifu"`)
+		return errors.HandleError(e, errors.Unauthorized("Authentication required"))
+	}
+
+	handler := &webdav.Handler{
+		Prefix:     davPrefix,
+		FileSystem: dav.NewFileSystem(h.container.App, h.container.Services.Gallery, info, h.container.Config.Gallery.MaxFileSize),
+		LockSystem: h.davLocks,
+		Logger: func(r *http.Request, err error) {
+			if err != nil {
+				h.container.App.Logger().Debug("WebDAV request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+			}
+		},
+	}
+	handler.ServeHTTP(e.Response, e.Request)
+
+	return nil
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+
+	"github.com/dorianlgs/photo-cifu/pkg/container"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// OEmbed returns the oEmbed description of a public or share linked gallery URL
+func (h *Handlers) OEmbed(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+	maxWidth, _ := strconv.Atoi(query.Get("maxwidth"))
+	maxHeight, _ := strconv.Atoi(query.Get("maxheight"))
+
+	req := &validation.OEmbedRequest{
+		URL:       query.Get("url"),
+		MaxWidth:  maxWidth,
+		MaxHeight: maxHeight,
+		Format:    query.Get("format"),
+	}
+
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	oembed, err := h.container.Services.Embed.OEmbed(requestBaseURL(e), e.RealIP(), req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, oembed)
+}
+
+// EmbedGallery serves the self-contained embed page of a public or share linked gallery
+func (h *Handlers) EmbedGallery(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+	req := &validation.EmbedRequest{
+		Share:  query.Get("share"),
+		Layout: query.Get("layout"),
+	}
+
+	nonce := security.RandomString(24)
+	header := e.Response.Header()
+	header.Set("Content-Security-Policy",
+		"default-src 'none'; img-src 'self'; style-src 'nonce-"+nonce+"'; script-src 'nonce-"+nonce+"'; "+
+			"base-uri 'none'; form-action 'none'; frame-ancestors "+h.container.Config.Embed.FrameAncestors)
+	// share tokens are part of the page URL, so it is neither cached nor sent as referrer
+	header.Set("Referrer-Policy", "no-referrer")
+	header.Set("Cache-Control", "private, no-store")
+	header.Set("X-Content-Type-Options", "nosniff")
+
+	if err := req.Validate(); err != nil {
+		return renderEmbed(e, embedPage{Nonce: nonce, Error: err})
+	}
+
+	gallery, err := h.container.Services.Embed.Gallery(e.Request.PathValue("id"), req.Share, e.RealIP())
+	if err != nil {
+		return renderEmbed(e, embedPage{Nonce: nonce, Error: err})
+	}
+
+	h.container.Services.Analytics.Record(container.EventGalleryView, gallery.ID, "")
+
+	// oEmbed discovery for consumers given the embed page URL itself
+	pageURL := requestBaseURL(e) + e.Request.URL.RequestURI()
+
+	return renderEmbed(e, embedPage{
+		Nonce:     nonce,
+		Gallery:   gallery,
+		Slideshow: req.Layout == validation.EmbedLayoutSlideshow,
+		OEmbedURL: requestBaseURL(e) + "/api/photocifu/oembed?url=" + url.QueryEscape(pageURL),
+	})
+}
+
+// embedPage is the data of the embed page template
+type embedPage struct {
+	Nonce     string
+	Gallery   *container.EmbedGallery
+	Slideshow bool
+	OEmbedURL string
+	Error     error
+}
+
+// renderEmbed writes the embed page, errors are shown in the page with their status
+func renderEmbed(e *core.RequestEvent, page embedPage) error {
+	status := http.StatusOK
+	if page.Error != nil {
+		status = http.StatusInternalServerError
+		if appErr, ok := page.Error.(*errors.AppError); ok {
+			status = appErr.Status
+		}
+		if status >= http.StatusInternalServerError {
+			e.App.Logger().Error("Failed to render embed page", "error", page.Error)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := embedTemplate.Execute(&buf, page); err != nil {
+		return errors.HandleError(e, errors.InternalError("Failed to render embed page", err))
+	}
+
+	return e.HTML(status, buf.String())
+}
+
+// errorMessage returns the message of an AppError, hiding internal errors
+func errorMessage(err error) string {
+	if appErr, ok := err.(*errors.AppError); ok && appErr.Status < http.StatusInternalServerError {
+		return appErr.Message
+	}
+	return "Something went wrong"
+}
+
+var embedTemplate = template.Must(template.New("embed").Funcs(template.FuncMap{
+	"errorMessage": errorMessage,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{if .Gallery}}{{.Gallery.Name}}{{else}}Gallery unavailable{{end}}</title>
+{{if .OEmbedURL}}<link rel="alternate" type="application/json+oembed" href="{{.OEmbedURL}}" title="{{.Gallery.Name}}">{{end}}
+<style nonce="{{.Nonce}}">
+*{box-sizing:border-box}
+html,body{margin:0;height:100%;background:#111;color:#eee;font:14px/1.4 system-ui,sans-serif}
+header{padding:8px 12px;white-space:nowrap;overflow:hidden;text-overflow:ellipsis}
+header small{color:#999;margin-left:8px}
+.grid{display:grid;grid-template-columns:repeat(auto-fill,minmax(160px,1fr));gap:4px;padding:4px}
+.grid button{padding:0;border:0;background:#222;aspect-ratio:1;cursor:zoom-in}
+.grid img{width:100%;height:100%;object-fit:cover;display:block}
+.viewer{position:fixed;inset:0;background:#000;display:flex;flex-direction:column}
+.viewer[hidden]{display:none}
+.viewer.inline{position:static;height:calc(100% - 37px)}
+.stage{flex:1;display:flex;align-items:center;justify-content:center;min-height:0}
+.stage img{max-width:100%;max-height:100%;object-fit:contain}
+.bar{display:flex;align-items:center;gap:8px;padding:6px 12px}
+.bar p{flex:1;margin:0;overflow:hidden;text-overflow:ellipsis;white-space:nowrap}
+.bar button{background:#333;color:#eee;border:0;border-radius:4px;padding:4px 10px;cursor:pointer}
+.message{display:flex;align-items:center;justify-content:center;height:100%;padding:24px;text-align:center;color:#aaa}
+</style>
+</head>
+<body>
+{{if .Error}}
+<div class="message">{{errorMessage .Error}}</div>
+{{else if not .Gallery.Images}}
+<div class="message">This gallery has no images yet</div>
+{{else}}
+<header>{{.Gallery.Name}}{{if .Gallery.Location}}<small>{{.Gallery.Location}}</small>{{end}}</header>
+{{if not .Slideshow}}
+<div class="grid">
+{{range $i, $image := .Gallery.Images}}<button type="button" data-index="{{$i}}"><img src="{{$image.URL}}" alt="{{$image.AltText}}" loading="lazy"></button>
+{{end}}</div>
+{{end}}
+<div id="viewer" class="viewer{{if .Slideshow}} inline{{end}}"{{if not .Slideshow}} hidden{{end}}>
+<div class="stage"><img id="photo" alt=""></div>
+<div class="bar">
+<button type="button" id="prev" aria-label="Previous">&lsaquo;</button>
+<span id="counter"></span>
+<button type="button" id="next" aria-label="Next">&rsaquo;</button>
+<p id="caption"></p>
+{{if not .Slideshow}}<button type="button" id="close" aria-label="Close">&times;</button>{{end}}
+</div>
+</div>
+<script nonce="{{.Nonce}}">
+(function () {
+  var images = {{.Gallery.Images}};
+  var viewer = document.getElementById("viewer");
+  var photo = document.getElementById("photo");
+  var current = 0;
+
+  function show(index) {
+    current = (index + images.length) % images.length;
+    photo.src = images[current].OpenURL;
+    photo.alt = images[current].AltText;
+    document.getElementById("caption").textContent = images[current].Caption;
+    document.getElementById("counter").textContent = (current + 1) + " / " + images.length;
+    viewer.hidden = false;
+  }
+
+  function close() {
+    if (document.getElementById("close")) {
+      viewer.hidden = true;
+    }
+  }
+
+  document.querySelectorAll("[data-index]").forEach(function (button) {
+    button.addEventListener("click", function () { show(Number(button.dataset.index)); });
+  });
+  document.getElementById("prev").addEventListener("click", function () { show(current - 1); });
+  document.getElementById("next").addEventListener("click", function () { show(current + 1); });
+  if (document.getElementById("close")) {
+    document.getElementById("close").addEventListener("click", close);
+  }
+  document.addEventListener("keydown", function (event) {
+    if (viewer.hidden) return;
+    if (event.key === "ArrowLeft") show(current - 1);
+    if (event.key === "ArrowRight") show(current + 1);
+    if (event.key === "Escape") close();
+  });
+
+  if ({{.Slideshow}}) {
+    show(0);
+  }
+})();
+</script>
+{{end}}
+</body>
+</html>
+`))
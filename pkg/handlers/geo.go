@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// GeoWithin returns the image or gallery markers inside a bounding box, clustered for the zoom level
+func (h *Handlers) GeoWithin(e *core.RequestEvent) error {
+	client, err := h.galleryClient(e)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	query := e.Request.URL.Query()
+	zoom, _ := strconv.Atoi(query.Get("zoom"))
+
+	req := &validation.GeoBoundsRequest{
+		Kind: e.Request.PathValue("kind"),
+		BBox: query.Get("bbox"),
+		Zoom: zoom,
+	}
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	clusters, err := h.container.Services.Geo.Within(client, req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, clusters)
+}
+
+// GeoNear returns the images or galleries within a radius of a point, nearest first
+func (h *Handlers) GeoNear(e *core.RequestEvent) error {
+	client, err := h.galleryClient(e)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	query := e.Request.URL.Query()
+	radius, _ := strconv.ParseFloat(query.Get("radius"), 64)
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	req := &validation.GeoNearRequest{
+		Kind:   e.Request.PathValue("kind"),
+		Near:   query.Get("near"),
+		Radius: radius,
+		Limit:  limit,
+	}
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	items, err := h.container.Services.Geo.Near(client, req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, map[string]any{"items": items})
+}
@@ -2,22 +2,33 @@ package handlers
 
 import (
 	"io"
+	"mime/multipart"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/dorianlgs/photo-cifu/pkg/container"
+	"github.com/dorianlgs/photo-cifu/pkg/dav"
 	"github.com/dorianlgs/photo-cifu/pkg/errors"
 	"github.com/dorianlgs/photo-cifu/pkg/validation"
 	"github.com/pocketbase/pocketbase/core"
+	"golang.org/x/net/webdav"
 )
 
 // Handlers contains all HTTP handlers
 type Handlers struct {
-	container *container.Container
+	container   *container.Container
+	davLocks    webdav.LockSystem
+	davFailures *container.AttemptLimiter
 }
 
 // New creates a new handlers instance
-func New(container *container.Container) *Handlers {
-	return &Handlers{container: container}
+func New(c *container.Container) *Handlers {
+	return &Handlers{
+		container:   c,
+		davLocks:    webdav.NewMemLS(),
+		davFailures: container.NewAttemptLimiter(dav.MaxAuthFailures, dav.AuthLockout),
+	}
 }
 
 // CreateGallery handles gallery creation requests
@@ -62,6 +73,7 @@ func (h *Handlers) CreateGallery(e *core.RequestEvent) error {
 
 	// Create gallery using service
 	galleryID, err := h.container.Services.Gallery.CreateGallery(
+		e.Auth.Id,
 		req.Name,
 		req.Location,
 		imagesData,
@@ -79,6 +91,115 @@ func (h *Handlers) CreateGallery(e *core.RequestEvent) error {
 	})
 }
 
+// UpdateGallery handles partial gallery updates, as JSON or as multipart form data with a new thumbnail
+func (h *Handlers) UpdateGallery(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	req := &validation.GalleryUpdateRequest{}
+	if strings.HasPrefix(e.Request.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := e.Request.ParseMultipartForm(maxMultipartMemory); err != nil {
+			return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+		}
+		if req, err = galleryUpdateForm(e.Request.MultipartForm); err != nil {
+			return errors.HandleError(e, err)
+		}
+	} else if err := e.BindBody(req); err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	// Get the optional thumbnail file
+	var thumbnailData []byte
+	if e.Request.MultipartForm != nil && len(e.Request.MultipartForm.File["thumbnail"]) > 0 {
+		thumbnailHeader := e.Request.MultipartForm.File["thumbnail"][0]
+		req.Thumbnail = thumbnailHeader
+
+		thumbnailFile, err := thumbnailHeader.Open()
+		if err != nil {
+			return errors.HandleError(e, errors.BadRequest("Failed to read thumbnail file", err))
+		}
+		defer thumbnailFile.Close()
+
+		thumbnailData, err = io.ReadAll(thumbnailFile)
+		if err != nil {
+			return errors.HandleError(e, errors.InternalError("Failed to read thumbnail file", err))
+		}
+	}
+
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	gallery, err := h.container.Services.Gallery.UpdateGallery(info, e.Request.PathValue("id"), req, thumbnailData)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, gallery)
+}
+
+// DeleteGallery moves a gallery and its images to the trash
+func (h *Handlers) DeleteGallery(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	if err := h.container.Services.Gallery.DeleteGallery(info, e.Request.PathValue("id")); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, map[string]string{
+		"message": "Gallery moved to trash",
+	})
+}
+
+// galleryUpdateForm reads a gallery update from multipart form data, leaving
+// the fields that are not in the form unset
+func galleryUpdateForm(form *multipart.Form) (*validation.GalleryUpdateRequest, error) {
+	req := &validation.GalleryUpdateRequest{}
+	value := func(key string) *string {
+		if values, ok := form.Value[key]; ok && len(values) > 0 {
+			return &values[0]
+		}
+		return nil
+	}
+
+	req.Name = value("name")
+	req.Location = value("location")
+	req.Description = value("description")
+	req.Images = form.Value["images"]
+
+	if maxPicks := value("max_picks"); maxPicks != nil {
+		n, err := strconv.Atoi(*maxPicks)
+		if err != nil {
+			return nil, errors.ValidationError("Max picks must be a number", nil)
+		}
+		req.MaxPicks = &n
+	}
+
+	if moderation := value("comment_moderation"); moderation != nil {
+		b, err := strconv.ParseBool(*moderation)
+		if err != nil {
+			return nil, errors.ValidationError("Comment moderation must be true or false", nil)
+		}
+		req.CommentModeration = &b
+	}
+
+	if public := value("public"); public != nil {
+		b, err := strconv.ParseBool(*public)
+		if err != nil {
+			return nil, errors.ValidationError("Public must be true or false", nil)
+		}
+		req.Public = &b
+	}
+
+	return req, nil
+}
+
 // CreateWorkflow handles workflow creation requests
 func (h *Handlers) CreateWorkflow(e *core.RequestEvent) error {
 	// Parse request
@@ -97,8 +218,13 @@ func (h *Handlers) CreateWorkflow(e *core.RequestEvent) error {
 		return errors.HandleError(e, err)
 	}
 
+	// The cleanup and the trash purge delete data across all galleries
+	if (req.WorkflowType == "cleanup" || req.WorkflowType == "trash_purge") && !e.HasSuperuserAuth() {
+		return errors.HandleError(e, errors.Forbidden("Only superusers can start the "+req.WorkflowType+" workflow"))
+	}
+
 	// Create workflow using service
-	instanceID, err := h.container.Services.Workflow.CreateWorkflow(req.WorkflowType, req.Input)
+	instanceID, err := h.container.Services.Workflow.CreateWorkflow(e.Auth, req.WorkflowType, req.Input)
 	if err != nil {
 		return errors.HandleError(e, err)
 	}
@@ -109,6 +235,41 @@ func (h *Handlers) CreateWorkflow(e *core.RequestEvent) error {
 	})
 }
 
+// GetWorkflow handles workflow status and history requests
+func (h *Handlers) GetWorkflow(e *core.RequestEvent) error {
+	run, err := h.container.Services.Workflow.GetWorkflow(e.Auth, e.Request.PathValue("id"))
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, run)
+}
+
+// ListWorkflows handles workflow run listing requests
+func (h *Handlers) ListWorkflows(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	perPage, _ := strconv.Atoi(query.Get("perPage"))
+
+	req := &validation.WorkflowListRequest{
+		Type:    query.Get("type"),
+		Status:  query.Get("status"),
+		Gallery: query.Get("gallery"),
+		Page:    page,
+		PerPage: perPage,
+	}
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	runs, err := h.container.Services.Workflow.ListWorkflows(e.Auth, req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, runs)
+}
+
 // SendSignal handles workflow signal requests
 func (h *Handlers) SendSignal(e *core.RequestEvent) error {
 	// Parse request
@@ -129,7 +290,7 @@ func (h *Handlers) SendSignal(e *core.RequestEvent) error {
 	}
 
 	// Send signal using service
-	err = h.container.Services.Signal.SendSignal(req.InstanceID, req.SignalName, req.Data)
+	err = h.container.Services.Signal.SendSignal(e.Auth, req.InstanceID, req.SignalName, req.Data)
 	if err != nil {
 		return errors.HandleError(e, err)
 	}
@@ -164,4 +325,19 @@ func getStringFromBody(body map[string]any, key string) string {
 		return value
 	}
 	return ""
-}
\ No newline at end of file
+}
+
+// requestBaseURL returns the URL the app is served at, the Application URL of
+// the settings. Absolute URLs in public documents must not come from request
+// headers, X-Forwarded-Proto is only trusted behind a configured proxy.
+func requestBaseURL(e *core.RequestEvent) string {
+	if appURL := strings.TrimRight(e.App.Settings().Meta.AppURL, "/"); appURL != "" {
+		return appURL
+	}
+
+	scheme := "http"
+	if e.IsTLS() || (len(e.App.Settings().TrustedProxy.Headers) > 0 && e.Request.Header.Get("X-Forwarded-Proto") == "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + e.Request.Host
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/container"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/iiif"
+)
+
+// IIIFBase redirects the image service base URI to its info.json document
+func (h *Handlers) IIIFBase(e *core.RequestEvent) error {
+	return e.Redirect(http.StatusSeeOther, requestBaseURL(e)+"/iiif/"+e.Request.PathValue("imageId")+"/info.json")
+}
+
+// IIIFImageInfo handles IIIF Image API info.json requests
+func (h *Handlers) IIIFImageInfo(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	imageInfo, err := h.container.Services.IIIF.ImageInfo(info, requestBaseURL(e), e.Request.PathValue("imageId"))
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	e.Response.Header().Set("Content-Type", "application/ld+json;profile=\""+iiif.ImageContext+"\"")
+	return e.JSON(http.StatusOK, imageInfo)
+}
+
+// IIIFImage handles IIIF Image API image requests
+func (h *Handlers) IIIFImage(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	req, err := iiif.ParseImageRequest(
+		e.Request.PathValue("region"),
+		e.Request.PathValue("size"),
+		e.Request.PathValue("rotation"),
+		e.Request.PathValue("qualityFormat"),
+	)
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Invalid IIIF image request", err))
+	}
+
+	data, err := h.container.Services.IIIF.RenderImage(info, e.Request.PathValue("imageId"), req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	// viewers add open to the image they show, tiles and thumbnails are not counted
+	if e.Request.URL.Query().Get("open") != "" {
+		h.container.Services.Analytics.Record(container.EventImageOpen, "", e.Request.PathValue("imageId"))
+	}
+
+	e.Response.Header().Set("Cache-Control", "private, max-age=86400")
+	return e.Blob(http.StatusOK, iiif.ContentTypes[req.Format], data)
+}
+
+// IIIFGalleryManifest handles IIIF Presentation API manifest requests
+func (h *Handlers) IIIFGalleryManifest(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	manifest, err := h.container.Services.IIIF.GalleryManifest(info, requestBaseURL(e), e.Request.PathValue("galleryId"))
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	e.Response.Header().Set("Content-Type", "application/ld+json;profile=\""+iiif.PresentationContext+"\"")
+	return e.JSON(http.StatusOK, manifest)
+}
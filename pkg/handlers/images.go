@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/container"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// maxMultipartMemory is the part of a multipart upload kept in memory, the rest is buffered on disk
+const maxMultipartMemory = 32 << 20
+
+// GalleryImages returns a page of the images of a gallery
+func (h *Handlers) GalleryImages(e *core.RequestEvent) error {
+	client, err := h.galleryClient(e)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	req := imagePageRequest(e)
+	sorts := append([]string{validation.ImageSortManual}, validation.AlbumSorts...)
+	if err := req.Validate(sorts); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	page, err := h.container.Services.Gallery.Images(client, e.Request.PathValue("id"), req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, page)
+}
+
+// AddImages adds uploaded images to an existing gallery
+func (h *Handlers) AddImages(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	galleryID := e.Request.PathValue("id")
+
+	if err := e.Request.ParseMultipartForm(maxMultipartMemory); err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to read uploaded images", err))
+	}
+
+	headers := e.Request.MultipartForm.File["images"]
+	if len(headers) == 0 {
+		return errors.HandleError(e, errors.ValidationError("At least one image is required", nil))
+	}
+
+	images := make([]container.ImageFile, 0, len(headers))
+	for _, header := range headers {
+		file, err := header.Open()
+		if err != nil {
+			return errors.HandleError(e, errors.BadRequest("Failed to read uploaded image", err))
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return errors.HandleError(e, errors.InternalError("Failed to read uploaded image", err))
+		}
+
+		req := &validation.ImageUploadRequest{
+			GalleryID: galleryID,
+			Filename:  header.Filename,
+			Data:      data,
+		}
+		if err := req.Validate(); err != nil {
+			return errors.HandleError(e, err)
+		}
+
+		images = append(images, container.ImageFile{Filename: header.Filename, Data: data})
+	}
+
+	imageIDs, err := h.container.Services.Gallery.AddImages(info, galleryID, images)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, map[string]any{
+		"image_ids": imageIDs,
+		"message":   "Images added successfully",
+	})
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+)
+
+// LikeImage likes an image once per user or share link
+func (h *Handlers) LikeImage(e *core.RequestEvent) error {
+	client, err := h.galleryClient(e)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	status, err := h.container.Services.Like.Like(client, e.Request.PathValue("id"))
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, status)
+}
+
+// UnlikeImage removes the client's like from an image
+func (h *Handlers) UnlikeImage(e *core.RequestEvent) error {
+	client, err := h.galleryClient(e)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	status, err := h.container.Services.Like.Unlike(client, e.Request.PathValue("id"))
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/container"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// GetSelection returns the proofing selection of the current client
+func (h *Handlers) GetSelection(e *core.RequestEvent) error {
+	client, err := h.galleryClient(e)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	selection, err := h.container.Services.Proofing.GetSelection(client, e.Request.PathValue("id"))
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, selection)
+}
+
+// PickImage adds an image to the selection or updates its note
+func (h *Handlers) PickImage(e *core.RequestEvent) error {
+	client, err := h.galleryClient(e)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	req := &validation.SelectionPickRequest{}
+	if e.Request.ContentLength != 0 {
+		if err := e.BindBody(req); err != nil {
+			return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+		}
+	}
+
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	selection, err := h.container.Services.Proofing.Pick(client, e.Request.PathValue("id"), e.Request.PathValue("imageId"), req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, selection)
+}
+
+// UnpickImage removes an image from the selection
+func (h *Handlers) UnpickImage(e *core.RequestEvent) error {
+	client, err := h.galleryClient(e)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	selection, err := h.container.Services.Proofing.Unpick(client, e.Request.PathValue("id"), e.Request.PathValue("imageId"))
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, selection)
+}
+
+// SubmitSelection locks the selection and notifies the gallery owner
+func (h *Handlers) SubmitSelection(e *core.RequestEvent) error {
+	client, err := h.galleryClient(e)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	req := &validation.SelectionSubmitRequest{}
+	if e.Request.ContentLength != 0 {
+		if err := e.BindBody(req); err != nil {
+			return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+		}
+	}
+
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	selection, err := h.container.Services.Proofing.Submit(client, e.Request.PathValue("id"), req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, selection)
+}
+
+// ReopenSelection unlocks a submitted selection so the client can change it
+func (h *Handlers) ReopenSelection(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	selection, err := h.container.Services.Proofing.Reopen(info, e.Request.PathValue("id"))
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, selection)
+}
+
+// ApproveSelection marks a submitted selection as final
+func (h *Handlers) ApproveSelection(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	selection, err := h.container.Services.Proofing.Approve(info, e.Request.PathValue("id"))
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, selection)
+}
+
+// ExportSelection downloads the picked filenames as CSV or a Lightroom filter string
+func (h *Handlers) ExportSelection(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	format := e.Request.URL.Query().Get("format")
+	if format == "" {
+		format = container.ExportFormatCSV
+	}
+
+	export, err := h.container.Services.Proofing.Export(info, e.Request.PathValue("id"), format)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	e.Response.Header().Set("Content-Disposition", "attachment; filename=\""+export.Filename+"\"")
+	return e.Blob(http.StatusOK, export.ContentType, export.Data)
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+)
+
+// Usage returns the storage, galleries, images per gallery and workflow runs
+// of the user against the limits of their plan
+func (h *Handlers) Usage(e *core.RequestEvent) error {
+	usage, err := h.container.Services.Quota.Usage(e.Auth)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, usage)
+}
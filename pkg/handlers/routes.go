@@ -17,10 +17,117 @@ func (h *Handlers) RegisterRoutes(router *router.Router[*core.RequestEvent]) {
 	// Gallery routes
 	router.POST(apiPrefix+"/gallery/create", h.CreateGallery).
 		Bind(apis.RequireAuth())
+	router.PATCH(apiPrefix+"/gallery/{id}", h.UpdateGallery).
+		Bind(apis.RequireAuth())
+	router.DELETE(apiPrefix+"/gallery/{id}", h.DeleteGallery).
+		Bind(apis.RequireAuth())
+
+	// Trash routes (deleted galleries and images are kept until restored or purged)
+	router.GET(apiPrefix+"/trash", h.ListTrash).
+		Bind(apis.RequireAuth())
+	router.POST(apiPrefix+"/trash/galleries/{id}/restore", h.RestoreGallery).
+		Bind(apis.RequireAuth())
+	router.POST(apiPrefix+"/trash/images/{id}/restore", h.RestoreImage).
+		Bind(apis.RequireAuth())
+	router.DELETE(apiPrefix+"/trash/galleries/{id}", h.PurgeGallery).
+		Bind(apis.RequireAuth())
+	router.DELETE(apiPrefix+"/trash/images/{id}", h.PurgeImage).
+		Bind(apis.RequireAuth())
+
+	// Direct-to-storage upload routes
+	router.POST(apiPrefix+"/uploads", h.CreateUploadSession).
+		Bind(apis.RequireAuth())
+	router.POST(apiPrefix+"/uploads/{id}/complete", h.CompleteUploadSession).
+		Bind(apis.RequireAuth())
+
+	// Gallery image and collaborator routes (roles are checked against the gallery)
+	router.GET(apiPrefix+"/galleries/{id}/images", h.GalleryImages)
+	router.POST(apiPrefix+"/galleries/{id}/images", h.AddImages).
+		Bind(apis.RequireAuth())
+	router.POST(apiPrefix+"/galleries/{id}/collaborators", h.InviteCollaborator).
+		Bind(apis.RequireAuth())
+	router.POST(apiPrefix+"/collaborators/{id}/accept", h.AcceptInvitation).
+		Bind(apis.RequireAuth())
+
+	// Proofing routes (clients use their account or a share file token in X-Share-Token)
+	router.GET(apiPrefix+"/galleries/{id}/selection", h.GetSelection)
+	router.PUT(apiPrefix+"/galleries/{id}/selection/{imageId}", h.PickImage)
+	router.DELETE(apiPrefix+"/galleries/{id}/selection/{imageId}", h.UnpickImage)
+	router.POST(apiPrefix+"/galleries/{id}/selection/submit", h.SubmitSelection)
+	router.POST(apiPrefix+"/selections/{id}/reopen", h.ReopenSelection).
+		Bind(apis.RequireAuth())
+	router.POST(apiPrefix+"/selections/{id}/approve", h.ApproveSelection).
+		Bind(apis.RequireAuth())
+	router.GET(apiPrefix+"/selections/{id}/export", h.ExportSelection).
+		Bind(apis.RequireAuth())
+
+	// Like routes (clients use their account or a share file token in X-Share-Token)
+	router.POST(apiPrefix+"/images/{id}/like", h.LikeImage)
+	router.DELETE(apiPrefix+"/images/{id}/like", h.UnlikeImage)
+
+	// Comment routes (clients use their account or a share file token in X-Share-Token)
+	router.GET(apiPrefix+"/images/{id}/comments", h.ListComments)
+	router.POST(apiPrefix+"/images/{id}/comments", h.PostComment)
+	router.PATCH(apiPrefix+"/comments/{id}", h.EditComment)
+	router.DELETE(apiPrefix+"/comments/{id}", h.DeleteComment)
+	router.POST(apiPrefix+"/comments/{id}/hide", h.HideComment).
+		Bind(apis.RequireAuth())
+	router.POST(apiPrefix+"/comments/{id}/approve", h.ApproveComment).
+		Bind(apis.RequireAuth())
+
+	// Search routes (clients use their account or a share file token in X-Share-Token)
+	router.GET(apiPrefix+"/search", h.Search)
+
+	// Map routes for images and galleries (clients use their account or a share file token in X-Share-Token)
+	router.GET(apiPrefix+"/geo/{kind}", h.GeoWithin)
+	router.GET(apiPrefix+"/geo/{kind}/near", h.GeoNear)
+
+	// Timeline routes (clients use their account or a share file token in X-Share-Token)
+	router.GET(apiPrefix+"/timeline", h.Timeline)
+
+	// Smart album routes (images are also listed with a share file token in X-Share-Token)
+	router.POST(apiPrefix+"/albums", h.CreateAlbum).
+		Bind(apis.RequireAuth())
+	router.PATCH(apiPrefix+"/albums/{id}", h.UpdateAlbum).
+		Bind(apis.RequireAuth())
+	router.GET(apiPrefix+"/albums/{id}/images", h.AlbumImages)
+	router.POST(apiPrefix+"/albums/{id}/shares", h.CreateAlbumShare).
+		Bind(apis.RequireAuth())
+
+	// Share link routes (access and images are public, protected by the share and file tokens)
+	router.POST(apiPrefix+"/galleries/{id}/shares", h.CreateShare).
+		Bind(apis.RequireAuth())
+	router.POST(apiPrefix+"/shares/{id}/revoke", h.RevokeShare).
+		Bind(apis.RequireAuth())
+	router.POST(apiPrefix+"/shares/access", h.AccessShare)
+	router.GET(apiPrefix+"/shares/images/{imageId}", h.ShareImage)
+
+	// Analytics routes (gallery owners only)
+	router.GET(apiPrefix+"/analytics/gallery/{id}", h.GalleryAnalytics).
+		Bind(apis.RequireAuth())
+
+	// Usage of the user's plan
+	router.GET(apiPrefix+"/usage", h.Usage).
+		Bind(apis.RequireAuth())
+
+	// Billing routes (the webhook is authenticated by its Stripe signature)
+	router.GET(apiPrefix+"/billing", h.Billing).
+		Bind(apis.RequireAuth())
+	router.POST(apiPrefix+"/billing/checkout", h.BillingCheckout).
+		Bind(apis.RequireAuth())
+	router.POST(apiPrefix+"/billing/webhook", h.StripeWebhook)
+
+	// Embed routes (public galleries, or share links passed in the share query parameter)
+	router.GET(apiPrefix+"/oembed", h.OEmbed)
+	router.GET("/embed/gallery/{id}", h.EmbedGallery)
 
 	// Workflow routes
 	router.POST(apiPrefix+"/workflow/create", h.CreateWorkflow).
 		Bind(apis.RequireAuth())
+	router.GET(apiPrefix+"/workflows", h.ListWorkflows).
+		Bind(apis.RequireAuth())
+	router.GET(apiPrefix+"/workflow/{id}", h.GetWorkflow).
+		Bind(apis.RequireAuth())
 
 	// Signal routes
 	router.POST(apiPrefix+"/signal/send", h.SendSignal).
@@ -29,6 +136,19 @@ func (h *Handlers) RegisterRoutes(router *router.Router[*core.RequestEvent]) {
 	// Settings routes
 	router.POST(apiPrefix+"/settings", h.UpdateSettings).
 		Bind(apis.RequireAuth())
+
+	// IIIF Image and Presentation API routes (access is checked against the gallery rules)
+	router.GET("/iiif/gallery/{galleryId}/manifest.json", h.IIIFGalleryManifest)
+	router.GET("/iiif/{imageId}", h.IIIFBase)
+	router.GET("/iiif/{imageId}/info.json", h.IIIFImageInfo)
+	router.GET("/iiif/{imageId}/{region}/{size}/{rotation}/{qualityFormat}", h.IIIFImage)
+
+	// WebDAV routes (authenticated with basic auth inside the handler).
+	// Methods are listed explicitly so they don't conflict with the static GET catch-all.
+	for _, method := range davMethods {
+		router.Route(method, davPrefix, h.WebDAV)
+		router.Route(method, davPrefix+"/{path...}", h.WebDAV)
+	}
 }
 
 // RegisterStaticRoutes registers static file serving
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// Search searches the galleries and images the client can access
+func (h *Handlers) Search(e *core.RequestEvent) error {
+	client, err := h.galleryClient(e)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	query := e.Request.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	perPage, _ := strconv.Atoi(query.Get("perPage"))
+
+	req := &validation.SearchRequest{
+		Query:    query.Get("q"),
+		Kind:     query.Get("kind"),
+		Camera:   query.Get("camera"),
+		Lens:     query.Get("lens"),
+		Year:     query.Get("year"),
+		Tag:      query.Get("tag"),
+		Location: query.Get("location"),
+		Page:     page,
+		PerPage:  perPage,
+	}
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	result, err := h.container.Services.Search.Search(client, req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/container"
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// shareTokenHeader carries the share file token of clients without an account
+const shareTokenHeader = "X-Share-Token"
+
+// CreateShare creates a share link for a gallery owned by the authenticated user
+func (h *Handlers) CreateShare(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	req := &validation.ShareCreateRequest{}
+	if err := e.BindBody(req); err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	link, err := h.container.Services.Share.CreateShare(info, e.Request.PathValue("id"), req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, link)
+}
+
+// RevokeShare revokes a share link and the file tokens issued for it
+func (h *Handlers) RevokeShare(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	if err := h.container.Services.Share.RevokeShare(info, e.Request.PathValue("id")); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, map[string]string{
+		"message": "Share link revoked successfully",
+	})
+}
+
+// AccessShare exchanges a share token (and password) for the gallery and a file token
+func (h *Handlers) AccessShare(e *core.RequestEvent) error {
+	req := &validation.ShareAccessRequest{}
+	if err := e.BindBody(req); err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	access, err := h.container.Services.Share.Access(req.Token, req.Password, e.RealIP())
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	if access.Gallery != nil {
+		h.container.Services.Analytics.Record(container.EventGalleryView, access.Gallery.ID, "")
+	}
+
+	return e.JSON(http.StatusOK, access)
+}
+
+// ShareImage serves an image of a shared gallery to the holder of a file token
+func (h *Handlers) ShareImage(e *core.RequestEvent) error {
+	grant, err := h.container.Services.Share.VerifyFileToken(e.Request.URL.Query().Get("token"))
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	download := e.Request.URL.Query().Get("download") != ""
+	open := e.Request.URL.Query().Get("open") != ""
+
+	reader, image, err := h.container.Services.Share.OpenImage(grant, e.Request.PathValue("imageId"), download)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+	defer reader.Close()
+
+	// images of smart album shares get their gallery when the counts are written,
+	// grids load the same URL without open so only viewers count as opens
+	switch {
+	case download:
+		h.container.Services.Analytics.Record(container.EventDownload, grant.GalleryID, image.Id)
+	case open:
+		h.container.Services.Analytics.Record(container.EventImageOpen, grant.GalleryID, image.Id)
+	}
+
+	filename := image.GetString("image")
+	contentType := mime.TypeByExtension(filepath.Ext(filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	disposition := "inline"
+	if download {
+		disposition = "attachment"
+	}
+	e.Response.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": filename}))
+	e.Response.Header().Set("Cache-Control", "private, max-age=900")
+
+	return e.Stream(http.StatusOK, contentType, reader)
+}
+
+// galleryClient identifies the client from a share file token or the auth token
+func (h *Handlers) galleryClient(e *core.RequestEvent) (*container.GalleryClient, error) {
+	if token := e.Request.Header.Get(shareTokenHeader); token != "" {
+		grant, err := h.container.Services.Share.VerifyFileToken(token)
+		if err != nil {
+			return nil, err
+		}
+		return &container.GalleryClient{Share: grant}, nil
+	}
+
+	info, err := e.RequestInfo()
+	if err != nil {
+		return nil, errors.BadRequest("Failed to parse request", err)
+	}
+	if info.Auth == nil {
+		return nil, errors.Unauthorized("Sign in or use a share link to access this gallery")
+	}
+
+	return &container.GalleryClient{Info: info}, nil
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// Timeline returns a page of the images the client can access, grouped by capture date
+func (h *Handlers) Timeline(e *core.RequestEvent) error {
+	client, err := h.galleryClient(e)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	query := e.Request.URL.Query()
+	perPage, _ := strconv.Atoi(query.Get("perPage"))
+
+	req := &validation.TimelineRequest{
+		Cursor:  query.Get("cursor"),
+		PerPage: perPage,
+	}
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	timeline, err := h.container.Services.Timeline.Timeline(client, req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, timeline)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+)
+
+// ListTrash returns the trashed galleries and images of the user
+func (h *Handlers) ListTrash(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	trash, err := h.container.Services.Trash.List(info)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, trash)
+}
+
+// RestoreGallery takes a gallery and its images out of the trash
+func (h *Handlers) RestoreGallery(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	gallery, err := h.container.Services.Trash.RestoreGallery(info, e.Request.PathValue("id"))
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, gallery)
+}
+
+// RestoreImage puts a trashed image back into its gallery
+func (h *Handlers) RestoreImage(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	image, err := h.container.Services.Trash.RestoreImage(info, e.Request.PathValue("id"))
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, image)
+}
+
+// PurgeGallery permanently deletes a trashed gallery, its images and their files
+func (h *Handlers) PurgeGallery(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	if err := h.container.Services.Trash.DeleteGallery(info, e.Request.PathValue("id")); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, map[string]string{
+		"message": "Gallery deleted successfully",
+	})
+}
+
+// PurgeImage permanently deletes a trashed image and its files
+func (h *Handlers) PurgeImage(e *core.RequestEvent) error {
+	info, err := e.RequestInfo()
+	if err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	if err := h.container.Services.Trash.DeleteImage(info, e.Request.PathValue("id")); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, map[string]string{
+		"message": "Image deleted successfully",
+	})
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"github.com/dorianlgs/photo-cifu/pkg/errors"
+	"github.com/dorianlgs/photo-cifu/pkg/validation"
+)
+
+// CreateUploadSession hands out presigned URLs for uploading a gallery directly to storage
+func (h *Handlers) CreateUploadSession(e *core.RequestEvent) error {
+	req := &validation.UploadSessionRequest{}
+	if err := e.BindBody(req); err != nil {
+		return errors.HandleError(e, errors.BadRequest("Failed to parse request", err))
+	}
+
+	if err := req.Validate(); err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	session, err := h.container.Services.Upload.CreateSession(e.Auth, req)
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, session)
+}
+
+// CompleteUploadSession creates the gallery once all files of a session have been uploaded
+func (h *Handlers) CompleteUploadSession(e *core.RequestEvent) error {
+	galleryID, err := h.container.Services.Upload.CompleteSession(e.Auth, e.Request.PathValue("id"))
+	if err != nil {
+		return errors.HandleError(e, err)
+	}
+
+	return e.JSON(http.StatusOK, map[string]string{
+		"gallery_id": galleryID,
+		"message":    "Gallery created successfully",
+	})
+}
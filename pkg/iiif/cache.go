@@ -0,0 +1,185 @@
+package iiif
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache is a size bounded on-disk cache for rendered tiles.
+// The least recently used entries are evicted once MaxBytes is exceeded.
+type Cache struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	size    int64
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	size     int64
+	lastUsed time.Time
+}
+
+// NewCache creates a cache rooted at dir, picking up any previously cached files
+func NewCache(dir string, maxBytes int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	c := &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		entries:  map[string]*cacheEntry{},
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		info, err := f.Info()
+		if err != nil || f.IsDir() {
+			continue
+		}
+		c.entries[f.Name()] = &cacheEntry{size: info.Size(), lastUsed: info.ModTime()}
+		c.size += info.Size()
+	}
+
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+
+	return c, nil
+}
+
+// Key builds a stable cache key from the given parts
+func Key(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ImageKey builds the cache key of a rendering of an image. The keys of an
+// image start with its id, so DeleteImage can find them.
+func ImageKey(imageID string, parts ...string) string {
+	return imageID + "-" + Key(parts...)
+}
+
+// Get returns the cached data for key, if present
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok {
+		entry.lastUsed = time.Now()
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.dir, key))
+	if err != nil {
+		c.remove(key)
+		return nil, false
+	}
+
+	return data, true
+}
+
+// Put stores data under key and evicts old entries if the cache grew too large
+func (c *Cache) Put(key string, data []byte) error {
+	if int64(len(data)) > c.maxBytes {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(c.dir, key)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if old, ok := c.entries[key]; ok {
+		c.size -= old.size
+	}
+	c.entries[key] = &cacheEntry{size: int64(len(data)), lastUsed: time.Now()}
+	c.size += int64(len(data))
+	c.evict()
+
+	return nil
+}
+
+// DeleteImage removes the cached renderings of an image
+func (c *Cache) DeleteImage(imageID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := imageID + "-"
+	for key, entry := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.size -= entry.size
+			delete(c.entries, key)
+			os.Remove(filepath.Join(c.dir, key))
+		}
+	}
+}
+
+func (c *Cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[key]; ok {
+		c.size -= entry.size
+		delete(c.entries, key)
+	}
+	os.Remove(filepath.Join(c.dir, key))
+}
+
+// evict drops the least recently used entries; the caller must hold the lock
+func (c *Cache) evict() {
+	if c.size <= c.maxBytes {
+		return
+	}
+
+	keys := make([]string, 0, len(c.entries))
+	for k := range c.entries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.entries[keys[i]].lastUsed.Before(c.entries[keys[j]].lastUsed)
+	})
+
+	for _, k := range keys {
+		if c.size <= c.maxBytes {
+			break
+		}
+		c.size -= c.entries[k].size
+		delete(c.entries, k)
+		os.Remove(filepath.Join(c.dir, k))
+	}
+}
@@ -0,0 +1,150 @@
+package iiif
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Context URIs for the implemented API versions
+const (
+	ImageContext        = "http://iiif.io/api/image/3/context.json"
+	PresentationContext = "http://iiif.io/api/presentation/3/context.json"
+)
+
+// ImageInfo is the info.json document of the Image API 3.0
+type ImageInfo struct {
+	Context        string   `json:"@context"`
+	ID             string   `json:"id"`
+	Type           string   `json:"type"`
+	Protocol       string   `json:"protocol"`
+	Profile        string   `json:"profile"`
+	Width          int      `json:"width"`
+	Height         int      `json:"height"`
+	MaxWidth       int      `json:"maxWidth"`
+	MaxHeight      int      `json:"maxHeight"`
+	ExtraQualities []string `json:"extraQualities"`
+	ExtraFormats   []string `json:"extraFormats"`
+	ExtraFeatures  []string `json:"extraFeatures"`
+}
+
+// NewImageInfo creates the info.json document for an image service
+func NewImageInfo(serviceID string, width, height int) *ImageInfo {
+	return &ImageInfo{
+		Context:        ImageContext,
+		ID:             serviceID,
+		Type:           "ImageService3",
+		Protocol:       "http://iiif.io/api/image",
+		Profile:        "level2",
+		Width:          width,
+		Height:         height,
+		MaxWidth:       MaxDimension,
+		MaxHeight:      MaxDimension,
+		ExtraQualities: []string{"color", "gray", "bitonal"},
+		ExtraFormats:   []string{"gif", "tif"},
+		ExtraFeatures:  []string{"mirroring", "rotationArbitrary", "regionSquare", "sizeUpscaling"},
+	}
+}
+
+// LangMap is a IIIF language map
+type LangMap map[string][]string
+
+// Manifest is a Presentation API 3.0 manifest
+type Manifest struct {
+	Context string    `json:"@context"`
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	Label   LangMap   `json:"label"`
+	Summary LangMap   `json:"summary,omitempty"`
+	Items   []*Canvas `json:"items"`
+}
+
+// Canvas is a single page of a manifest
+type Canvas struct {
+	ID     string            `json:"id"`
+	Type   string            `json:"type"`
+	Width  int               `json:"width"`
+	Height int               `json:"height"`
+	Items  []*AnnotationPage `json:"items"`
+}
+
+// AnnotationPage groups the annotations painted on a canvas
+type AnnotationPage struct {
+	ID    string        `json:"id"`
+	Type  string        `json:"type"`
+	Items []*Annotation `json:"items"`
+}
+
+// Annotation paints a resource onto a canvas
+type Annotation struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	Motivation string    `json:"motivation"`
+	Target     string    `json:"target"`
+	Body       *Resource `json:"body"`
+}
+
+// Resource is the image painted by an annotation
+type Resource struct {
+	ID      string     `json:"id"`
+	Type    string     `json:"type"`
+	Format  string     `json:"format"`
+	Width   int        `json:"width"`
+	Height  int        `json:"height"`
+	Service []*Service `json:"service"`
+}
+
+// Service references the image service of a resource
+type Service struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Profile string `json:"profile"`
+}
+
+// NewManifest creates an empty manifest
+func NewManifest(id, label, summary string) *Manifest {
+	m := &Manifest{
+		Context: PresentationContext,
+		ID:      id,
+		Type:    "Manifest",
+		Label:   LangMap{"none": {label}},
+		Items:   []*Canvas{},
+	}
+	if summary != "" {
+		m.Summary = LangMap{"none": {summary}}
+	}
+	return m
+}
+
+// AddImage appends a canvas painting the given image service
+func (m *Manifest) AddImage(serviceID string, width, height int) {
+	canvasID := fmt.Sprintf("%s/canvas/%d", strings.TrimSuffix(m.ID, "/manifest.json"), len(m.Items)+1)
+
+	m.Items = append(m.Items, &Canvas{
+		ID:     canvasID,
+		Type:   "Canvas",
+		Width:  width,
+		Height: height,
+		Items: []*AnnotationPage{{
+			ID:   canvasID + "/page",
+			Type: "AnnotationPage",
+			Items: []*Annotation{{
+				ID:         canvasID + "/page/annotation",
+				Type:       "Annotation",
+				Motivation: "painting",
+				Target:     canvasID,
+				Body: &Resource{
+					ID:     serviceID + "/full/max/0/default.jpg",
+					Type:   "Image",
+					Format: "image/jpeg",
+					Width:  width,
+					Height: height,
+					Service: []*Service{{
+						ID:      serviceID,
+						Type:    "ImageService3",
+						Profile: "level2",
+					}},
+				},
+			}},
+		}},
+	})
+}
@@ -0,0 +1,243 @@
+package iiif
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Region kinds as defined by the IIIF Image API 3.0
+const (
+	RegionFull    = "full"
+	RegionSquare  = "square"
+	RegionPixels  = "pixels"
+	RegionPercent = "percent"
+)
+
+// Size kinds as defined by the IIIF Image API 3.0
+const (
+	SizeMax     = "max"
+	SizeWidth   = "width"
+	SizeHeight  = "height"
+	SizePercent = "percent"
+	SizeExact   = "exact"
+	SizeBestFit = "bestfit"
+)
+
+// Region represents the parsed region parameter
+type Region struct {
+	Kind       string
+	X, Y, W, H float64
+}
+
+// Size represents the parsed size parameter
+type Size struct {
+	Kind    string
+	Upscale bool
+	W, H    int
+	Percent float64
+}
+
+// Rotation represents the parsed rotation parameter
+type Rotation struct {
+	Mirror  bool
+	Degrees float64
+}
+
+// ImageRequest is a fully parsed IIIF image request
+type ImageRequest struct {
+	Region   Region
+	Size     Size
+	Rotation Rotation
+	Quality  string
+	Format   string
+}
+
+// Supported qualities and output formats
+var (
+	Qualities = []string{"default", "color", "gray", "bitonal"}
+	Formats   = []string{"jpg", "png", "gif", "tif"}
+)
+
+// String returns a canonical representation of the request, used for cache keys
+func (r *ImageRequest) String() string {
+	return fmt.Sprintf("%+v/%+v/%+v/%s.%s", r.Region, r.Size, r.Rotation, r.Quality, r.Format)
+}
+
+// ParseImageRequest parses the path segments of an IIIF image request.
+// The last segment is expected in the "{quality}.{format}" form.
+func ParseImageRequest(region, size, rotation, qualityFormat string) (*ImageRequest, error) {
+	req := &ImageRequest{}
+
+	var err error
+	if req.Region, err = ParseRegion(region); err != nil {
+		return nil, err
+	}
+	if req.Size, err = ParseSize(size); err != nil {
+		return nil, err
+	}
+	if req.Rotation, err = ParseRotation(rotation); err != nil {
+		return nil, err
+	}
+
+	dot := strings.LastIndex(qualityFormat, ".")
+	if dot <= 0 || dot == len(qualityFormat)-1 {
+		return nil, fmt.Errorf("invalid quality and format %q", qualityFormat)
+	}
+	req.Quality = qualityFormat[:dot]
+	req.Format = strings.ToLower(qualityFormat[dot+1:])
+
+	if !contains(Qualities, req.Quality) {
+		return nil, fmt.Errorf("unsupported quality %q", req.Quality)
+	}
+	if !contains(Formats, req.Format) {
+		return nil, fmt.Errorf("unsupported format %q", req.Format)
+	}
+
+	return req, nil
+}
+
+// ParseRegion parses the region parameter
+func ParseRegion(s string) (Region, error) {
+	switch s {
+	case "full":
+		return Region{Kind: RegionFull}, nil
+	case "square":
+		return Region{Kind: RegionSquare}, nil
+	}
+
+	kind := RegionPixels
+	if strings.HasPrefix(s, "pct:") {
+		kind = RegionPercent
+		s = strings.TrimPrefix(s, "pct:")
+	}
+
+	values, err := parseFloats(s, 4)
+	if err != nil {
+		return Region{}, fmt.Errorf("invalid region: %w", err)
+	}
+
+	region := Region{Kind: kind, X: values[0], Y: values[1], W: values[2], H: values[3]}
+	if region.X < 0 || region.Y < 0 || region.W <= 0 || region.H <= 0 {
+		return Region{}, fmt.Errorf("invalid region %q", s)
+	}
+	if kind == RegionPixels && !isIntegral(values...) {
+		return Region{}, fmt.Errorf("pixel region values must be integers")
+	}
+
+	return region, nil
+}
+
+// ParseSize parses the size parameter
+func ParseSize(s string) (Size, error) {
+	size := Size{}
+	if strings.HasPrefix(s, "^") {
+		size.Upscale = true
+		s = s[1:]
+	}
+
+	switch {
+	case s == "max":
+		size.Kind = SizeMax
+		return size, nil
+	case strings.HasPrefix(s, "pct:"):
+		pct, err := strconv.ParseFloat(strings.TrimPrefix(s, "pct:"), 64)
+		if err != nil || pct <= 0 || (pct > 100 && !size.Upscale) {
+			return Size{}, fmt.Errorf("invalid size percentage %q", s)
+		}
+		size.Kind = SizePercent
+		size.Percent = pct
+		return size, nil
+	}
+
+	bestFit := strings.HasPrefix(s, "!")
+	s = strings.TrimPrefix(s, "!")
+
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return Size{}, fmt.Errorf("invalid size %q", s)
+	}
+
+	var err error
+	if parts[0] != "" {
+		if size.W, err = strconv.Atoi(parts[0]); err != nil || size.W <= 0 {
+			return Size{}, fmt.Errorf("invalid size width %q", parts[0])
+		}
+	}
+	if parts[1] != "" {
+		if size.H, err = strconv.Atoi(parts[1]); err != nil || size.H <= 0 {
+			return Size{}, fmt.Errorf("invalid size height %q", parts[1])
+		}
+	}
+
+	switch {
+	case size.W > 0 && size.H > 0 && bestFit:
+		size.Kind = SizeBestFit
+	case size.W > 0 && size.H > 0:
+		size.Kind = SizeExact
+	case bestFit:
+		return Size{}, fmt.Errorf("best fit size requires both width and height")
+	case size.W > 0:
+		size.Kind = SizeWidth
+	case size.H > 0:
+		size.Kind = SizeHeight
+	default:
+		return Size{}, fmt.Errorf("invalid size %q", s)
+	}
+
+	return size, nil
+}
+
+// ParseRotation parses the rotation parameter
+func ParseRotation(s string) (Rotation, error) {
+	rotation := Rotation{}
+	if strings.HasPrefix(s, "!") {
+		rotation.Mirror = true
+		s = s[1:]
+	}
+
+	degrees, err := strconv.ParseFloat(s, 64)
+	if err != nil || degrees < 0 || degrees > 360 {
+		return Rotation{}, fmt.Errorf("invalid rotation %q", s)
+	}
+	rotation.Degrees = math.Mod(degrees, 360)
+
+	return rotation, nil
+}
+
+// Helper functions
+func parseFloats(s string, n int) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d comma separated values", n)
+	}
+
+	values := make([]float64, n)
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func isIntegral(values ...float64) bool {
+	for _, v := range values {
+		if v != math.Trunc(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package iiif
+
+import (
+	"testing"
+)
+
+func TestParseRegion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Region
+		wantErr bool
+	}{
+		{in: "full", want: Region{Kind: RegionFull}},
+		{in: "square", want: Region{Kind: RegionSquare}},
+		{in: "10,20,300,400", want: Region{Kind: RegionPixels, X: 10, Y: 20, W: 300, H: 400}},
+		{in: "0,0,1,1", want: Region{Kind: RegionPixels, W: 1, H: 1}},
+		{in: "pct:10,20,30.5,40", want: Region{Kind: RegionPercent, X: 10, Y: 20, W: 30.5, H: 40}},
+		{in: "10.5,20,300,400", wantErr: true},
+		{in: "10,20,300", wantErr: true},
+		{in: "10,20,300,400,5", wantErr: true},
+		{in: "-1,0,10,10", wantErr: true},
+		{in: "0,0,0,10", wantErr: true},
+		{in: "0,0,10,-10", wantErr: true},
+		{in: "pct:0,0,0,10", wantErr: true},
+		{in: "a,b,c,d", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRegion(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRegion(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRegion(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Size
+		wantErr bool
+	}{
+		{in: "max", want: Size{Kind: SizeMax}},
+		{in: "^max", want: Size{Kind: SizeMax, Upscale: true}},
+		{in: "300,", want: Size{Kind: SizeWidth, W: 300}},
+		{in: ",200", want: Size{Kind: SizeHeight, H: 200}},
+		{in: "300,200", want: Size{Kind: SizeExact, W: 300, H: 200}},
+		{in: "!300,200", want: Size{Kind: SizeBestFit, W: 300, H: 200}},
+		{in: "^!300,200", want: Size{Kind: SizeBestFit, Upscale: true, W: 300, H: 200}},
+		{in: "pct:50", want: Size{Kind: SizePercent, Percent: 50}},
+		{in: "^pct:150", want: Size{Kind: SizePercent, Upscale: true, Percent: 150}},
+		{in: "pct:150", wantErr: true},
+		{in: "pct:0", wantErr: true},
+		{in: "pct:x", wantErr: true},
+		{in: "!300,", wantErr: true},
+		{in: "!,200", wantErr: true},
+		{in: ",", wantErr: true},
+		{in: "0,200", wantErr: true},
+		{in: "300,-1", wantErr: true},
+		{in: "300", wantErr: true},
+		{in: "300,200,100", wantErr: true},
+		{in: "full", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSize(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSize(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRotation(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rotation
+		wantErr bool
+	}{
+		{in: "0", want: Rotation{}},
+		{in: "90", want: Rotation{Degrees: 90}},
+		{in: "22.5", want: Rotation{Degrees: 22.5}},
+		{in: "360", want: Rotation{}},
+		{in: "!0", want: Rotation{Mirror: true}},
+		{in: "!180", want: Rotation{Mirror: true, Degrees: 180}},
+		{in: "-90", wantErr: true},
+		{in: "361", wantErr: true},
+		{in: "!", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRotation(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRotation(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRotation(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImageRequest(t *testing.T) {
+	tests := []struct {
+		name                                  string
+		region, size, rotation, qualityFormat string
+		wantQuality, wantFormat               string
+		wantErr                               bool
+	}{
+		{name: "full request", region: "full", size: "max", rotation: "0", qualityFormat: "default.jpg", wantQuality: "default", wantFormat: "jpg"},
+		{name: "format is case insensitive", region: "square", size: "!200,200", rotation: "90", qualityFormat: "gray.PNG", wantQuality: "gray", wantFormat: "png"},
+		{name: "all qualities", region: "full", size: "max", rotation: "0", qualityFormat: "bitonal.tif", wantQuality: "bitonal", wantFormat: "tif"},
+		{name: "unsupported format", region: "full", size: "max", rotation: "0", qualityFormat: "default.webp", wantErr: true},
+		{name: "unsupported quality", region: "full", size: "max", rotation: "0", qualityFormat: "sepia.jpg", wantErr: true},
+		{name: "missing format", region: "full", size: "max", rotation: "0", qualityFormat: "default", wantErr: true},
+		{name: "empty format", region: "full", size: "max", rotation: "0", qualityFormat: "default.", wantErr: true},
+		{name: "missing quality", region: "full", size: "max", rotation: "0", qualityFormat: ".jpg", wantErr: true},
+		{name: "invalid region", region: "nope", size: "max", rotation: "0", qualityFormat: "default.jpg", wantErr: true},
+		{name: "invalid size", region: "full", size: "nope", rotation: "0", qualityFormat: "default.jpg", wantErr: true},
+		{name: "invalid rotation", region: "full", size: "max", rotation: "nope", qualityFormat: "default.jpg", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := ParseImageRequest(tt.region, tt.size, tt.rotation, tt.qualityFormat)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseImageRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if req.Quality != tt.wantQuality || req.Format != tt.wantFormat {
+				t.Errorf("ParseImageRequest() quality, format = %q, %q, want %q, %q", req.Quality, req.Format, tt.wantQuality, tt.wantFormat)
+			}
+		})
+	}
+}
+
+func TestImageRequestStringIsCanonical(t *testing.T) {
+	a, err := ParseImageRequest("full", "max", "0", "default.JPG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ParseImageRequest("full", "max", "360", "default.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := ParseImageRequest("full", "^max", "0", "default.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.String() != b.String() {
+		t.Errorf("equivalent requests have different keys: %q and %q", a.String(), b.String())
+	}
+	if a.String() == c.String() {
+		t.Errorf("different requests share the key %q", a.String())
+	}
+}
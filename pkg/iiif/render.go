@@ -0,0 +1,163 @@
+package iiif
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxDimension caps the width and height of rendered images
+const MaxDimension = 4096
+
+// ContentTypes maps the supported output formats to their mime types
+var ContentTypes = map[string]string{
+	"jpg": "image/jpeg",
+	"png": "image/png",
+	"gif": "image/gif",
+	"tif": "image/tiff",
+}
+
+// DecodeConfig reads the dimensions of the image without decoding it fully
+func DecodeConfig(r io.Reader) (width, height int, err error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// Render applies the request to the source image and returns the encoded result
+func Render(r io.Reader, req *ImageRequest) ([]byte, error) {
+	src, err := imaging.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	rect, err := regionRect(src.Bounds(), req.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height, err := targetSize(rect.Dx(), rect.Dy(), req.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	var img image.Image = imaging.Crop(src, rect)
+	if width != rect.Dx() || height != rect.Dy() {
+		img = imaging.Resize(img, width, height, imaging.Lanczos)
+	}
+
+	if req.Rotation.Mirror {
+		img = imaging.FlipH(img)
+	}
+	if req.Rotation.Degrees != 0 {
+		// IIIF rotates clockwise, imaging rotates counter-clockwise
+		img = imaging.Rotate(img, -req.Rotation.Degrees, color.Transparent)
+	}
+
+	switch req.Quality {
+	case "gray":
+		img = imaging.Grayscale(img)
+	case "bitonal":
+		img = imaging.AdjustFunc(imaging.Grayscale(img), func(c color.NRGBA) color.NRGBA {
+			v := uint8(0)
+			if c.R >= 128 {
+				v = 255
+			}
+			return color.NRGBA{R: v, G: v, B: v, A: c.A}
+		})
+	}
+
+	format, err := imaging.FormatFromExtension(req.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := imaging.Encode(buf, img, format, imaging.JPEGQuality(90)); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func regionRect(bounds image.Rectangle, region Region) (image.Rectangle, error) {
+	w, h := bounds.Dx(), bounds.Dy()
+
+	var rect image.Rectangle
+	switch region.Kind {
+	case RegionFull:
+		return bounds, nil
+	case RegionSquare:
+		side := min(w, h)
+		x, y := (w-side)/2, (h-side)/2
+		rect = image.Rect(x, y, x+side, y+side)
+	case RegionPercent:
+		rect = image.Rect(
+			int(math.Round(region.X*float64(w)/100)),
+			int(math.Round(region.Y*float64(h)/100)),
+			int(math.Round((region.X+region.W)*float64(w)/100)),
+			int(math.Round((region.Y+region.H)*float64(h)/100)),
+		)
+	default:
+		rect = image.Rect(int(region.X), int(region.Y), int(region.X+region.W), int(region.Y+region.H))
+	}
+
+	rect = rect.Add(bounds.Min).Intersect(bounds)
+	if rect.Empty() {
+		return image.Rectangle{}, fmt.Errorf("region is outside of the image bounds")
+	}
+
+	return rect, nil
+}
+
+func targetSize(rw, rh int, size Size) (int, int, error) {
+	var w, h int
+	switch size.Kind {
+	case SizeMax:
+		// max is the region size bounded by MaxDimension, ^max scales it up to MaxDimension
+		scale := float64(MaxDimension) / float64(max(rw, rh))
+		if !size.Upscale {
+			scale = math.Min(scale, 1)
+		}
+		w, h = int(math.Round(float64(rw)*scale)), int(math.Round(float64(rh)*scale))
+	case SizeWidth:
+		w = size.W
+		h = int(math.Round(float64(rh) * float64(size.W) / float64(rw)))
+	case SizeHeight:
+		h = size.H
+		w = int(math.Round(float64(rw) * float64(size.H) / float64(rh)))
+	case SizePercent:
+		w = int(math.Round(float64(rw) * size.Percent / 100))
+		h = int(math.Round(float64(rh) * size.Percent / 100))
+	case SizeExact:
+		w, h = size.W, size.H
+	case SizeBestFit:
+		scale := math.Min(float64(size.W)/float64(rw), float64(size.H)/float64(rh))
+		// best fit is never larger than the region unless upscaling is asked for
+		if !size.Upscale {
+			scale = math.Min(scale, 1)
+		}
+		w, h = int(math.Round(float64(rw)*scale)), int(math.Round(float64(rh)*scale))
+	default:
+		return 0, 0, fmt.Errorf("unsupported size")
+	}
+
+	w, h = max(w, 1), max(h, 1)
+
+	if !size.Upscale && (w > rw || h > rh) {
+		return 0, 0, fmt.Errorf("requested size is larger than the region, use ^ to allow upscaling")
+	}
+	if w > MaxDimension || h > MaxDimension {
+		return 0, 0, fmt.Errorf("requested size exceeds the maximum of %dpx", MaxDimension)
+	}
+
+	return w, h, nil
+}
@@ -0,0 +1,43 @@
+package iiif
+
+import (
+	"testing"
+)
+
+func TestTargetSize(t *testing.T) {
+	tests := []struct {
+		name         string
+		rw, rh       int
+		size         Size
+		wantW, wantH int
+		wantErr      bool
+	}{
+		{name: "max", rw: 1000, rh: 500, size: Size{Kind: SizeMax}, wantW: 1000, wantH: 500},
+		{name: "max is bounded by MaxDimension", rw: 8192, rh: 4096, size: Size{Kind: SizeMax}, wantW: MaxDimension, wantH: MaxDimension / 2},
+		{name: "^max scales up to MaxDimension", rw: 1024, rh: 512, size: Size{Kind: SizeMax, Upscale: true}, wantW: MaxDimension, wantH: MaxDimension / 2},
+		{name: "width", rw: 1000, rh: 500, size: Size{Kind: SizeWidth, W: 200}, wantW: 200, wantH: 100},
+		{name: "height", rw: 1000, rh: 500, size: Size{Kind: SizeHeight, H: 100}, wantW: 200, wantH: 100},
+		{name: "percent", rw: 1000, rh: 500, size: Size{Kind: SizePercent, Percent: 25}, wantW: 250, wantH: 125},
+		{name: "exact", rw: 1000, rh: 500, size: Size{Kind: SizeExact, W: 300, H: 300}, wantW: 300, wantH: 300},
+		{name: "best fit", rw: 1000, rh: 500, size: Size{Kind: SizeBestFit, W: 400, H: 400}, wantW: 400, wantH: 200},
+		{name: "best fit keeps a smaller region", rw: 300, rh: 150, size: Size{Kind: SizeBestFit, W: 400, H: 400}, wantW: 300, wantH: 150},
+		{name: "^best fit scales up", rw: 300, rh: 150, size: Size{Kind: SizeBestFit, Upscale: true, W: 400, H: 400}, wantW: 400, wantH: 200},
+		{name: "tiny results are at least a pixel", rw: 1000, rh: 1, size: Size{Kind: SizeWidth, W: 10}, wantW: 10, wantH: 1},
+		{name: "width larger than the region", rw: 100, rh: 100, size: Size{Kind: SizeWidth, W: 200}, wantErr: true},
+		{name: "^width larger than the region", rw: 100, rh: 100, size: Size{Kind: SizeWidth, Upscale: true, W: 200}, wantW: 200, wantH: 200},
+		{name: "larger than MaxDimension", rw: 100, rh: 100, size: Size{Kind: SizeExact, Upscale: true, W: MaxDimension + 1, H: 10}, wantErr: true},
+		{name: "unsupported", rw: 100, rh: 100, size: Size{Kind: "nope"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, h, err := targetSize(tt.rw, tt.rh, tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("targetSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if w != tt.wantW || h != tt.wantH {
+				t.Errorf("targetSize() = %d, %d, want %d, %d", w, h, tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
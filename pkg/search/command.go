@@ -0,0 +1,40 @@
+package search
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+)
+
+// NewCommand creates the search command group
+func NewCommand(app core.App) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "search",
+		Short: "Manage the search index",
+	}
+
+	command.AddCommand(reindexCommand(app))
+
+	return command
+}
+
+// reindexCommand rebuilds the search index from the galleries and images collections
+func reindexCommand(app core.App) *cobra.Command {
+	return &cobra.Command{
+		Use:          "reindex",
+		Example:      "search reindex",
+		Short:        "Rebuilds the full-text search index",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			count, err := Rebuild(app)
+			if err != nil {
+				return fmt.Errorf("failed to rebuild the search index: %w", err)
+			}
+
+			command.Printf("Indexed %d galleries and images.\n", count)
+
+			return nil
+		},
+	}
+}
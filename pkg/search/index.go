@@ -0,0 +1,218 @@
+// Package search maintains the SQLite FTS5 index of galleries and images.
+package search
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Table is the FTS5 table holding the search index
+const Table = "search_index"
+
+// Kinds of indexed records
+const (
+	KindGallery = "gallery"
+	KindImage   = "image"
+)
+
+// Register keeps the index in sync with the galleries and images collections
+// and fills it on start if it is empty
+func Register(app core.App) {
+	app.OnRecordAfterCreateSuccess("galleries").BindFunc(func(e *core.RecordEvent) error {
+		logError(e.App, IndexGallery(e.App, e.Record), e.Record)
+		return e.Next()
+	})
+	app.OnRecordAfterUpdateSuccess("galleries").BindFunc(func(e *core.RecordEvent) error {
+		logError(e.App, IndexGallery(e.App, e.Record), e.Record)
+		return e.Next()
+	})
+	app.OnRecordAfterDeleteSuccess("galleries").BindFunc(func(e *core.RecordEvent) error {
+		logError(e.App, removeGallery(e.App, e.Record.Id), e.Record)
+		return e.Next()
+	})
+
+	app.OnRecordAfterCreateSuccess("images").BindFunc(func(e *core.RecordEvent) error {
+		logError(e.App, IndexImage(e.App, e.Record), e.Record)
+		return e.Next()
+	})
+	app.OnRecordAfterUpdateSuccess("images").BindFunc(func(e *core.RecordEvent) error {
+		logError(e.App, IndexImage(e.App, e.Record), e.Record)
+		return e.Next()
+	})
+	app.OnRecordAfterDeleteSuccess("images").BindFunc(func(e *core.RecordEvent) error {
+		logError(e.App, remove(e.App, KindImage, e.Record.Id), e.Record)
+		return e.Next()
+	})
+
+	app.OnServe().BindFunc(func(e *core.ServeEvent) error {
+		var indexed int
+		err := e.App.DB().Select("count(*)").From(Table).Row(&indexed)
+		if err == nil && indexed == 0 {
+			if total, err := e.App.CountRecords("galleries"); err == nil && total > 0 {
+				if count, err := Rebuild(e.App); err != nil {
+					e.App.Logger().Warn("Failed to build the search index", "error", err)
+				} else {
+					e.App.Logger().Info("Built the search index", "records", count)
+				}
+			}
+		}
+		return e.Next()
+	})
+}
+
+// IndexGallery indexes the gallery and its images
+func IndexGallery(app core.App, gallery *core.Record) error {
+	if err := remove(app, KindGallery, gallery.Id); err != nil {
+		return err
+	}
+
+	err := insert(app, dbx.Params{
+		"kind":        KindGallery,
+		"record_id":   gallery.Id,
+		"gallery":     gallery.Id,
+		"name":        gallery.GetString("name"),
+		"location":    galleryLocation(gallery),
+		"description": gallery.GetString("description"),
+	})
+	if err != nil {
+		return err
+	}
+
+	// images are created before they are added to a gallery, so they are
+	// indexed again here to pick up the gallery they belong to
+	images, err := app.FindRecordsByIds("images", gallery.GetStringSlice("images"))
+	if err != nil {
+		return fmt.Errorf("failed to load gallery images: %w", err)
+	}
+	for _, image := range images {
+		if err := indexImage(app, image, gallery.Id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IndexImage indexes the image with the gallery it belongs to
+func IndexImage(app core.App, image *core.Record) error {
+	galleryID := ""
+	if gallery, err := app.FindFirstRecordByFilter("galleries", "images.id ?= {:id}", dbx.Params{"id": image.Id}); err == nil {
+		galleryID = gallery.Id
+	}
+
+	return indexImage(app, image, galleryID)
+}
+
+// Rebuild recreates the whole index and returns the number of indexed records
+func Rebuild(app core.App) (int, error) {
+	count := 0
+
+	err := app.RunInTransaction(func(txApp core.App) error {
+		if _, err := txApp.DB().Delete(Table, nil).Execute(); err != nil {
+			return fmt.Errorf("failed to clear the search index: %w", err)
+		}
+
+		galleries, err := txApp.FindAllRecords("galleries")
+		if err != nil {
+			return fmt.Errorf("failed to load galleries: %w", err)
+		}
+
+		galleryOf := map[string]string{}
+		for _, gallery := range galleries {
+			if err := insert(txApp, dbx.Params{
+				"kind":        KindGallery,
+				"record_id":   gallery.Id,
+				"gallery":     gallery.Id,
+				"name":        gallery.GetString("name"),
+				"location":    galleryLocation(gallery),
+				"description": gallery.GetString("description"),
+			}); err != nil {
+				return err
+			}
+			count++
+
+			for _, imageID := range gallery.GetStringSlice("images") {
+				galleryOf[imageID] = gallery.Id
+			}
+		}
+
+		images, err := txApp.FindAllRecords("images")
+		if err != nil {
+			return fmt.Errorf("failed to load images: %w", err)
+		}
+		for _, image := range images {
+			if err := insertImage(txApp, image, galleryOf[image.Id]); err != nil {
+				return err
+			}
+			count++
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+func indexImage(app core.App, image *core.Record, galleryID string) error {
+	if err := remove(app, KindImage, image.Id); err != nil {
+		return err
+	}
+	return insertImage(app, image, galleryID)
+}
+
+func insertImage(app core.App, image *core.Record, galleryID string) error {
+	var tags []string
+	image.UnmarshalJSONField("tags", &tags)
+
+	return insert(app, dbx.Params{
+		"kind":      KindImage,
+		"record_id": image.Id,
+		"gallery":   galleryID,
+		"name":      image.GetString("original_filename"),
+		"location":  image.GetString("place"),
+		"caption":   image.GetString("caption"),
+		"alt_text":  image.GetString("alt_text"),
+		"tags":      strings.Join(tags, " "),
+	})
+}
+
+// galleryLocation is the free text location with the canonical place it resolved to
+func galleryLocation(gallery *core.Record) string {
+	return strings.TrimSpace(gallery.GetString("location") + " " + gallery.GetString("place"))
+}
+
+func insert(app core.App, row dbx.Params) error {
+	if _, err := app.DB().Insert(Table, row).Execute(); err != nil {
+		return fmt.Errorf("failed to index %s %s: %w", row["kind"], row["record_id"], err)
+	}
+	return nil
+}
+
+func remove(app core.App, kind, recordID string) error {
+	if _, err := app.DB().Delete(Table, dbx.HashExp{"kind": kind, "record_id": recordID}).Execute(); err != nil {
+		return fmt.Errorf("failed to remove %s %s from the search index: %w", kind, recordID, err)
+	}
+	return nil
+}
+
+// removeGallery removes the gallery and detaches its images, which outlive it
+func removeGallery(app core.App, galleryID string) error {
+	if err := remove(app, KindGallery, galleryID); err != nil {
+		return err
+	}
+
+	_, err := app.DB().Update(Table, dbx.Params{"gallery": ""}, dbx.HashExp{"kind": KindImage, "gallery": galleryID}).Execute()
+	if err != nil {
+		return fmt.Errorf("failed to detach images of gallery %s: %w", galleryID, err)
+	}
+	return nil
+}
+
+func logError(app core.App, err error, record *core.Record) {
+	if err != nil {
+		app.Logger().Warn("Failed to update the search index", "collection", record.Collection().Name, "id", record.Id, "error", err)
+	}
+}
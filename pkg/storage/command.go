@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+
+	"github.com/dorianlgs/photo-cifu/pkg/config"
+)
+
+// NewCommand creates the storage command group
+func NewCommand(app core.App) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "storage",
+		Short: "Manage the file storage",
+	}
+
+	command.AddCommand(migrateCommand(app))
+
+	return command
+}
+
+func migrateCommand(app core.App) *cobra.Command {
+	cfg := config.New()
+	s3 := core.S3Config{
+		Bucket:         cfg.Storage.S3.Bucket,
+		Region:         cfg.Storage.S3.Region,
+		Endpoint:       cfg.Storage.S3.Endpoint,
+		AccessKey:      cfg.Storage.S3.AccessKey,
+		Secret:         cfg.Storage.S3.Secret,
+		ForcePathStyle: cfg.Storage.S3.ForcePathStyle,
+	}
+
+	var to string
+	var dryRun bool
+
+	command := &cobra.Command{
+		Use:          "migrate",
+		Example:      "storage migrate --to s3 --bucket photos --endpoint http://localhost:9000 --dry-run",
+		Short:        "Copies all stored files to another backend and switches the storage settings",
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			migrator, err := NewMigrator(app, to, s3, func(format string, args ...any) {
+				command.Printf(format+"\n", args...)
+			})
+			if err != nil {
+				return err
+			}
+
+			report, runErr := migrator.Run(dryRun)
+			if report != nil {
+				printReport(command, report, dryRun)
+			}
+			if runErr != nil {
+				return runErr
+			}
+
+			if !dryRun {
+				command.Printf("Storage switched to %s.\n", to)
+			}
+
+			return nil
+		},
+	}
+
+	command.Flags().StringVar(&to, "to", "", "the target storage backend (s3 or local)")
+	command.Flags().BoolVar(&dryRun, "dry-run", false, "only report the files that would be copied")
+	command.Flags().StringVar(&s3.Bucket, "bucket", s3.Bucket, "the S3 bucket (env S3_BUCKET)")
+	command.Flags().StringVar(&s3.Region, "region", s3.Region, "the S3 region (env S3_REGION)")
+	command.Flags().StringVar(&s3.Endpoint, "endpoint", s3.Endpoint, "the S3 endpoint (env S3_ENDPOINT)")
+	command.Flags().StringVar(&s3.AccessKey, "access-key", s3.AccessKey, "the S3 access key (env S3_ACCESS_KEY)")
+	command.Flags().StringVar(&s3.Secret, "secret", s3.Secret, "the S3 secret (env S3_SECRET)")
+	command.Flags().BoolVar(&s3.ForcePathStyle, "force-path-style", s3.ForcePathStyle, "use path style addressing, e.g. for MinIO (env S3_FORCE_PATH_STYLE)")
+	command.MarkFlagRequired("to")
+
+	return command
+}
+
+func printReport(command *cobra.Command, report *Report, dryRun bool) {
+	groups := make([]string, 0, len(report.ByCollection))
+	for name := range report.ByCollection {
+		groups = append(groups, name)
+	}
+	sort.Strings(groups)
+
+	for _, name := range groups {
+		stats := report.ByCollection[name]
+		command.Printf("  %-20s %6d files %12s\n", name, stats.Files, formatBytes(stats.Bytes))
+	}
+	command.Printf("Total: %d files, %s\n", report.Total.Files, formatBytes(report.Total.Bytes))
+	command.Printf("Already migrated: %d files\n", report.Skipped)
+
+	if dryRun {
+		command.Printf("To copy: %d files, %s\n", report.Pending.Files, formatBytes(report.Pending.Bytes))
+		return
+	}
+
+	command.Printf("Copied and verified: %d files\n", report.Copied)
+	for _, key := range report.Failed {
+		command.Printf("Failed: %s\n", key)
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,288 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+)
+
+// Storage backends supported by the migrator
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// stateSaveInterval is the number of copied files between state checkpoints
+const stateSaveInterval = 25
+
+// Stats holds file counters for a group of storage objects
+type Stats struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// Report summarizes a migration run
+type Report struct {
+	Total        Stats             `json:"total"`
+	Pending      Stats             `json:"pending"`
+	Copied       int               `json:"copied"`
+	Skipped      int               `json:"skipped"`
+	Failed       []string          `json:"failed"`
+	ByCollection map[string]*Stats `json:"byCollection"`
+}
+
+// Migrator copies every stored file from the current storage to another backend
+type Migrator struct {
+	app       core.App
+	to        string
+	s3        core.S3Config
+	statePath string
+	logf      func(format string, args ...any)
+
+	// state maps the already verified file keys to their sha256 checksum
+	state map[string]string
+}
+
+// NewMigrator creates a migrator towards the given backend. The s3 config is
+// only used when migrating to S3.
+func NewMigrator(app core.App, to string, s3 core.S3Config, logf func(format string, args ...any)) (*Migrator, error) {
+	if to != BackendLocal && to != BackendS3 {
+		return nil, fmt.Errorf("unsupported storage backend %q, expected %q or %q", to, BackendLocal, BackendS3)
+	}
+
+	if to == BackendS3 && (s3.Bucket == "" || s3.Endpoint == "" || s3.AccessKey == "" || s3.Secret == "") {
+		return nil, fmt.Errorf("S3 bucket, endpoint, access key and secret are required")
+	}
+
+	// MinIO and most S3 compatible services accept the default AWS region
+	if to == BackendS3 && s3.Region == "" {
+		s3.Region = "us-east-1"
+	}
+
+	current := BackendLocal
+	if app.Settings().S3.Enabled {
+		current = BackendS3
+	}
+	if current == to {
+		return nil, fmt.Errorf("storage is already using the %s backend", to)
+	}
+
+	m := &Migrator{
+		app:       app,
+		to:        to,
+		s3:        s3,
+		statePath: filepath.Join(app.DataDir(), "storage_migrate_"+to+".json"),
+		logf:      logf,
+		state:     map[string]string{},
+	}
+
+	if err := m.loadState(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Run copies and verifies every file. Files verified by a previous,
+// interrupted run are skipped. Unless dryRun is set, the PocketBase storage
+// settings are switched once every file has been verified.
+func (m *Migrator) Run(dryRun bool) (*Report, error) {
+	src, err := m.app.NewFilesystem()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source storage: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := m.target()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open target storage: %w", err)
+	}
+	defer dst.Close()
+
+	objects, err := src.List("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source files: %w", err)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+
+	report := &Report{Failed: []string{}, ByCollection: map[string]*Stats{}}
+
+	for _, obj := range objects {
+		if obj.IsDir {
+			continue
+		}
+
+		group := m.collectionName(obj.Key)
+		if report.ByCollection[group] == nil {
+			report.ByCollection[group] = &Stats{}
+		}
+		report.ByCollection[group].Files++
+		report.ByCollection[group].Bytes += obj.Size
+		report.Total.Files++
+		report.Total.Bytes += obj.Size
+
+		// files verified by a previous run are skipped if the copy is still intact
+		if _, ok := m.state[obj.Key]; ok {
+			if attrs, err := dst.Attributes(obj.Key); err == nil && attrs.Size == obj.Size {
+				report.Skipped++
+				continue
+			}
+		}
+
+		report.Pending.Files++
+		report.Pending.Bytes += obj.Size
+
+		if dryRun {
+			continue
+		}
+
+		checksum, err := copyFile(src, dst, obj.Key)
+		if err != nil {
+			m.logf("failed to copy %s: %v", obj.Key, err)
+			report.Failed = append(report.Failed, obj.Key)
+			continue
+		}
+
+		m.state[obj.Key] = checksum
+		report.Copied++
+
+		if report.Copied%stateSaveInterval == 0 {
+			if err := m.saveState(); err != nil {
+				return report, err
+			}
+			m.logf("copied %d/%d files", report.Copied, report.Pending.Files)
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	if err := m.saveState(); err != nil {
+		return report, err
+	}
+
+	if len(report.Failed) > 0 {
+		return report, fmt.Errorf("%d files failed verification, rerun the command to retry them", len(report.Failed))
+	}
+
+	if err := m.switchSettings(); err != nil {
+		return report, err
+	}
+
+	// the state is no longer needed once the settings point to the new backend
+	os.Remove(m.statePath)
+
+	return report, nil
+}
+
+func (m *Migrator) target() (*filesystem.System, error) {
+	if m.to == BackendS3 {
+		return filesystem.NewS3(m.s3.Bucket, m.s3.Region, m.s3.Endpoint, m.s3.AccessKey, m.s3.Secret, m.s3.ForcePathStyle)
+	}
+	return filesystem.NewLocal(filepath.Join(m.app.DataDir(), core.LocalStorageDirName))
+}
+
+func (m *Migrator) switchSettings() error {
+	settings := m.app.Settings()
+
+	if m.to == BackendS3 {
+		settings.S3 = m.s3
+		settings.S3.Enabled = true
+	} else {
+		settings.S3.Enabled = false
+	}
+
+	if err := m.app.Save(settings); err != nil {
+		return fmt.Errorf("files were copied but the storage settings could not be saved: %w", err)
+	}
+
+	return nil
+}
+
+// collectionName resolves the collection a file key belongs to
+func (m *Migrator) collectionName(key string) string {
+	id, _, _ := strings.Cut(key, "/")
+	if collection, err := m.app.FindCachedCollectionByNameOrId(id); err == nil {
+		return collection.Name
+	}
+	return id
+}
+
+func (m *Migrator) loadState() error {
+	data, err := os.ReadFile(m.statePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read migration state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &m.state); err != nil {
+		return fmt.Errorf("failed to parse migration state %s: %w", m.statePath, err)
+	}
+
+	return nil
+}
+
+func (m *Migrator) saveState() error {
+	data, err := json.Marshal(m.state)
+	if err != nil {
+		return err
+	}
+
+	tmp := m.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write migration state: %w", err)
+	}
+
+	return os.Rename(tmp, m.statePath)
+}
+
+// copyFile copies a single file and verifies the copy by reading it back
+func copyFile(src, dst *filesystem.System, key string) (string, error) {
+	data, err := readAll(src, key)
+	if err != nil {
+		return "", fmt.Errorf("read source: %w", err)
+	}
+	checksum := sha256Hex(data)
+
+	if err := dst.Upload(data, key); err != nil {
+		return "", fmt.Errorf("upload: %w", err)
+	}
+
+	copied, err := readAll(dst, key)
+	if err != nil {
+		return "", fmt.Errorf("read back: %w", err)
+	}
+
+	if sha256Hex(copied) != checksum {
+		return "", fmt.Errorf("checksum mismatch")
+	}
+
+	return checksum, nil
+}
+
+func readAll(fsys *filesystem.System, key string) ([]byte, error) {
+	r, err := fsys.GetReader(key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
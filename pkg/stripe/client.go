@@ -0,0 +1,122 @@
+package stripe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the Stripe API, stripe-mock listens on http://localhost:12111
+const DefaultBaseURL = "https://api.stripe.com"
+
+// Client calls the Stripe API with a secret key
+type Client struct {
+	Key     string
+	BaseURL string
+	HTTP    *http.Client
+}
+
+// NewClient creates a client for the API at baseURL, DefaultBaseURL when empty
+func NewClient(key, baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		Key:     key,
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Error is an error returned by the API
+type Error struct {
+	Status  int
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("stripe: %s (%d %s)", e.Message, e.Status, e.Type)
+}
+
+// CheckoutSessionParams are the parameters of a subscription checkout
+type CheckoutSessionParams struct {
+	Price             string
+	SuccessURL        string
+	CancelURL         string
+	ClientReferenceID string
+	Customer          string // existing customer, CustomerEmail is used otherwise
+	CustomerEmail     string
+	Metadata          map[string]string // set on both the session and the subscription
+	IdempotencyKey    string
+}
+
+// CreateCheckoutSession creates a subscription checkout session for a single price
+func (c *Client) CreateCheckoutSession(params *CheckoutSessionParams) (*CheckoutSession, error) {
+	form := url.Values{}
+	form.Set("mode", "subscription")
+	form.Set("line_items[0][price]", params.Price)
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("success_url", params.SuccessURL)
+	form.Set("cancel_url", params.CancelURL)
+	if params.ClientReferenceID != "" {
+		form.Set("client_reference_id", params.ClientReferenceID)
+	}
+	if params.Customer != "" {
+		form.Set("customer", params.Customer)
+	} else if params.CustomerEmail != "" {
+		form.Set("customer_email", params.CustomerEmail)
+	}
+	for key, value := range params.Metadata {
+		form.Set("metadata["+key+"]", value)
+		form.Set("subscription_data[metadata]["+key+"]", value)
+	}
+
+	session := &CheckoutSession{}
+	if err := c.post("/v1/checkout/sessions", form, params.IdempotencyKey, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (c *Client) post(path string, form url.Values, idempotencyKey string, result any) error {
+	req, err := http.NewRequest(http.MethodPost, c.BaseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.Key, "")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("stripe: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("stripe: %w", err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := struct {
+			Error *Error `json:"error"`
+		}{Error: &Error{}}
+		if err := json.Unmarshal(body, &apiErr); err != nil || apiErr.Error.Message == "" {
+			apiErr.Error.Message = http.StatusText(resp.StatusCode)
+		}
+		apiErr.Error.Status = resp.StatusCode
+		return apiErr.Error
+	}
+
+	return json.Unmarshal(body, result)
+}
@@ -0,0 +1,92 @@
+package stripe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func sign(payload []byte, secret string, timestamp int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	const secret = "whsec_test"
+	payload := []byte(`{"id":"evt_1","type":"checkout.session.completed"}`)
+	now := time.Unix(1700000000, 0)
+	ts := now.Unix()
+	t0 := "t=" + strconv.FormatInt(ts, 10)
+	valid := sign(payload, secret, ts)
+
+	tests := []struct {
+		name      string
+		header    string
+		tolerance time.Duration
+		now       time.Time
+		want      error
+	}{
+		{"valid", t0 + ",v1=" + valid, 5 * time.Minute, now, nil},
+		{"spaces around parts", t0 + ", v1=" + valid, 5 * time.Minute, now, nil},
+		{"valid among several v1", t0 + ",v1=" + sign(payload, "whsec_old", ts) + ",v1=" + valid, 5 * time.Minute, now, nil},
+		{"valid with v0 and unknown parts", t0 + ",v0=abcd,x,v1=" + valid, 5 * time.Minute, now, nil},
+		{"no valid v1 among several", t0 + ",v1=" + sign(payload, "whsec_old", ts) + ",v1=" + sign(payload, "whsec_other", ts), 5 * time.Minute, now, ErrInvalidSignature},
+		{"wrong secret", t0 + ",v1=" + sign(payload, "whsec_other", ts), 5 * time.Minute, now, ErrInvalidSignature},
+		{"signed for another timestamp", "t=" + strconv.FormatInt(ts+1, 10) + ",v1=" + valid, 5 * time.Minute, now, ErrInvalidSignature},
+		{"not hex", t0 + ",v1=zz" + valid[2:], 5 * time.Minute, now, ErrInvalidSignature},
+		{"missing timestamp", "v1=" + valid, 5 * time.Minute, now, ErrInvalidSignature},
+		{"invalid timestamp", "t=abc,v1=" + valid, 5 * time.Minute, now, ErrInvalidSignature},
+		{"missing signature", t0, 5 * time.Minute, now, ErrInvalidSignature},
+		{"empty header", "", 5 * time.Minute, now, ErrInvalidSignature},
+		{"expired", t0 + ",v1=" + valid, 5 * time.Minute, now.Add(6 * time.Minute), ErrExpiredSignature},
+		{"in the future", t0 + ",v1=" + valid, 5 * time.Minute, now.Add(-6 * time.Minute), ErrExpiredSignature},
+		{"at the tolerance", t0 + ",v1=" + valid, 5 * time.Minute, now.Add(5 * time.Minute), nil},
+		{"no tolerance", t0 + ",v1=" + valid, 0, now.Add(24 * time.Hour), nil},
+		{"expired and invalid", t0 + ",v1=" + sign(payload, "whsec_other", ts), 5 * time.Minute, now.Add(time.Hour), ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignature(payload, tt.header, secret, tt.tolerance, tt.now)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("VerifySignature() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureTamperedPayload(t *testing.T) {
+	const secret = "whsec_test"
+	ts := time.Now().Unix()
+	header := "t=" + strconv.FormatInt(ts, 10) + ",v1=" + sign([]byte(`{"id":"evt_1"}`), secret, ts)
+
+	err := VerifySignature([]byte(`{"id":"evt_2"}`), header, secret, 5*time.Minute, time.Unix(ts, 0))
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifySignature() = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestConstructEvent(t *testing.T) {
+	const secret = "whsec_test"
+	payload := []byte(`{"id":"evt_1","type":"customer.subscription.updated","created":1700000000,"data":{"object":{"id":"sub_1"}}}`)
+	ts := time.Now().Unix()
+	header := "t=" + strconv.FormatInt(ts, 10) + ",v1=" + sign(payload, secret, ts)
+
+	event, err := ConstructEvent(payload, header, secret, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("ConstructEvent() error = %v", err)
+	}
+	if event.ID != "evt_1" || event.Type != EventSubscriptionUpdated || string(event.Data.Object) != `{"id":"sub_1"}` {
+		t.Errorf("ConstructEvent() = %+v", event)
+	}
+
+	if _, err := ConstructEvent(payload, header, "whsec_other", 5*time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("ConstructEvent() with another secret error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
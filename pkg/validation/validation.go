@@ -1,9 +1,14 @@
 package validation
 
 import (
+	"encoding/base64"
 	"fmt"
+	"math"
 	"mime/multipart"
+	"net/mail"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dorianlgs/photo-cifu/pkg/errors"
 )
@@ -46,6 +51,728 @@ func (r *GalleryCreateRequest) Validate() error {
 	return nil
 }
 
+// GalleryUpdateRequest represents a partial gallery update. Nil fields are left unchanged.
+type GalleryUpdateRequest struct {
+	Name              *string `json:"name"`
+	Location          *string `json:"location"`
+	Description       *string `json:"description"`
+	MaxPicks          *int    `json:"max_picks"`
+	CommentModeration *bool   `json:"comment_moderation"`
+	Public            *bool   `json:"public"`
+	// Images reorders the gallery, it must hold the same images as the gallery
+	Images    []string              `json:"images"`
+	Thumbnail *multipart.FileHeader `json:"-"`
+}
+
+// Validate validates the gallery update request
+func (r *GalleryUpdateRequest) Validate() error {
+	if r.Name != nil {
+		if strings.TrimSpace(*r.Name) == "" {
+			return errors.ValidationError("Gallery name is required", nil)
+		}
+		if len(*r.Name) > 100 {
+			return errors.ValidationError("Gallery name must be less than 100 characters", nil)
+		}
+	}
+
+	if r.Location != nil && strings.TrimSpace(*r.Location) == "" {
+		return errors.ValidationError("Gallery location is required", nil)
+	}
+
+	if r.Description != nil && len(*r.Description) > 5000 {
+		return errors.ValidationError("Description must be less than 5000 characters", nil)
+	}
+
+	if r.MaxPicks != nil && *r.MaxPicks < 0 {
+		return errors.ValidationError("Max picks must be 0 (no limit) or more", nil)
+	}
+
+	if r.Thumbnail != nil && !isValidImageFile(r.Thumbnail.Filename) {
+		return errors.ValidationError("Thumbnail must be a valid image file", nil)
+	}
+
+	if r.Name == nil && r.Location == nil && r.Description == nil && r.MaxPicks == nil &&
+		r.CommentModeration == nil && r.Public == nil && r.Images == nil && r.Thumbnail == nil {
+		return errors.ValidationError("Nothing to update", nil)
+	}
+
+	return nil
+}
+
+// ImageUploadRequest represents a single image added to an existing gallery
+type ImageUploadRequest struct {
+	GalleryID string `json:"gallery_id"`
+	Filename  string `json:"filename"`
+	Data      []byte `json:"-"`
+}
+
+// Validate validates the image upload request
+func (r *ImageUploadRequest) Validate() error {
+	if strings.TrimSpace(r.GalleryID) == "" {
+		return errors.ValidationError("Gallery ID is required", nil)
+	}
+
+	if len(r.Data) == 0 {
+		return errors.ValidationError("Image data is required", nil)
+	}
+
+	if !isValidImageFile(r.Filename) {
+		return errors.ValidationError("File must be a valid image file", nil)
+	}
+
+	return nil
+}
+
+// Upload session modes
+const (
+	UploadModeArchive = "archive"
+	UploadModeImages  = "images"
+)
+
+// UploadFile describes a file the client will upload directly to storage
+type UploadFile struct {
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+}
+
+// UploadSessionRequest represents the creation of a direct-to-storage upload
+type UploadSessionRequest struct {
+	Name      string       `json:"name"`
+	Location  string       `json:"location"`
+	Mode      string       `json:"mode"`
+	Files     []UploadFile `json:"files"`
+	Thumbnail UploadFile   `json:"thumbnail"`
+}
+
+// Validate validates the upload session request
+func (r *UploadSessionRequest) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.ValidationError("Gallery name is required", nil)
+	}
+
+	if len(r.Name) > 100 {
+		return errors.ValidationError("Gallery name must be less than 100 characters", nil)
+	}
+
+	if !isValidImageFile(r.Thumbnail.Filename) {
+		return errors.ValidationError("Thumbnail must be a valid image file", nil)
+	}
+
+	switch r.Mode {
+	case UploadModeArchive:
+		if len(r.Files) != 1 {
+			return errors.ValidationError("Archive uploads must contain exactly one zip file", nil)
+		}
+		if !isValidZipFile(r.Files[0].Filename) {
+			return errors.ValidationError("Images file must be a zip archive", nil)
+		}
+	case UploadModeImages:
+		if len(r.Files) == 0 {
+			return errors.ValidationError("At least one image is required", nil)
+		}
+		for _, file := range r.Files {
+			if !isValidImageFile(file.Filename) {
+				return errors.ValidationError(fmt.Sprintf("%s is not a valid image file", file.Filename), nil)
+			}
+		}
+	default:
+		return errors.ValidationError("Upload mode must be archive or images", nil)
+	}
+
+	for _, file := range append(r.Files, r.Thumbnail) {
+		if file.Size < 0 {
+			return errors.ValidationError("File size must not be negative", nil)
+		}
+		if strings.ContainsAny(file.Filename, "/\\") {
+			return errors.ValidationError("File names must not contain path separators", nil)
+		}
+	}
+
+	return nil
+}
+
+// Share link permissions
+const (
+	SharePermissionView     = "view"
+	SharePermissionDownload = "download"
+	SharePermissionLike     = "like"
+	SharePermissionComment  = "comment"
+)
+
+// ShareCreateRequest represents the creation of a gallery share link
+type ShareCreateRequest struct {
+	Label       string   `json:"label"`
+	Permissions []string `json:"permissions"`
+	Expires     string   `json:"expires"`
+	Password    string   `json:"password"`
+}
+
+// Validate validates the share link creation request
+func (r *ShareCreateRequest) Validate() error {
+	if len(r.Label) > 100 {
+		return errors.ValidationError("Label must be less than 100 characters", nil)
+	}
+
+	if len(r.Permissions) == 0 {
+		return errors.ValidationError("At least one permission is required", nil)
+	}
+
+	validPermissions := []string{SharePermissionView, SharePermissionDownload, SharePermissionLike, SharePermissionComment}
+	for _, permission := range r.Permissions {
+		if !contains(validPermissions, permission) {
+			return errors.ValidationError(
+				fmt.Sprintf("Invalid permission. Valid permissions: %s", strings.Join(validPermissions, ", ")),
+				nil,
+			)
+		}
+	}
+
+	if r.Password != "" && (len(r.Password) < 4 || len(r.Password) > 72) {
+		return errors.ValidationError("Password must be between 4 and 72 characters", nil)
+	}
+
+	return nil
+}
+
+// ShareAccessRequest represents a share token exchange
+type ShareAccessRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// Validate validates the share access request
+func (r *ShareAccessRequest) Validate() error {
+	if strings.TrimSpace(r.Token) == "" {
+		return errors.ValidationError("Share token is required", nil)
+	}
+
+	return nil
+}
+
+// OEmbedRequest represents an oEmbed consumer request for a gallery URL
+type OEmbedRequest struct {
+	URL       string
+	MaxWidth  int
+	MaxHeight int
+	Format    string
+}
+
+// Validate validates the oEmbed request
+func (r *OEmbedRequest) Validate() error {
+	if strings.TrimSpace(r.URL) == "" {
+		return errors.ValidationError("URL is required", nil)
+	}
+
+	// the oEmbed spec asks for 501 when the format is not supported
+	if r.Format != "" && r.Format != "json" {
+		return errors.NotImplemented("Only the json format is supported")
+	}
+
+	if r.MaxWidth < 0 || r.MaxHeight < 0 {
+		return errors.ValidationError("maxwidth and maxheight must be positive", nil)
+	}
+
+	return nil
+}
+
+// Embed widget layouts
+const (
+	EmbedLayoutGrid      = "grid"
+	EmbedLayoutSlideshow = "slideshow"
+)
+
+// EmbedRequest represents a request for the embeddable gallery page
+type EmbedRequest struct {
+	Share  string
+	Layout string
+}
+
+// Validate validates the embed request, defaulting to the grid layout
+func (r *EmbedRequest) Validate() error {
+	if r.Layout == "" {
+		r.Layout = EmbedLayoutGrid
+	}
+
+	if r.Layout != EmbedLayoutGrid && r.Layout != EmbedLayoutSlideshow {
+		return errors.ValidationError("Invalid layout. Valid layouts: grid, slideshow", nil)
+	}
+
+	return nil
+}
+
+// CollaboratorInviteRequest represents a gallery collaborator invitation
+type CollaboratorInviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// Validate validates the collaborator invitation
+func (r *CollaboratorInviteRequest) Validate() error {
+	if _, err := mail.ParseAddress(r.Email); err != nil || strings.ContainsAny(r.Email, "<>") {
+		return errors.ValidationError("A valid email address is required", err)
+	}
+
+	validRoles := []string{"viewer", "contributor", "editor"}
+	if !contains(validRoles, r.Role) {
+		return errors.ValidationError(
+			fmt.Sprintf("Invalid role. Valid roles: %s", strings.Join(validRoles, ", ")),
+			nil,
+		)
+	}
+
+	return nil
+}
+
+// SelectionPickRequest represents picking an image for a proofing selection
+type SelectionPickRequest struct {
+	Note string `json:"note"`
+}
+
+// Validate validates the pick request
+func (r *SelectionPickRequest) Validate() error {
+	if len(r.Note) > 1000 {
+		return errors.ValidationError("Note must be less than 1000 characters", nil)
+	}
+
+	return nil
+}
+
+// SelectionSubmitRequest represents submitting a proofing selection
+type SelectionSubmitRequest struct {
+	ClientName string `json:"client_name"`
+}
+
+// Validate validates the submit request
+func (r *SelectionSubmitRequest) Validate() error {
+	if len(r.ClientName) > 100 {
+		return errors.ValidationError("Name must be less than 100 characters", nil)
+	}
+
+	return nil
+}
+
+// CommentRequest represents posting or editing an image comment
+type CommentRequest struct {
+	Text   string `json:"text"`
+	Parent string `json:"parent"`
+}
+
+// Validate validates the comment request
+func (r *CommentRequest) Validate() error {
+	if strings.TrimSpace(r.Text) == "" {
+		return errors.ValidationError("Comment text is required", nil)
+	}
+
+	if len(r.Text) > 2000 {
+		return errors.ValidationError("Comment must be less than 2000 characters", nil)
+	}
+
+	return nil
+}
+
+// SearchRequest represents a search query with its facet filters
+type SearchRequest struct {
+	Query    string
+	Kind     string
+	Camera   string
+	Lens     string
+	Year     string
+	Tag      string
+	Location string
+	Page     int
+	PerPage  int
+}
+
+// Validate validates the search request and applies the paging defaults
+func (r *SearchRequest) Validate() error {
+	if len(r.Query) > 200 {
+		return errors.ValidationError("Search query must be less than 200 characters", nil)
+	}
+
+	if r.Kind != "" && r.Kind != "gallery" && r.Kind != "image" {
+		return errors.ValidationError("Kind must be gallery or image", nil)
+	}
+
+	if r.Year != "" {
+		if year, err := strconv.Atoi(r.Year); err != nil || len(r.Year) != 4 || year < 1800 {
+			return errors.ValidationError("Year must be a four digit year", nil)
+		}
+	}
+
+	if r.Page < 1 {
+		r.Page = 1
+	}
+
+	if r.PerPage < 1 {
+		r.PerPage = 20
+	}
+	if r.PerPage > 100 {
+		return errors.ValidationError("Per page must be at most 100", nil)
+	}
+
+	return nil
+}
+
+// Kinds of markers returned by the map endpoints
+const (
+	GeoKindImages    = "images"
+	GeoKindGalleries = "galleries"
+)
+
+// GeoBoundsRequest represents a map query for the markers inside a bounding box
+type GeoBoundsRequest struct {
+	Kind string
+	// BBox is "minLon,minLat,maxLon,maxLat". minLon is greater than maxLon
+	// when the box crosses the antimeridian.
+	BBox string
+	Zoom int
+
+	// Set by Validate from BBox
+	MinLon float64
+	MinLat float64
+	MaxLon float64
+	MaxLat float64
+}
+
+// Validate validates the bounding box query and parses the box
+func (r *GeoBoundsRequest) Validate() error {
+	if err := validateGeoKind(r.Kind); err != nil {
+		return err
+	}
+
+	values, err := parseCoordinates(r.BBox, 4)
+	if err != nil {
+		return errors.ValidationError("The bbox must be minLon,minLat,maxLon,maxLat", nil)
+	}
+	r.MinLon, r.MinLat, r.MaxLon, r.MaxLat = values[0], values[1], values[2], values[3]
+
+	if !validLon(r.MinLon) || !validLon(r.MaxLon) || !validLat(r.MinLat) || !validLat(r.MaxLat) || r.MinLat > r.MaxLat {
+		return errors.ValidationError("The bbox is outside of the valid coordinates", nil)
+	}
+
+	if r.Zoom < 0 || r.Zoom > 22 {
+		return errors.ValidationError("Zoom must be between 0 and 22", nil)
+	}
+
+	return nil
+}
+
+// GeoNearRequest represents a map query for the markers around a point
+type GeoNearRequest struct {
+	Kind string
+	// Near is "lat,lon"
+	Near string
+	// Radius in meters
+	Radius float64
+	Limit  int
+
+	// Set by Validate from Near
+	Lat float64
+	Lon float64
+}
+
+// Validate validates the radius query, parses the point and applies the defaults
+func (r *GeoNearRequest) Validate() error {
+	if err := validateGeoKind(r.Kind); err != nil {
+		return err
+	}
+
+	values, err := parseCoordinates(r.Near, 2)
+	if err != nil {
+		return errors.ValidationError("The near point must be lat,lon", nil)
+	}
+	r.Lat, r.Lon = values[0], values[1]
+
+	if !validLat(r.Lat) || !validLon(r.Lon) {
+		return errors.ValidationError("The near point is outside of the valid coordinates", nil)
+	}
+
+	if r.Radius <= 0 {
+		r.Radius = 1000
+	}
+	if r.Radius > 500000 || math.IsNaN(r.Radius) {
+		return errors.ValidationError("Radius must be at most 500000 meters", nil)
+	}
+
+	if r.Limit < 1 {
+		r.Limit = 50
+	}
+	if r.Limit > 500 {
+		return errors.ValidationError("Limit must be at most 500", nil)
+	}
+
+	return nil
+}
+
+func validateGeoKind(kind string) error {
+	if kind != GeoKindImages && kind != GeoKindGalleries {
+		return errors.ValidationError("Kind must be images or galleries", nil)
+	}
+	return nil
+}
+
+// parseCoordinates parses a comma separated list of count numbers
+func parseCoordinates(value string, count int) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != count {
+		return nil, fmt.Errorf("expected %d coordinates", count)
+	}
+
+	values := make([]float64, count)
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func validLat(lat float64) bool {
+	return lat >= -90 && lat <= 90
+}
+
+func validLon(lon float64) bool {
+	return lon >= -180 && lon <= 180
+}
+
+// TimelineRequest represents a page of the capture date timeline
+type TimelineRequest struct {
+	// Cursor is the nextCursor of the previous page, empty for the first page
+	Cursor  string
+	PerPage int
+
+	// Set by Validate from Cursor
+	CursorAt string
+	CursorID string
+}
+
+// Validate validates the timeline request, decodes the cursor and applies the paging defaults
+func (r *TimelineRequest) Validate() error {
+	if r.Cursor != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(r.Cursor)
+		at, id, ok := strings.Cut(string(decoded), "|")
+		if err != nil || !ok || at == "" || id == "" {
+			return errors.ValidationError("Invalid timeline cursor", nil)
+		}
+		r.CursorAt, r.CursorID = at, id
+	}
+
+	if r.PerPage < 1 {
+		r.PerPage = 100
+	}
+	if r.PerPage > 500 {
+		return errors.ValidationError("Per page must be at most 500", nil)
+	}
+
+	return nil
+}
+
+// TimelineCursor returns the cursor of the timeline page following the image
+// captured at the date
+func TimelineCursor(at, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(at + "|" + id))
+}
+
+// AnalyticsDayFormat is the format of analytics days, in UTC
+const AnalyticsDayFormat = "2006-01-02"
+
+// AnalyticsRequest represents a time series of gallery analytics
+type AnalyticsRequest struct {
+	// From and To are the first and last day, the last 30 days by default
+	From string
+	To   string
+
+	// Set by Validate from From and To
+	FromDay time.Time
+	ToDay   time.Time
+}
+
+// Validate validates the analytics request and applies the default range
+func (r *AnalyticsRequest) Validate() error {
+	r.ToDay = time.Now().UTC().Truncate(24 * time.Hour)
+	if r.To != "" {
+		day, err := time.Parse(AnalyticsDayFormat, r.To)
+		if err != nil {
+			return errors.ValidationError("To must be a date like 2025-01-31", err)
+		}
+		r.ToDay = day
+	}
+
+	r.FromDay = r.ToDay.AddDate(0, 0, -29)
+	if r.From != "" {
+		day, err := time.Parse(AnalyticsDayFormat, r.From)
+		if err != nil {
+			return errors.ValidationError("From must be a date like 2025-01-01", err)
+		}
+		r.FromDay = day
+	}
+
+	if r.FromDay.After(r.ToDay) {
+		return errors.ValidationError("From must not be after To", nil)
+	}
+	if r.ToDay.Sub(r.FromDay) >= 366*24*time.Hour {
+		return errors.ValidationError("The range must be at most 366 days", nil)
+	}
+
+	return nil
+}
+
+// Image sort modes of gallery and smart album pages
+const (
+	ImageSortManual   = "manual"   // the order of the gallery, galleries only
+	ImageSortNewest   = "newest"   // upload time, newest first
+	ImageSortOldest   = "oldest"   // upload time, oldest first
+	ImageSortCaptured = "captured" // capture time, newest first
+	ImageSortLikes    = "likes"    // most liked first
+	ImageSortFilename = "filename" // original filename
+)
+
+// AlbumSorts are the sort modes of smart albums, which have no order of their own
+var AlbumSorts = []string{ImageSortNewest, ImageSortOldest, ImageSortCaptured, ImageSortLikes, ImageSortFilename}
+
+// ImagePageRequest represents a page of the images of a gallery or smart album
+type ImagePageRequest struct {
+	Sort    string
+	Page    int
+	PerPage int
+}
+
+// Validate validates the page request against the allowed sort modes and applies the paging defaults
+func (r *ImagePageRequest) Validate(sorts []string) error {
+	if r.Sort != "" && !contains(sorts, r.Sort) {
+		return errors.ValidationError(fmt.Sprintf("Invalid sort. Valid sorts: %s", strings.Join(sorts, ", ")), nil)
+	}
+
+	if r.Page < 1 {
+		r.Page = 1
+	}
+
+	if r.PerPage < 1 {
+		r.PerPage = 50
+	}
+	if r.PerPage > 200 {
+		return errors.ValidationError("Per page must be at most 200", nil)
+	}
+
+	return nil
+}
+
+// SmartAlbumFilters is the saved query of a smart album. Images must match all
+// of the set filters.
+type SmartAlbumFilters struct {
+	// YearFrom and YearTo bound the year of the capture time, or the upload
+	// time of images without one
+	YearFrom int `json:"year_from,omitempty"`
+	YearTo   int `json:"year_to,omitempty"`
+	// Camera and Lens match part of the model, ignoring case
+	Camera string `json:"camera,omitempty"`
+	Lens   string `json:"lens,omitempty"`
+	// FocalLengthMin and FocalLengthMax are in millimeters
+	FocalLengthMin float64  `json:"focal_length_min,omitempty"`
+	FocalLengthMax float64  `json:"focal_length_max,omitempty"`
+	MinLikes       int      `json:"min_likes,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	// Place matches part of the place name or the country code of the image
+	Place string `json:"place,omitempty"`
+	// Galleries limits the album to images of these galleries
+	Galleries []string `json:"galleries,omitempty"`
+}
+
+// Validate validates the filters
+func (f *SmartAlbumFilters) Validate() error {
+	for _, year := range []int{f.YearFrom, f.YearTo} {
+		if year != 0 && (year < 1800 || year > 9999) {
+			return errors.ValidationError("Years must be four digit years", nil)
+		}
+	}
+	if f.YearFrom != 0 && f.YearTo != 0 && f.YearFrom > f.YearTo {
+		return errors.ValidationError("The year range must not end before it starts", nil)
+	}
+
+	if len(f.Camera) > 100 || len(f.Lens) > 100 || len(f.Place) > 100 {
+		return errors.ValidationError("Camera, lens and place filters must be less than 100 characters", nil)
+	}
+
+	if f.FocalLengthMin < 0 || f.FocalLengthMax < 0 {
+		return errors.ValidationError("Focal lengths must not be negative", nil)
+	}
+	if f.FocalLengthMax != 0 && f.FocalLengthMin > f.FocalLengthMax {
+		return errors.ValidationError("The focal length range must not end before it starts", nil)
+	}
+
+	if f.MinLikes < 0 {
+		return errors.ValidationError("Minimum likes must not be negative", nil)
+	}
+
+	if len(f.Tags) > 20 {
+		return errors.ValidationError("A smart album can filter on at most 20 tags", nil)
+	}
+	for _, tag := range f.Tags {
+		if strings.TrimSpace(tag) == "" || len(tag) > 50 {
+			return errors.ValidationError("Tags must be between 1 and 50 characters", nil)
+		}
+	}
+
+	if len(f.Galleries) > 100 {
+		return errors.ValidationError("A smart album can filter on at most 100 galleries", nil)
+	}
+
+	if f.YearFrom == 0 && f.YearTo == 0 && f.Camera == "" && f.Lens == "" && f.FocalLengthMin == 0 &&
+		f.FocalLengthMax == 0 && f.MinLikes == 0 && len(f.Tags) == 0 && f.Place == "" && len(f.Galleries) == 0 {
+		return errors.ValidationError("A smart album needs at least one filter", nil)
+	}
+
+	return nil
+}
+
+// SmartAlbumRequest represents the creation or a partial update of a smart
+// album. Nil fields are left unchanged on updates.
+type SmartAlbumRequest struct {
+	Name    *string            `json:"name"`
+	Filters *SmartAlbumFilters `json:"filters"`
+	Sort    *string            `json:"sort"`
+}
+
+// Validate validates the smart album request, creating requires a name and filters
+func (r *SmartAlbumRequest) Validate(create bool) error {
+	if create && (r.Name == nil || r.Filters == nil) {
+		return errors.ValidationError("Smart album name and filters are required", nil)
+	}
+
+	if r.Name != nil {
+		if strings.TrimSpace(*r.Name) == "" {
+			return errors.ValidationError("Smart album name is required", nil)
+		}
+		if len(*r.Name) > 100 {
+			return errors.ValidationError("Smart album name must be less than 100 characters", nil)
+		}
+	}
+
+	if r.Filters != nil {
+		if err := r.Filters.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if r.Sort != nil && !contains(AlbumSorts, *r.Sort) {
+		return errors.ValidationError(fmt.Sprintf("Invalid sort. Valid sorts: %s", strings.Join(AlbumSorts, ", ")), nil)
+	}
+
+	if !create && r.Name == nil && r.Filters == nil && r.Sort == nil {
+		return errors.ValidationError("Nothing to update", nil)
+	}
+
+	return nil
+}
+
+// WorkflowTypes are the workflows that can be started
+var WorkflowTypes = []string{"gallery_process", "image_enhancement", "cleanup", "trash_purge"}
+
+// WorkflowStatuses are the statuses of workflow runs
+var WorkflowStatuses = []string{"running", "waiting", "completed", "failed", "canceled", "unknown"}
+
 // WorkflowCreateRequest represents workflow creation input
 type WorkflowCreateRequest struct {
 	WorkflowType string      `json:"workflow_type"`
@@ -58,10 +785,9 @@ func (r *WorkflowCreateRequest) Validate() error {
 		return errors.ValidationError("Workflow type is required", nil)
 	}
 
-	validTypes := []string{"gallery_process", "image_enhancement", "cleanup"}
-	if !contains(validTypes, r.WorkflowType) {
+	if !contains(WorkflowTypes, r.WorkflowType) {
 		return errors.ValidationError(
-			fmt.Sprintf("Invalid workflow type. Valid types: %s", strings.Join(validTypes, ", ")),
+			fmt.Sprintf("Invalid workflow type. Valid types: %s", strings.Join(WorkflowTypes, ", ")),
 			nil,
 		)
 	}
@@ -89,6 +815,60 @@ func (r *SignalRequest) Validate() error {
 	return nil
 }
 
+// WorkflowListRequest represents the filters of the workflow runs list
+type WorkflowListRequest struct {
+	Type    string
+	Status  string
+	Gallery string
+	Page    int
+	PerPage int
+}
+
+// Validate validates the workflow list filters and applies the paging defaults
+func (r *WorkflowListRequest) Validate() error {
+	if r.Type != "" && !contains(WorkflowTypes, r.Type) {
+		return errors.ValidationError(
+			fmt.Sprintf("Invalid workflow type. Valid types: %s", strings.Join(WorkflowTypes, ", ")),
+			nil,
+		)
+	}
+
+	if r.Status != "" && !contains(WorkflowStatuses, r.Status) {
+		return errors.ValidationError(
+			fmt.Sprintf("Invalid workflow status. Valid statuses: %s", strings.Join(WorkflowStatuses, ", ")),
+			nil,
+		)
+	}
+
+	if r.Page < 1 {
+		r.Page = 1
+	}
+
+	if r.PerPage < 1 {
+		r.PerPage = 20
+	}
+	if r.PerPage > 100 {
+		return errors.ValidationError("Per page must be at most 100", nil)
+	}
+
+	return nil
+}
+
+// CheckoutRequest represents the plan a user wants to subscribe to
+type CheckoutRequest struct {
+	Plan string `json:"plan"`
+}
+
+// Validate validates the checkout request
+func (r *CheckoutRequest) Validate() error {
+	r.Plan = strings.TrimSpace(r.Plan)
+	if r.Plan == "" {
+		return errors.ValidationError("Plan is required", nil)
+	}
+
+	return nil
+}
+
 // Helper functions
 func isValidZipFile(filename string) bool {
 	return strings.HasSuffix(strings.ToLower(filename), ".zip")
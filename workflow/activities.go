@@ -6,36 +6,80 @@ import (
 	"time"
 
 	"github.com/cschleiden/go-workflows/activity"
+	"github.com/cschleiden/go-workflows/workflow"
 	"github.com/pocketbase/pocketbase"
 )
 
 type activities struct {
-	pb *pocketbase.PocketBase
+	pb     *pocketbase.PocketBase
+	images ImageProcessor
 }
 
-func (act *activities) ProcessGalleryImages(ctx context.Context, galleryID string) (int, error) {
-	logger := activity.Logger(ctx)
-	logger.Info("Processing gallery images", "galleryID", galleryID)
+// ImageProcessor does the work of the gallery processing and image enhancement
+// workflows on a single image, it lives with the services that read metadata
+// and render images
+type ImageProcessor interface {
+	ProcessImage(ctx context.Context, imageID string) (*ImageResult, error)
+	EnhanceImage(ctx context.Context, imageID string, options EnhancementOptions) (*EnhancementResult, error)
+	ReviewEnhancement(ctx context.Context, imageID string, approved bool) error
+}
+
+// ImageResult is what processing did to an image
+type ImageResult struct {
+	ImageID     string `json:"image_id"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Derivatives int    `json:"derivatives"` // IIIF sizes rendered or already cached
+	Error       string `json:"error,omitempty"`
+}
 
+// GalleryImageIDs returns the images of a gallery in the gallery's order
+func (act *activities) GalleryImageIDs(ctx context.Context, galleryID string) ([]string, error) {
 	record, err := act.pb.FindRecordById("galleries", galleryID)
 	if err != nil {
-		logger.Error("Failed to find gallery", "galleryID", galleryID, "error", err.Error())
-		return 0, fmt.Errorf("gallery not found: %s", galleryID)
+		return nil, workflow.NewPermanentError(fmt.Errorf("gallery not found: %s", galleryID))
 	}
 
-	logger.Info("Found gallery", "name", record.Get("name"), "location", record.Get("location"))
+	activity.Logger(ctx).Info("Found gallery", "name", record.Get("name"), "location", record.Get("location"))
 
-	// Simulate image processing work
-	time.Sleep(10 * time.Second)
+	return record.GetStringSlice("images"), nil
+}
+
+// ProcessGalleryImages counts the images of a gallery, it is only scheduled
+// by runs of Workflow1
+func (act *activities) ProcessGalleryImages(ctx context.Context, galleryID string) (int, error) {
+	imageIDs, err := act.GalleryImageIDs(ctx, galleryID)
+	return len(imageIDs), err
+}
+
+// ProcessImage extracts the metadata, hash and derivatives of an image
+func (act *activities) ProcessImage(ctx context.Context, imageID string) (*ImageResult, error) {
+	if act.images == nil {
+		return nil, workflow.NewPermanentError(fmt.Errorf("image processing is not available"))
+	}
+
+	return act.images.ProcessImage(ctx, imageID)
+}
+
+// EnhanceImage stores an enhanced version of an image next to its original
+func (act *activities) EnhanceImage(ctx context.Context, imageID string, options EnhancementOptions) (*EnhancementResult, error) {
+	if act.images == nil {
+		return nil, workflow.NewPermanentError(fmt.Errorf("image processing is not available"))
+	}
+
+	return act.images.EnhanceImage(ctx, imageID, options)
+}
 
-	// In a real implementation, this would process the gallery images
-	// For now, return the number of images processed
-	images := record.Get("images")
-	if imageList, ok := images.([]interface{}); ok {
-		return len(imageList), nil
+// ReviewEnhancement replaces an image by its enhanced version when approved,
+// or discards the enhanced version
+func (act *activities) ReviewEnhancement(ctx context.Context, imageID string, approved bool) error {
+	if act.images == nil {
+		return workflow.NewPermanentError(fmt.Errorf("image processing is not available"))
 	}
 
-	return 0, nil
+	return act.images.ReviewEnhancement(ctx, imageID, approved)
 }
 
 func (act *activities) SendNotificationEmail(ctx context.Context, galleryName, userEmail string) error {
@@ -0,0 +1,155 @@
+package workflow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+// Cleanup targets, collected in this order so the uploads of deleted sessions
+// are not reported twice
+const (
+	CleanupOrphanImages   = "orphan_images"
+	CleanupStorageFiles   = "storage_files"
+	CleanupUploadSessions = "upload_sessions"
+	CleanupUploads        = "uploads"
+	CleanupShareLinks     = "share_links"
+)
+
+var cleanupTargets = []string{
+	CleanupOrphanImages,
+	CleanupStorageFiles,
+	CleanupUploadSessions,
+	CleanupUploads,
+	CleanupShareLinks,
+}
+
+// Trash targets, purged by the trash purge workflow instead of the cleanup.
+// Images go first, purging a gallery also purges its trashed images.
+const (
+	CleanupTrashedImages    = "trashed_images"
+	CleanupTrashedGalleries = "trashed_galleries"
+)
+
+var trashTargets = []string{
+	CleanupTrashedImages,
+	CleanupTrashedGalleries,
+}
+
+// maxCleanupSamples is the number of candidates listed per target in the report
+const maxCleanupSamples = 100
+
+// CleanupInput represents the input for the cleanup workflow
+type CleanupInput struct {
+	// DryRun only reports what would be deleted
+	DryRun bool `json:"dry_run"`
+	// GracePeriod in seconds, only records and files older than it are collected
+	GracePeriod int `json:"grace_period"`
+	// BatchSize is the number of records or files deleted per activity
+	BatchSize int `json:"batch_size"`
+}
+
+// CleanupResult is what the cleanup found and deleted for a target
+type CleanupResult struct {
+	Target  string `json:"target"`
+	Found   int    `json:"found"`
+	Deleted int    `json:"deleted"`
+	Failed  int    `json:"failed"`
+	// Truncated is set when there were more candidates than a run collects
+	Truncated bool `json:"truncated"`
+	// Samples are the first candidates, record ids or storage keys
+	Samples []string `json:"samples"`
+}
+
+// CleanupReport is the result of the cleanup workflow
+type CleanupReport struct {
+	DryRun  bool            `json:"dry_run"`
+	Before  time.Time       `json:"before"`
+	Results []CleanupResult `json:"results"`
+}
+
+// CleanupCandidates are the records or storage keys a target would delete
+type CleanupCandidates struct {
+	Items     []string `json:"items"`
+	Truncated bool     `json:"truncated"`
+}
+
+// CleanupBatch is the outcome of deleting a batch of candidates
+type CleanupBatch struct {
+	Deleted int `json:"deleted"`
+	Failed  int `json:"failed"`
+}
+
+// CleanupWorkflow collects images that are in no gallery, storage files without
+// records, abandoned uploads, expired upload sessions and expired share links
+func CleanupWorkflow(ctx workflow.Context, input CleanupInput) (*CleanupReport, error) {
+	logger := workflow.Logger(ctx)
+
+	if input.BatchSize < 1 {
+		input.BatchSize = 100
+	}
+
+	report := &CleanupReport{
+		DryRun:  input.DryRun,
+		Before:  workflow.Now(ctx).Add(-time.Duration(input.GracePeriod) * time.Second).UTC(),
+		Results: []CleanupResult{},
+	}
+	logger.Info("Starting cleanup workflow", "dryRun", input.DryRun, "before", report.Before)
+
+	if err := runCleanup(ctx, cleanupTargets, input.BatchSize, report); err != nil {
+		return report, err
+	}
+
+	logger.Info("Cleanup workflow completed", "dryRun", input.DryRun)
+	return report, nil
+}
+
+// runCleanup finds and, unless it is a dry run, deletes the candidates of the
+// targets in batches, adding a result per target to the report
+func runCleanup(ctx workflow.Context, targets []string, batchSize int, report *CleanupReport) error {
+	logger := workflow.Logger(ctx)
+
+	var a *activities
+
+	options := workflow.ActivityOptions{
+		RetryOptions: workflow.RetryOptions{
+			MaxAttempts:        3,
+			FirstRetryInterval: time.Second * 5,
+			BackoffCoefficient: 2,
+		},
+	}
+
+	for _, target := range targets {
+		candidates, err := workflow.ExecuteActivity[CleanupCandidates](ctx, options, a.FindCleanupCandidates, target, report.Before).Get(ctx)
+		if err != nil {
+			logger.Error("Failed to find cleanup candidates", "target", target, "error", err)
+			return fmt.Errorf("failed to find %s to clean up: %w", target, err)
+		}
+
+		result := CleanupResult{
+			Target:    target,
+			Found:     len(candidates.Items),
+			Truncated: candidates.Truncated,
+			Samples:   candidates.Items[:min(len(candidates.Items), maxCleanupSamples)],
+		}
+
+		for start := 0; start < len(candidates.Items) && !report.DryRun; start += batchSize {
+			batch := candidates.Items[start:min(start+batchSize, len(candidates.Items))]
+
+			deleted, err := workflow.ExecuteActivity[CleanupBatch](ctx, options, a.DeleteCleanupBatch, target, batch, report.Before).Get(ctx)
+			if err != nil {
+				logger.Error("Failed to delete cleanup batch", "target", target, "error", err)
+				result.Failed += len(batch)
+				continue
+			}
+			result.Deleted += deleted.Deleted
+			result.Failed += deleted.Failed
+		}
+
+		logger.Info("Cleaned up", "target", target, "found", result.Found, "deleted", result.Deleted, "failed", result.Failed)
+		report.Results = append(report.Results, result)
+	}
+
+	return nil
+}
@@ -0,0 +1,341 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cschleiden/go-workflows/activity"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// maxCleanupCandidates bounds the candidates a run collects per target, the
+// rest is left for the next run
+const maxCleanupCandidates = 10000
+
+// uploadKeyPrefix is the storage prefix of upload sessions, see container.UploadKeyPrefix
+const uploadKeyPrefix = "uploads/"
+
+// recordCleanups are the collections and conditions of the record targets.
+// Trashed images are left to the trash purge. Share links with client
+// comments, likes or selections are kept with them.
+var recordCleanups = map[string]struct {
+	collection string
+	condition  string
+}{
+	CleanupOrphanImages: {
+		collection: "images",
+		condition: "created < {:before} AND deleted_at = '' AND NOT EXISTS " +
+			"(SELECT 1 FROM galleries g, json_each(g.images) j WHERE j.value = images.id)",
+	},
+	CleanupUploadSessions: {
+		collection: "upload_sessions",
+		condition:  "expires < {:before} AND (status != 'processing' OR updated < {:before})",
+	},
+	CleanupTrashedImages: {
+		collection: "images",
+		condition:  "deleted_at != '' AND deleted_at < {:before}",
+	},
+	CleanupTrashedGalleries: {
+		collection: "galleries",
+		condition:  "deleted_at != '' AND deleted_at < {:before}",
+	},
+	CleanupShareLinks: {
+		collection: "share_links",
+		condition: "(expires < {:before} OR (revoked = TRUE AND updated < {:before}))" +
+			" AND NOT EXISTS (SELECT 1 FROM comments WHERE share = share_links.id)" +
+			" AND NOT EXISTS (SELECT 1 FROM likes WHERE share = share_links.id)" +
+			" AND NOT EXISTS (SELECT 1 FROM selections WHERE share = share_links.id)",
+	},
+}
+
+func (act *activities) FindCleanupCandidates(ctx context.Context, target string, before time.Time) (CleanupCandidates, error) {
+	logger := activity.Logger(ctx)
+	logger.Info("Finding cleanup candidates", "target", target, "before", before)
+
+	var candidates CleanupCandidates
+	var err error
+
+	switch target {
+	case CleanupStorageFiles, CleanupUploads:
+		candidates.Items, err = act.findOrphanFiles(target, before)
+	default:
+		candidates.Items, err = act.findCleanupRecords(target, before, nil)
+	}
+	if err != nil {
+		return candidates, err
+	}
+
+	if len(candidates.Items) > maxCleanupCandidates {
+		candidates.Items = candidates.Items[:maxCleanupCandidates]
+		candidates.Truncated = true
+	}
+
+	return candidates, nil
+}
+
+func (act *activities) DeleteCleanupBatch(ctx context.Context, target string, items []string, before time.Time) (CleanupBatch, error) {
+	logger := activity.Logger(ctx)
+	logger.Info("Deleting cleanup batch", "target", target, "count", len(items))
+
+	switch target {
+	case CleanupStorageFiles, CleanupUploads:
+		return act.deleteOrphanFiles(ctx, target, items, before)
+	default:
+		return act.deleteCleanupRecords(ctx, target, items, before)
+	}
+}
+
+// findCleanupRecords returns the ids of the target's records matching its
+// condition, limited to ids when given
+func (act *activities) findCleanupRecords(target string, before time.Time, ids []string) ([]string, error) {
+	cleanup, ok := recordCleanups[target]
+	if !ok {
+		return nil, fmt.Errorf("unknown cleanup target: %s", target)
+	}
+
+	query := act.pb.DB().Select("id").From(cleanup.collection).
+		Where(dbx.NewExp(cleanup.condition, dbx.Params{"before": before.UTC().Format(types.DefaultDateLayout)})).
+		OrderBy("id").
+		Limit(maxCleanupCandidates + 1)
+	if ids != nil {
+		query.AndWhere(dbx.In("id", toAny(ids)...))
+	}
+
+	var found []string
+	if err := query.Column(&found); err != nil {
+		return nil, fmt.Errorf("failed to find %s: %w", target, err)
+	}
+
+	return found, nil
+}
+
+// deleteCleanupRecords deletes the records that still match the target's
+// condition. Deleting records cascades to their relations and removes their files.
+func (act *activities) deleteCleanupRecords(ctx context.Context, target string, ids []string, before time.Time) (CleanupBatch, error) {
+	logger := activity.Logger(ctx)
+	var batch CleanupBatch
+
+	// the candidates may have changed since they were found
+	ids, err := act.findCleanupRecords(target, before, ids)
+	if err != nil {
+		return batch, err
+	}
+
+	records, err := act.pb.FindRecordsByIds(recordCleanups[target].collection, ids)
+	if err != nil {
+		return batch, fmt.Errorf("failed to load %s: %w", target, err)
+	}
+
+	var fsys *filesystem.System
+	if target == CleanupUploadSessions {
+		if fsys, err = act.pb.NewFilesystem(); err != nil {
+			return batch, fmt.Errorf("failed to open storage: %w", err)
+		}
+		defer fsys.Close()
+	}
+
+	for _, record := range records {
+		if err := act.pb.Delete(record); err != nil {
+			logger.Error("Failed to delete record", "target", target, "id", record.Id, "error", err.Error())
+			batch.Failed++
+			continue
+		}
+
+		if fsys != nil {
+			for _, err := range fsys.DeletePrefix(uploadKeyPrefix + record.Id + "/") {
+				logger.Warn("Failed to delete upload", "sessionID", record.Id, "error", err.Error())
+			}
+		}
+
+		batch.Deleted++
+	}
+
+	return batch, nil
+}
+
+// findOrphanFiles returns the storage keys of the target's orphan files
+func (act *activities) findOrphanFiles(target string, before time.Time) ([]string, error) {
+	fsys, err := act.pb.NewFilesystem()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer fsys.Close()
+
+	prefix := ""
+	if target == CleanupUploads {
+		prefix = uploadKeyPrefix
+	}
+
+	objects, err := fsys.List(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage files: %w", err)
+	}
+
+	checker, err := act.newFileChecker()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, object := range objects {
+		if object.IsDir || !object.ModTime.Before(before) {
+			continue
+		}
+		if checker.orphan(target, object.Key) {
+			keys = append(keys, object.Key)
+			if len(keys) > maxCleanupCandidates {
+				break
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+// deleteOrphanFiles deletes the files that are still orphans and older than before
+func (act *activities) deleteOrphanFiles(ctx context.Context, target string, keys []string, before time.Time) (CleanupBatch, error) {
+	logger := activity.Logger(ctx)
+	var batch CleanupBatch
+
+	fsys, err := act.pb.NewFilesystem()
+	if err != nil {
+		return batch, fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer fsys.Close()
+
+	checker, err := act.newFileChecker()
+	if err != nil {
+		return batch, err
+	}
+
+	for _, key := range keys {
+		attrs, err := fsys.Attributes(key)
+		if err != nil || !attrs.ModTime.Before(before) || !checker.orphan(target, key) {
+			continue
+		}
+
+		if err := fsys.Delete(key); err != nil {
+			logger.Error("Failed to delete file", "key", key, "error", err.Error())
+			batch.Failed++
+			continue
+		}
+		batch.Deleted++
+	}
+
+	return batch, nil
+}
+
+// fileChecker tells the storage files of existing records from orphans
+type fileChecker struct {
+	app core.App
+	// collections with file fields by id, the first segment of their file keys
+	collections map[string]*core.Collection
+	records     map[string]*core.Record
+}
+
+func (act *activities) newFileChecker() (*fileChecker, error) {
+	collections, err := act.pb.FindAllCollections()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load collections: %w", err)
+	}
+
+	checker := &fileChecker{
+		app:         act.pb,
+		collections: map[string]*core.Collection{},
+		records:     map[string]*core.Record{},
+	}
+	for _, collection := range collections {
+		if len(fileFields(collection)) > 0 {
+			checker.collections[collection.Id] = collection
+		}
+	}
+
+	return checker, nil
+}
+
+// orphan reports whether the file at key belongs to no record. Keys outside of
+// the target's layout are never orphans, so unknown files are left alone, and
+// so are files whose record can't be loaded.
+func (c *fileChecker) orphan(target, key string) bool {
+	parts := strings.Split(key, "/")
+
+	if target == CleanupUploads {
+		// uploads/{session}/{file}, kept while the session is pending or processing
+		if len(parts) < 3 || parts[0]+"/" != uploadKeyPrefix {
+			return false
+		}
+		session, err := c.record("upload_sessions", parts[1])
+		if err != nil {
+			return false
+		}
+		return session == nil || session.GetString("status") == "completed" || session.GetString("status") == "failed"
+	}
+
+	// {collection}/{record}/{file} and {collection}/{record}/thumbs_{file}/{thumb}
+	collection, ok := c.collections[parts[0]]
+	if !ok || len(parts) < 3 {
+		return false
+	}
+
+	record, err := c.record(collection.Id, parts[1])
+	if err != nil {
+		return false
+	}
+	if record == nil {
+		return true
+	}
+
+	filename := strings.TrimPrefix(parts[2], "thumbs_")
+	for _, field := range fileFields(collection) {
+		if slices.Contains(record.GetStringSlice(field.GetName()), filename) {
+			return false
+		}
+	}
+	return true
+}
+
+// record returns the record, or nil if it doesn't exist, caching lookups
+func (c *fileChecker) record(collection, id string) (*core.Record, error) {
+	cacheKey := collection + "/" + id
+	if record, ok := c.records[cacheKey]; ok {
+		return record, nil
+	}
+
+	record, err := c.app.FindRecordById(collection, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		record, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.records[cacheKey] = record
+	return record, nil
+}
+
+// fileFields returns the file fields of the collection
+func fileFields(collection *core.Collection) []core.Field {
+	var fields []core.Field
+	for _, field := range collection.Fields {
+		if field.Type() == core.FieldTypeFile {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func toAny(values []string) []any {
+	result := make([]any, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
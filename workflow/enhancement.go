@@ -0,0 +1,155 @@
+package workflow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+// EnhancementReviewSignal is the signal approving or rejecting the enhanced images
+const EnhancementReviewSignal = "enhancement_review"
+
+// Enhancement statuses of an image
+const (
+	EnhancementPending  = "pending"
+	EnhancementApproved = "approved"
+	EnhancementRejected = "rejected"
+)
+
+// EnhancementOptions are the operations applied to each image, see package enhance
+type EnhancementOptions struct {
+	Operations []string `json:"operations"`
+	MaxWidth   int      `json:"max_width"`
+	MaxHeight  int      `json:"max_height"`
+}
+
+// EnhancementInput represents the input for the image enhancement workflow.
+// Either an image or all the images of a gallery are enhanced.
+type EnhancementInput struct {
+	ImageID   string             `json:"image_id"`
+	GalleryID string             `json:"gallery_id"`
+	Options   EnhancementOptions `json:"options"`
+	// Concurrency is the number of images enhanced at once
+	Concurrency int `json:"concurrency"`
+	// ReviewTimeout in seconds, the enhancements are rejected when no review arrives in time
+	ReviewTimeout int `json:"review_timeout"`
+}
+
+// EnhancementReview is the data of the review signal
+type EnhancementReview struct {
+	Approved bool `json:"approved"`
+}
+
+// EnhancementResult is the enhanced version of an image
+type EnhancementResult struct {
+	ImageID string `json:"image_id"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Size    int64  `json:"size"`
+	Error   string `json:"error,omitempty"`
+}
+
+// EnhancementReport is the result of the image enhancement workflow
+type EnhancementReport struct {
+	// Status is approved or rejected, rejected too when the review timed out
+	Status   string              `json:"status"`
+	TimedOut bool                `json:"timed_out"`
+	Enhanced int                 `json:"enhanced"`
+	Failed   int                 `json:"failed"`
+	Results  []EnhancementResult `json:"results"`
+}
+
+// EnhancementWorkflow renders an enhanced version of each image next to its
+// original, then waits for the review signal. Approved enhancements replace
+// the image, keeping the original file, rejected ones are discarded.
+func EnhancementWorkflow(ctx workflow.Context, input EnhancementInput) (*EnhancementReport, error) {
+	logger := workflow.Logger(ctx)
+	logger.Info("Starting image enhancement workflow", "imageID", input.ImageID, "galleryID", input.GalleryID, "operations", input.Options.Operations)
+
+	if input.ReviewTimeout < 1 {
+		input.ReviewTimeout = 7 * 24 * 3600
+	}
+
+	var a *activities
+
+	imageIDs := []string{input.ImageID}
+	if input.ImageID == "" {
+		var err error
+		imageIDs, err = workflow.ExecuteActivity[[]string](ctx, imageActivityOptions, a.GalleryImageIDs, input.GalleryID).Get(ctx)
+		if err != nil {
+			logger.Error("Failed to load gallery images", "error", err)
+			return nil, fmt.Errorf("failed to load gallery images: %w", err)
+		}
+	}
+
+	report := &EnhancementReport{
+		Status:  EnhancementRejected,
+		Results: make([]EnhancementResult, len(imageIDs)),
+	}
+
+	enhanced := []string{}
+	fanOut(ctx, len(imageIDs), input.Concurrency,
+		func(i int) workflow.Future[*EnhancementResult] {
+			return workflow.ExecuteActivity[*EnhancementResult](ctx, imageActivityOptions, a.EnhanceImage, imageIDs[i], input.Options)
+		},
+		func(i int, result *EnhancementResult, err error) {
+			if err != nil {
+				logger.Error("Failed to enhance image", "imageID", imageIDs[i], "error", err)
+				result = &EnhancementResult{ImageID: imageIDs[i], Error: err.Error()}
+			}
+			report.Results[i] = *result
+		},
+	)
+	for _, result := range report.Results {
+		if result.Error != "" {
+			report.Failed++
+			continue
+		}
+		report.Enhanced++
+		enhanced = append(enhanced, result.ImageID)
+	}
+
+	if len(enhanced) == 0 {
+		logger.Info("No image was enhanced")
+		return report, nil
+	}
+
+	logger.Info("Images enhanced, waiting for review", "enhanced", report.Enhanced, "failed", report.Failed)
+
+	tctx, cancel := workflow.WithCancel(ctx)
+	workflow.Select(ctx,
+		workflow.Await(workflow.ScheduleTimer(tctx, time.Duration(input.ReviewTimeout)*time.Second), func(ctx workflow.Context, f workflow.Future[any]) {
+			if _, err := f.Get(ctx); err == nil {
+				logger.Info("Enhancement review timed out")
+				report.TimedOut = true
+			}
+		}),
+		workflow.Receive(workflow.NewSignalChannel[EnhancementReview](ctx, EnhancementReviewSignal), func(ctx workflow.Context, review EnhancementReview, ok bool) {
+			logger.Info("Received enhancement review", "approved", review.Approved)
+			if review.Approved {
+				report.Status = EnhancementApproved
+			}
+			cancel()
+		}),
+	)
+
+	failed := 0
+	fanOut(ctx, len(enhanced), input.Concurrency,
+		func(i int) workflow.Future[any] {
+			return workflow.ExecuteActivity[any](ctx, imageActivityOptions, a.ReviewEnhancement, enhanced[i], report.Status == EnhancementApproved)
+		},
+		func(i int, _ any, err error) {
+			if err != nil {
+				logger.Error("Failed to apply enhancement review", "imageID", enhanced[i], "error", err)
+				failed++
+			}
+		},
+	)
+	if failed > 0 {
+		return report, fmt.Errorf("failed to apply the review to %d images", failed)
+	}
+
+	logger.Info("Image enhancement workflow completed", "status", report.Status)
+	return report, nil
+}
@@ -0,0 +1,41 @@
+package workflow
+
+import (
+	"time"
+
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+// TrashPurgeInput represents the input for the trash purge workflow
+type TrashPurgeInput struct {
+	// DryRun only reports what would be purged
+	DryRun bool `json:"dry_run"`
+	// Retention in seconds, galleries and images trashed longer ago are purged
+	Retention int `json:"retention"`
+	// BatchSize is the number of records deleted per activity
+	BatchSize int `json:"batch_size"`
+}
+
+// TrashPurgeWorkflow permanently deletes the galleries and images that have been
+// in the trash for longer than the retention, along with their files
+func TrashPurgeWorkflow(ctx workflow.Context, input TrashPurgeInput) (*CleanupReport, error) {
+	logger := workflow.Logger(ctx)
+
+	if input.BatchSize < 1 {
+		input.BatchSize = 100
+	}
+
+	report := &CleanupReport{
+		DryRun:  input.DryRun,
+		Before:  workflow.Now(ctx).Add(-time.Duration(input.Retention) * time.Second).UTC(),
+		Results: []CleanupResult{},
+	}
+	logger.Info("Starting trash purge workflow", "dryRun", input.DryRun, "before", report.Before)
+
+	if err := runCleanup(ctx, trashTargets, input.BatchSize, report); err != nil {
+		return report, err
+	}
+
+	logger.Info("Trash purge workflow completed", "dryRun", input.DryRun)
+	return report, nil
+}
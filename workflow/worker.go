@@ -8,13 +8,17 @@ import (
 	"github.com/pocketbase/pocketbase"
 )
 
-func RunWorker(ctx context.Context, mb backend.Backend, pb *pocketbase.PocketBase) {
+func RunWorker(ctx context.Context, mb backend.Backend, pb *pocketbase.PocketBase, images ImageProcessor) {
 	w := worker.New(mb, nil)
 
 	w.RegisterWorkflow(Workflow1)
+	w.RegisterWorkflow(GalleryProcessingWorkflow)
+	w.RegisterWorkflow(ProcessImagesWorkflow)
+	w.RegisterWorkflow(EnhancementWorkflow)
+	w.RegisterWorkflow(CleanupWorkflow)
+	w.RegisterWorkflow(TrashPurgeWorkflow)
 
-	w.RegisterActivity(&activities{pb: pb})
-	w.RegisterActivity(&activities{pb: pb})
+	w.RegisterActivity(&activities{pb: pb, images: images})
 
 	if err := w.Start(ctx); err != nil {
 		panic("could not start worker")
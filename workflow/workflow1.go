@@ -2,6 +2,7 @@ package workflow
 
 import (
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/cschleiden/go-workflows/workflow"
@@ -12,8 +13,178 @@ type GalleryProcessingInput struct {
 	GalleryID   string `json:"gallery_id"`
 	GalleryName string `json:"gallery_name"`
 	UserEmail   string `json:"user_email"`
+	// Concurrency is the number of images processed at once
+	Concurrency int `json:"concurrency"`
+	// BatchSize is the number of images processed by each child workflow
+	BatchSize int `json:"batch_size"`
 }
 
+// GalleryProcessingReport is the result of the gallery processing workflow,
+// with a result per image in the gallery's order
+type GalleryProcessingReport struct {
+	GalleryID string        `json:"gallery_id"`
+	Images    int           `json:"images"`
+	Processed int           `json:"processed"`
+	Failed    int           `json:"failed"`
+	Results   []ImageResult `json:"results"`
+}
+
+// ImageBatchInput is a batch of images processed by a child workflow
+type ImageBatchInput struct {
+	ImageIDs    []string `json:"image_ids"`
+	Concurrency int      `json:"concurrency"`
+}
+
+// imageActivityOptions retry each image on its own, a failing image doesn't
+// fail the others
+var imageActivityOptions = workflow.ActivityOptions{
+	RetryOptions: workflow.RetryOptions{
+		MaxAttempts:        3,
+		FirstRetryInterval: time.Second * 5,
+		BackoffCoefficient: 2,
+	},
+}
+
+// GalleryProcessingWorkflow processes every image of a gallery in child
+// workflows of BatchSize images and notifies the user once they are processed
+func GalleryProcessingWorkflow(ctx workflow.Context, input GalleryProcessingInput) (*GalleryProcessingReport, error) {
+	logger := workflow.Logger(ctx)
+	logger.Info("Starting gallery processing workflow", "galleryID", input.GalleryID, "galleryName", input.GalleryName)
+
+	if input.Concurrency < 1 {
+		input.Concurrency = 4
+	}
+	if input.BatchSize < 1 {
+		input.BatchSize = 100
+	}
+
+	var a *activities
+
+	imageIDs, err := workflow.ExecuteActivity[[]string](ctx, imageActivityOptions, a.GalleryImageIDs, input.GalleryID).Get(ctx)
+	if err != nil {
+		logger.Error("Failed to load gallery images", "error", err)
+		return nil, fmt.Errorf("failed to load gallery images: %w", err)
+	}
+
+	report := &GalleryProcessingReport{
+		GalleryID: input.GalleryID,
+		Images:    len(imageIDs),
+		Results:   make([]ImageResult, 0, len(imageIDs)),
+	}
+
+	// Each batch runs as a child workflow, which keeps the history of this one
+	// short however large the gallery is
+	instanceID := workflow.WorkflowInstance(ctx).InstanceID
+	for start := 0; start < len(imageIDs); start += input.BatchSize {
+		batch := imageIDs[start:min(start+input.BatchSize, len(imageIDs))]
+
+		results, err := workflow.CreateSubWorkflowInstance[[]ImageResult](ctx, workflow.SubWorkflowOptions{
+			InstanceID: fmt.Sprintf("%s-images-%d", instanceID, start/input.BatchSize),
+		}, ProcessImagesWorkflow, ImageBatchInput{ImageIDs: batch, Concurrency: input.Concurrency}).Get(ctx)
+		if err != nil {
+			logger.Error("Failed to process image batch", "start", start, "error", err)
+			results = make([]ImageResult, len(batch))
+			for i, imageID := range batch {
+				results[i] = ImageResult{ImageID: imageID, Error: err.Error()}
+			}
+		}
+
+		for _, result := range results {
+			if result.Error != "" {
+				report.Failed++
+			} else {
+				report.Processed++
+			}
+		}
+		report.Results = append(report.Results, results...)
+	}
+
+	logger.Info("Gallery images processed", "processed", report.Processed, "failed", report.Failed)
+
+	// Notify the user now that the images are processed
+	if input.UserEmail != "" {
+		_, err := workflow.ExecuteActivity[any](ctx, workflow.DefaultActivityOptions, a.SendNotificationEmail, input.GalleryName, input.UserEmail).Get(ctx)
+		if err != nil {
+			logger.Error("Failed to send notification email", "error", err)
+			return report, fmt.Errorf("failed to send notification email: %w", err)
+		}
+		logger.Info("Notification email sent")
+	}
+
+	logger.Info("Gallery processing workflow completed", "galleryID", input.GalleryID)
+	return report, nil
+}
+
+// ProcessImagesWorkflow processes a batch of images, running at most
+// Concurrency image activities at once. Images that still fail after their
+// retries are reported with their error.
+func ProcessImagesWorkflow(ctx workflow.Context, input ImageBatchInput) ([]ImageResult, error) {
+	logger := workflow.Logger(ctx)
+
+	var a *activities
+
+	results := make([]ImageResult, len(input.ImageIDs))
+	fanOut(ctx, len(input.ImageIDs), input.Concurrency,
+		func(i int) workflow.Future[*ImageResult] {
+			return workflow.ExecuteActivity[*ImageResult](ctx, imageActivityOptions, a.ProcessImage, input.ImageIDs[i])
+		},
+		func(i int, result *ImageResult, err error) {
+			if err != nil {
+				logger.Error("Failed to process image", "imageID", input.ImageIDs[i], "error", err)
+				results[i] = ImageResult{ImageID: input.ImageIDs[i], Error: err.Error()}
+				return
+			}
+			results[i] = *result
+		},
+	)
+
+	return results, nil
+}
+
+// fanOut starts n activities with start, running at most concurrency of them
+// at once, and passes the outcome of each to done as it completes
+func fanOut[R any](ctx workflow.Context, n, concurrency int, start func(i int) workflow.Future[R], done func(i int, result R, err error)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	type pending struct {
+		index  int
+		future workflow.Future[R]
+	}
+	running := []pending{}
+
+	// wait for whichever running activity finishes first
+	waitAny := func() {
+		finished := -1
+		cases := make([]workflow.SelectCase, 0, len(running))
+		for i, p := range running {
+			cases = append(cases, workflow.Await(p.future, func(ctx workflow.Context, f workflow.Future[R]) {
+				finished = i
+			}))
+		}
+		workflow.Select(ctx, cases...)
+
+		p := running[finished]
+		result, err := p.future.Get(ctx)
+		done(p.index, result, err)
+		running = slices.Delete(running, finished, finished+1)
+	}
+
+	for i := 0; i < n; i++ {
+		for len(running) >= concurrency {
+			waitAny()
+		}
+		running = append(running, pending{index: i, future: start(i)})
+	}
+	for len(running) > 0 {
+		waitAny()
+	}
+}
+
+// Workflow1 is the first version of the gallery processing workflow. It stays
+// registered so runs started before GalleryProcessingWorkflow replaced it can
+// finish, new runs use GalleryProcessingWorkflow.
 func Workflow1(ctx workflow.Context, input GalleryProcessingInput) error {
 	logger := workflow.Logger(ctx)
 	logger.Info("Starting gallery processing workflow", "galleryID", input.GalleryID, "galleryName", input.GalleryName)
@@ -38,7 +209,7 @@ func Workflow1(ctx workflow.Context, input GalleryProcessingInput) error {
 
 	// Wait for processing completion signal or timeout
 	logger.Info("Waiting for processing completion signal")
-	
+
 	tctx, cancel := workflow.WithCancel(ctx)
 	timerFired := false
 